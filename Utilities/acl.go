@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Group grantees.
+const (
+	AllUsersGroup           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	AuthenticatedUsersGroup = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// GrantToID grants permission to the user with a canonical ID.
+func GrantToID(id string, permission string) *s3.Grant {
+
+	return &s3.Grant{
+		Grantee:    &s3.Grantee{Type: aws.String(s3.TypeCanonicalUser), ID: aws.String(id)},
+		Permission: aws.String(permission),
+	}
+}
+
+// GrantToEmail grants permission to the user with an email address.
+func GrantToEmail(email string, permission string) *s3.Grant {
+
+	return &s3.Grant{
+		Grantee:    &s3.Grantee{Type: aws.String(s3.TypeAmazonCustomerByEmail), EmailAddress: aws.String(email)},
+		Permission: aws.String(permission),
+	}
+}
+
+// GrantToGroup grants permission to a group such as AllUsersGroup.
+func GrantToGroup(uri string, permission string) *s3.Grant {
+
+	return &s3.Grant{
+		Grantee:    &s3.Grantee{Type: aws.String(s3.TypeGroup), URI: aws.String(uri)},
+		Permission: aws.String(permission),
+	}
+}
+
+// PutBucketACLGrants replaces the ACL of a bucket with grants, keeping its
+// owner.
+func PutBucketACLGrants(svc *s3.S3, bucket string, grants ...*s3.Grant) (*s3.PutBucketAclOutput, error) {
+
+	current, err := GetBucketACL(svc, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.PutBucketAcl(&s3.PutBucketAclInput{
+		Bucket: aws.String(bucket),
+		AccessControlPolicy: &s3.AccessControlPolicy{
+			Owner:  current.Owner,
+			Grants: grants,
+		},
+	})
+}
+
+// PutObjectACLGrants replaces the ACL of an object with grants, keeping
+// its owner.
+func PutObjectACLGrants(svc *s3.S3, bucket string, key string, grants ...*s3.Grant) (*s3.PutObjectAclOutput, error) {
+
+	current, err := GetObjectACL(svc, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.PutObjectAcl(&s3.PutObjectAclInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		AccessControlPolicy: &s3.AccessControlPolicy{
+			Owner:  current.Owner,
+			Grants: grants,
+		},
+	})
+}
+
+// GrantList describes grants as sorted "PERMISSION id=<id>" and
+// "PERMISSION uri=<uri>" entries, which compare without regard to order or
+// display names. Grants by email come back as canonical IDs.
+func GrantList(grants []*s3.Grant) []string {
+
+	list := []string{}
+
+	for _, g := range grants {
+		grantee := "id=" + aws.StringValue(g.Grantee.ID)
+		if aws.StringValue(g.Grantee.Type) == s3.TypeGroup {
+			grantee = "uri=" + aws.StringValue(g.Grantee.URI)
+		}
+		list = append(list, aws.StringValue(g.Permission)+" "+grantee)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
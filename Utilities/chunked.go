@@ -0,0 +1,232 @@
+package helpers
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// Payload hashes announcing an aws-chunked body, sent as
+// x-amz-content-sha256.
+const (
+	StreamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// emptySHA256 is the hex SHA-256 of no data.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// ChunkedOptions shape an aws-chunked upload, including the ways of
+// breaking it that tests check the gateway's handling of.
+type ChunkedOptions struct {
+	// ChunkSize is the size of every chunk but the last. Defaults to 64KiB.
+	ChunkSize int
+
+	// Checksum is the algorithm of a checksum sent in a trailer after the
+	// last chunk: CRC32, CRC32C, SHA1 or SHA256. Setting it sends the
+	// chunks unsigned, as STREAMING-UNSIGNED-PAYLOAD-TRAILER.
+	Checksum string
+
+	// ChecksumValue is sent in the trailer in place of the checksum of the
+	// content.
+	ChecksumValue string
+
+	// DecodedLength is sent as x-amz-decoded-content-length in place of
+	// the length of the content.
+	DecodedLength string
+
+	// CorruptChunk is the number, from 1, of a chunk whose signature is
+	// altered. The zero-length chunk ending the body counts as well.
+	CorruptChunk int
+
+	// OmitFinalChunk leaves out the zero-length chunk that ends the body.
+	OmitFinalChunk bool
+}
+
+// ChecksumOf returns the base64 checksum of content with algorithm, as
+// sent in x-amz-checksum-* headers and trailers.
+func ChecksumOf(algorithm string, content string) (string, error) {
+
+	var h hash.Hash
+
+	switch strings.ToUpper(algorithm) {
+	case "CRC32":
+		h = crc32.NewIEEE()
+	case "CRC32C":
+		h = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "SHA1":
+		h = sha1.New()
+	case "SHA256":
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+	}
+
+	h.Write([]byte(content))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// encodeChunked returns the aws-chunked encoding of chunks, each signed by
+// sign unless it is nil, followed by trailer when it is not empty.
+func encodeChunked(chunks []string, sign func(chunk string) string, trailer string) string {
+
+	var body strings.Builder
+
+	for _, chunk := range chunks {
+		body.WriteString(strconv.FormatInt(int64(len(chunk)), 16))
+		if sign != nil {
+			body.WriteString(";chunk-signature=" + sign(chunk))
+		}
+		body.WriteString("\r\n")
+
+		if chunk == "" {
+			if trailer != "" {
+				body.WriteString(trailer + "\r\n")
+			}
+			body.WriteString("\r\n")
+			break
+		}
+
+		body.WriteString(chunk + "\r\n")
+	}
+
+	return body.String()
+}
+
+// chunkSigner returns a function signing the chunks of an aws-chunked
+// body in turn, each signature chaining from the previous one and the
+// first from seed, the signature of the request itself.
+func chunkSigner(secret string, region string, date time.Time, seed string) func(chunk string) string {
+
+	scope := date.Format("20060102") + "/" + region + "/s3/aws4_request"
+	key := signingKey(secret, date.Format("20060102"), region, "s3")
+	previous := seed
+
+	return func(chunk string) string {
+		sum := sha256.Sum256([]byte(chunk))
+		stringToSign := strings.Join([]string{
+			"AWS4-HMAC-SHA256-PAYLOAD",
+			date.Format("20060102T150405Z"),
+			scope,
+			previous,
+			emptySHA256,
+			hex.EncodeToString(sum[:]),
+		}, "\n")
+
+		previous = hex.EncodeToString(hmacSHA256(key, stringToSign))
+		return previous
+	}
+}
+
+// PutObjectChunked uploads content to key in bucket with an aws-chunked
+// PUT signed by the main user, the way newer SDKs stream uploads. It
+// returns the response and its body.
+func PutObjectChunked(bucket string, key string, content string, opts ChunkedOptions) (*http.Response, string, error) {
+
+	u, err := ObjectURL(bucket, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creds, err := Creds.Get()
+	if err != nil {
+		return nil, "", err
+	}
+
+	size := opts.ChunkSize
+	if size <= 0 {
+		size = 64 * 1024
+	}
+
+	var chunks []string
+	for rest := content; rest != ""; {
+		n := size
+		if n > len(rest) {
+			n = len(rest)
+		}
+		chunks = append(chunks, rest[:n])
+		rest = rest[n:]
+	}
+	if !opts.OmitFinalChunk {
+		chunks = append(chunks, "")
+	}
+
+	decoded := strconv.Itoa(len(content))
+	if opts.DecodedLength != "" {
+		decoded = opts.DecodedLength
+	}
+
+	req, err := http.NewRequest("PUT", u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Encoding", "aws-chunked")
+	req.Header.Set("X-Amz-Decoded-Content-Length", decoded)
+
+	now := time.Now().UTC()
+	region := GetConfig().Main.Region
+	signer := v4.NewSigner(Creds, func(s *v4.Signer) { s.DisableURIPathEscaping = true })
+
+	var body string
+
+	if opts.Checksum != "" {
+		name := "x-amz-checksum-" + strings.ToLower(opts.Checksum)
+
+		value := opts.ChecksumValue
+		if value == "" {
+			if value, err = ChecksumOf(opts.Checksum, content); err != nil {
+				return nil, "", err
+			}
+		}
+
+		req.Header.Set("X-Amz-Content-Sha256", StreamingUnsignedTrailer)
+		req.Header.Set("X-Amz-Trailer", name)
+
+		body = encodeChunked(chunks, nil, name+":"+value)
+		req.ContentLength = int64(len(body))
+
+		if _, err := signer.Sign(req, nil, "s3", region, now); err != nil {
+			return nil, "", err
+		}
+	} else {
+		req.Header.Set("X-Amz-Content-Sha256", StreamingPayload)
+
+		// The length of the body does not depend on the signatures in it,
+		// so it can be signed before they are known.
+		unsigned := func(string) string { return emptySHA256 }
+		req.ContentLength = int64(len(encodeChunked(chunks, unsigned, "")))
+
+		if _, err := signer.Sign(req, nil, "s3", region, now); err != nil {
+			return nil, "", err
+		}
+
+		auth := req.Header.Get("Authorization")
+		seed := auth[strings.LastIndex(auth, "Signature=")+len("Signature="):]
+
+		sign := chunkSigner(creds.SecretAccessKey, region, now, seed)
+		n := 0
+
+		body = encodeChunked(chunks, func(chunk string) string {
+			signature := sign(chunk)
+			if n++; n == opts.CorruptChunk {
+				return strings.Repeat("0", len(signature))
+			}
+			return signature
+		}, "")
+	}
+
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+
+	return SendRequest(req)
+}
@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestChunkSigner checks the seed and chunk signatures of the example
+// upload in the AWS documentation of STREAMING-AWS4-HMAC-SHA256-PAYLOAD,
+// and the length of its encoded body.
+func TestChunkSigner(t *testing.T) {
+
+	assert := assert.New(t)
+
+	secret := "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	date := time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+
+	req, _ := http.NewRequest("PUT", "https://s3.amazonaws.com/examplebucket/chunkObject.txt", nil)
+	req.Header.Set("Content-Encoding", "aws-chunked")
+	req.Header.Set("Content-Length", "66824")
+	req.Header.Set("X-Amz-Content-Sha256", StreamingPayload)
+	req.Header.Set("X-Amz-Date", "20130524T000000Z")
+	req.Header.Set("X-Amz-Decoded-Content-Length", "66560")
+	req.Header.Set("X-Amz-Storage-Class", "REDUCED_REDUNDANCY")
+
+	signedHeaders := []string{"content-encoding", "content-length", "host", "x-amz-content-sha256",
+		"x-amz-date", "x-amz-decoded-content-length", "x-amz-storage-class"}
+	scope := CredentialScope("20130524", "us-east-1", "s3")
+	seed := SignatureV4(secret, scope, StringToSignV4(date, scope,
+		CanonicalRequestV4(req, signedHeaders, StreamingPayload, false)))
+	assert.Equal("4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9", seed)
+
+	sign := chunkSigner(secret, "us-east-1", date, seed)
+
+	assert.Equal("ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648", sign(strings.Repeat("a", 65536)))
+	assert.Equal("0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497", sign(strings.Repeat("a", 1024)))
+	assert.Equal("b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9", sign(""))
+
+	body := encodeChunked([]string{strings.Repeat("a", 65536), strings.Repeat("a", 1024), ""},
+		chunkSigner(secret, "us-east-1", date, seed), "")
+	assert.Equal(66824, len(body))
+}
+
+func TestEncodeChunked(t *testing.T) {
+
+	assert := assert.New(t)
+
+	sign := func(chunk string) string { return "sig" }
+	assert.Equal("3;chunk-signature=sig\r\nfoo\r\n0;chunk-signature=sig\r\n\r\n", encodeChunked([]string{"foo", ""}, sign, ""))
+	assert.Equal("3\r\nfoo\r\n0\r\nx-amz-checksum-crc32:jHNlIQ==\r\n\r\n", encodeChunked([]string{"foo", ""}, nil, "x-amz-checksum-crc32:jHNlIQ=="))
+	assert.Equal("3\r\nfoo\r\n", encodeChunked([]string{"foo"}, nil, "x-amz-checksum-crc32:jHNlIQ=="))
+
+	crc, err := ChecksumOf("CRC32", "foo")
+	assert.Nil(err)
+	assert.Equal("jHNlIQ==", crc)
+}
@@ -0,0 +1,346 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Config is the suite configuration. Each section of config.toml maps to a
+// field, and every key can be overridden from the environment as
+// S3TEST_<SECTION>_<KEY>, for example S3TEST_S3MAIN_ENDPOINT.
+type Config struct {
+	Default  DefaultConfig  `key:"default"`
+	Main     UserConfig     `key:"s3main"`
+	Alt      UserConfig     `key:"s3alt"`
+	Tenant   UserConfig     `key:"tenant"`
+	Fixtures FixturesConfig `key:"fixtures"`
+
+	// File is the config file that was read, if any.
+	File string
+}
+
+// DefaultConfig holds the [DEFAULT] section, the settings of the run.
+type DefaultConfig struct {
+	// Embedded is nil when unset: the reference server is then used when
+	// no s3main endpoint is configured.
+	Embedded           *bool    `key:"embedded"`
+	HostStyle          bool     `key:"host_style"`
+	IsSecure           bool     `key:"is_secure"`
+	IncludeTags        []string `key:"include_tags"`
+	ExcludeTags        []string `key:"exclude_tags"`
+	CABundle           string   `key:"ca_bundle"`
+	ClientCert         string   `key:"client_cert"`
+	ClientKey          string   `key:"client_key"`
+	InsecureSkipVerify bool     `key:"insecure_skip_verify"`
+	ReportJSON         string   `key:"report_json"`
+	ReportJUnit        string   `key:"report_junit"`
+	ResolveToEndpoint  bool     `key:"resolve_to_endpoint"`
+
+	// WebsiteEndpoint is the host:port buckets are served as static
+	// websites under, addressed as bucket.website_endpoint.
+	WebsiteEndpoint string `key:"website_endpoint"`
+
+	// LCDebugInterval is how many seconds a lifecycle day lasts on the
+	// gateway, RGW's rgw_lc_debug_interval. Zero skips the tests that wait
+	// for rules to take effect, on the gateway and the embedded server.
+	LCDebugInterval int `key:"lc_debug_interval"`
+}
+
+// UserConfig holds the [s3main], [s3alt] and [tenant] sections, one per
+// principal the suite acts as.
+type UserConfig struct {
+	AccessKey    string `key:"access_key"`
+	AccessSecret string `key:"access_secret"`
+	Region       string `key:"region"`
+	Endpoint     string `key:"endpoint"`
+	DisplayName  string `key:"display_name"`
+	Email        string `key:"email"`
+	Bucket       string `key:"bucket"`
+	SSE          string `key:"sse"`
+	KMSKeyID     string `key:"kmskeyid"`
+
+	// IsSecure is nil when unset, deferring to [DEFAULT].
+	IsSecure *bool `key:"is_secure"`
+}
+
+// FixturesConfig holds the [fixtures] section.
+type FixturesConfig struct {
+	BucketPrefix string `key:"bucket_prefix"`
+}
+
+// envAliases are the short environment variables kept alongside the
+// S3TEST_<SECTION>_<KEY> form. They take precedence over it.
+var envAliases = map[string]string{
+	"default.embedded":            "S3TEST_EMBEDDED",
+	"default.host_style":          "S3TEST_HOST_STYLE",
+	"default.resolve_to_endpoint": "S3TEST_RESOLVE_TO_ENDPOINT",
+	"default.is_secure":           "S3TEST_IS_SECURE",
+	"s3main.is_secure":            "S3TEST_IS_SECURE",
+	"default.include_tags":        "S3TEST_TAGS",
+	"default.exclude_tags":        "S3TEST_EXCLUDE_TAGS",
+	"default.report_json":         "S3TEST_REPORT_JSON",
+	"default.report_junit":        "S3TEST_REPORT_JUNIT",
+	"default.lc_debug_interval":   "S3TEST_LC_DEBUG_INTERVAL",
+}
+
+var (
+	configMu sync.Mutex
+	config   *Config
+)
+
+// GetConfig returns the configuration, loading it on first use. It panics
+// if the configuration is invalid; call LoadConfig first to handle that.
+func GetConfig() *Config {
+
+	configMu.Lock()
+	loaded := config
+	configMu.Unlock()
+
+	if loaded != nil {
+		return loaded
+	}
+
+	if err := LoadConfig(); err != nil {
+		panic(fmt.Sprintf("failed to load config, %v", err))
+	}
+
+	return GetConfig()
+}
+
+// LoadConfig reads the configuration and validates it. The file is named by
+// S3TEST_CONF; without it, config.toml, config.yaml or config.json is looked
+// for in the repository root and in the parent of the working directory, and
+// the environment alone is used if there is none.
+func LoadConfig() error {
+
+	viper.Reset()
+
+	conf := &Config{}
+
+	if path := os.Getenv("S3TEST_CONF"); path != "" {
+		conf.File = path
+	} else {
+	search:
+		for _, dir := range configDirs() {
+			for _, ext := range []string{"toml", "yaml", "json"} {
+				path := filepath.Join(dir, "config."+ext)
+				if _, err := os.Stat(path); err == nil {
+					conf.File = path
+					break search
+				}
+			}
+		}
+	}
+
+	if conf.File != "" {
+		viper.SetConfigFile(conf.File)
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read %s, %v", conf.File, err)
+		}
+	}
+
+	walkKeys(conf, func(key string, field reflect.Value) {
+
+		for _, name := range []string{envAliases[key], envName(key)} {
+			if v, ok := os.LookupEnv(name); ok && name != "" {
+				viper.Set(key, v)
+				break
+			}
+		}
+	})
+
+	// Fill in fixture values the config may leave blank so the suite can
+	// run against the reference server with no config file at all.
+	if isEmbedded() {
+		viper.SetDefault("s3main.access_key", "0555b35654ad1656d804")
+		viper.SetDefault("s3main.access_secret", "h7GhxuBLTrlhVUyxSPUKUV8r/2EI4ngqJxD7iBdBYLhwluN30JaT3Q==")
+		viper.SetDefault("s3main.display_name", "M. Tester")
+		viper.SetDefault("s3main.email", "tester@example.com")
+		viper.SetDefault("s3alt.access_key", "NOPQRSTUVWXYZABCDEFG")
+		viper.SetDefault("s3alt.access_secret", "nopqrstuvwxyzabcdefghijklmnabcdefghijklm")
+		viper.SetDefault("s3alt.display_name", "john.doe")
+		viper.SetDefault("s3alt.email", "john.doe@example.com")
+		viper.SetDefault("default.lc_debug_interval", 1)
+	}
+
+	viper.SetDefault("s3main.region", "us-east-1")
+	viper.SetDefault("s3alt.region", viper.GetString("s3main.region"))
+	viper.SetDefault("tenant.region", viper.GetString("s3main.region"))
+	viper.SetDefault("fixtures.bucket_prefix", "s3test")
+
+	walkKeys(conf, func(key string, field reflect.Value) {
+
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(viper.GetString(key))
+		case int:
+			field.SetInt(int64(viper.GetInt(key)))
+		case bool:
+			field.SetBool(isTrue(viper.GetString(key)))
+		case *bool:
+			if viper.IsSet(key) {
+				on := isTrue(viper.GetString(key))
+				field.Set(reflect.ValueOf(&on))
+			}
+		case []string:
+			field.Set(reflect.ValueOf(stringList(viper.Get(key))))
+		}
+	})
+
+	if err := conf.validate(); err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	config = conf
+	configMu.Unlock()
+
+	return nil
+}
+
+// configDirs returns the directories the config file is looked for in: the
+// repository root, found from the location of this file, and the parent
+// of the working directory.
+func configDirs() []string {
+
+	dirs := []string{".."}
+
+	if _, file, _, ok := runtime.Caller(0); ok && filepath.IsAbs(file) {
+		dirs = append([]string{filepath.Join(filepath.Dir(file), "..")}, dirs...)
+	}
+
+	return dirs
+}
+
+// envName returns the environment variable that overrides key, as
+// S3TEST_<SECTION>_<KEY>.
+func envName(key string) string {
+
+	return "S3TEST_" + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// walkKeys calls fn for every field of conf with its dotted config key.
+func walkKeys(conf *Config, fn func(key string, field reflect.Value)) {
+
+	sections := reflect.ValueOf(conf).Elem()
+
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Type().Field(i).Tag.Get("key")
+		if section == "" {
+			continue
+		}
+
+		fields := sections.Field(i)
+		for j := 0; j < fields.NumField(); j++ {
+			fn(section+"."+fields.Type().Field(j).Tag.Get("key"), fields.Field(j))
+		}
+	}
+}
+
+// isEmbedded reports whether the settings read so far select the reference
+// server, before they are decoded.
+func isEmbedded() bool {
+
+	if setting := viper.Get("default.embedded"); setting != nil {
+		return isTrue(fmt.Sprint(setting))
+	}
+
+	return viper.GetString("s3main.endpoint") == ""
+}
+
+// stringList reads a comma separated string or a list.
+func stringList(v interface{}) []string {
+
+	var raw []string
+
+	switch v := v.(type) {
+	case string:
+		raw = strings.Split(v, ",")
+	case []string:
+		raw = v
+	case []interface{}:
+		for _, item := range v {
+			raw = append(raw, fmt.Sprint(item))
+		}
+	}
+
+	var items []string
+	for _, item := range raw {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// UseEmbedded reports whether the suite runs against the reference server.
+func (c *Config) UseEmbedded() bool {
+
+	if c.Default.Embedded != nil {
+		return *c.Default.Embedded
+	}
+
+	return c.Main.Endpoint == ""
+}
+
+// Secure reports whether the suite connects over HTTPS. `is_secure` under
+// [s3main] takes precedence over [DEFAULT].
+func (c *Config) Secure() bool {
+
+	if c.Main.IsSecure != nil {
+		return *c.Main.IsSecure
+	}
+
+	return c.Default.IsSecure
+}
+
+// validate reports every missing or inconsistent setting at once.
+func (c *Config) validate() error {
+
+	var problems []string
+
+	if !c.UseEmbedded() {
+		if c.Main.Endpoint == "" {
+			problems = append(problems, "s3main.endpoint is not set")
+		}
+		if c.Main.AccessKey == "" || c.Main.AccessSecret == "" {
+			problems = append(problems, "s3main.access_key and s3main.access_secret are required")
+		}
+	}
+
+	for _, user := range []struct {
+		section string
+		UserConfig
+	}{{"s3alt", c.Alt}, {"tenant", c.Tenant}} {
+		if section := user.section; (user.AccessKey == "") != (user.AccessSecret == "") {
+			problems = append(problems, fmt.Sprintf("%s.access_key and %s.access_secret must be set together", section, section))
+		}
+	}
+
+	if (c.Default.ClientCert == "") != (c.Default.ClientKey == "") {
+		problems = append(problems, "default.client_cert and default.client_key must be set together")
+	}
+
+	if c.Default.LCDebugInterval < 0 {
+		problems = append(problems, "default.lc_debug_interval must not be negative")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	source := c.File
+	if source == "" {
+		source = "no config file"
+	}
+
+	return fmt.Errorf("invalid config (%s): %s", source, strings.Join(problems, "; "))
+}
@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// unsetConfigEnv unsets every variable LoadConfig reads until the test is
+// over, so that none of the caller's environment leaks into the test. They
+// must be unset rather than emptied: an empty variable still overrides the
+// config file.
+func unsetConfigEnv(t *testing.T) {
+
+	names := []string{"S3TEST_CONF"}
+	walkKeys(&Config{}, func(key string, field reflect.Value) {
+		names = append(names, envName(key))
+	})
+	for _, name := range envAliases {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok {
+			name := name
+			os.Unsetenv(name)
+			t.Cleanup(func() { os.Setenv(name, v) })
+		}
+	}
+}
+
+// withConfigFile loads a config file holding contents, with env set on top
+// of it and no other S3TEST_* variable. Once the test is over, viper is
+// reset and the suite's own configuration is reloaded, so later tests see
+// none of the test's values.
+func withConfigFile(t *testing.T, contents string, env map[string]string) error {
+
+	saved := config
+
+	// Registered first, so it runs after the environment has been
+	// restored.
+	t.Cleanup(func() {
+		viper.Reset()
+		config = nil
+		if saved == nil {
+			return
+		}
+		if err := LoadConfig(); err != nil {
+			t.Errorf("failed to reload config, %v", err)
+			config = saved
+		}
+	})
+
+	unsetConfigEnv(t)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("S3TEST_CONF", path)
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	return LoadConfig()
+}
+
+func TestLoadConfigSources(t *testing.T) {
+
+	assert := assert.New(t)
+
+	err := withConfigFile(t, `
+[DEFAULT]
+is_secure = "yes"
+include_tags = ["bucket", "acl"]
+lc_debug_interval = 10
+
+[fixtures]
+bucket_prefix = "gateway"
+
+[s3main]
+access_key = "main-key"
+access_secret = "main-secret"
+region = "eu-west-1"
+endpoint = "gateway:8000"
+
+[s3alt]
+access_key = "alt-key"
+access_secret = "alt-secret"
+`, map[string]string{
+		"S3TEST_S3MAIN_ENDPOINT": "other:9000",
+		"S3TEST_TAGS":            "object, list",
+	})
+	assert.Nil(err)
+
+	conf := GetConfig()
+	assert.Equal("main-key", conf.Main.AccessKey)
+	assert.Equal("other:9000", conf.Main.Endpoint)
+	assert.Equal("eu-west-1", conf.Alt.Region)
+	assert.Equal("gateway", conf.Fixtures.BucketPrefix)
+	assert.Equal([]string{"object", "list"}, conf.Default.IncludeTags)
+	assert.Equal(10, conf.Default.LCDebugInterval)
+	assert.Equal(false, conf.UseEmbedded())
+	assert.Equal(true, conf.Secure())
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+
+	assert := assert.New(t)
+
+	err := withConfigFile(t, `
+[DEFAULT]
+embedded = false
+client_cert = "client.pem"
+lc_debug_interval = -1
+
+[s3alt]
+access_key = "alt-key"
+`, map[string]string{})
+
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "s3main.endpoint is not set")
+	assert.Contains(err.Error(), "s3main.access_key and s3main.access_secret are required")
+	assert.Contains(err.Error(), "s3alt.access_key and s3alt.access_secret must be set together")
+	assert.Contains(err.Error(), "default.client_cert and default.client_key must be set together")
+	assert.Contains(err.Error(), "default.lc_debug_interval must not be negative")
+}
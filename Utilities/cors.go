@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// CORSRule returns a rule allowing requests with methods from origins.
+func CORSRule(origins []string, methods []string) *s3.CORSRule {
+
+	return &s3.CORSRule{
+		AllowedOrigins: aws.StringSlice(origins),
+		AllowedMethods: aws.StringSlice(methods),
+	}
+}
+
+func PutBucketCors(svc *s3.S3, bucket string, rules ...*s3.CORSRule) error {
+
+	_, err := svc.PutBucketCors(&s3.PutBucketCorsInput{
+		Bucket:            aws.String(bucket),
+		CORSConfiguration: &s3.CORSConfiguration{CORSRules: rules},
+	})
+
+	return err
+}
+
+func GetBucketCors(svc *s3.S3, bucket string) ([]*s3.CORSRule, error) {
+
+	resp, err := svc.GetBucketCors(&s3.GetBucketCorsInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.CORSRules, nil
+}
+
+func DeleteBucketCors(svc *s3.S3, bucket string) error {
+
+	_, err := svc.DeleteBucketCors(&s3.DeleteBucketCorsInput{
+		Bucket: aws.String(bucket),
+	})
+
+	return err
+}
+
+// ObjectURL returns the URL of key in bucket, or of the bucket when key is
+// empty, addressed path or host style as the suite's requests are.
+func ObjectURL(bucket string, key string) (*url.URL, error) {
+
+	connect()
+
+	req, _ := svc.HeadBucketRequest(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if key != "" {
+		req, _ = svc.HeadObjectRequest(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	}
+
+	if err := req.Build(); err != nil {
+		return nil, err
+	}
+
+	return req.HTTPRequest.URL, nil
+}
+
+// SendCORSRequest sends an unsigned request to key in bucket, or to the
+// bucket when key is empty, the way a browser would, with headers such as
+// Origin added. The body of the response is drained and closed.
+func SendCORSRequest(method string, bucket string, key string, headers map[string]string) (*http.Response, error) {
+
+	u, err := ObjectURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := SetupRawRequest(u.Scheme, method, u.Host+u.EscapedPath(), "")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	return resp, nil
+}
+
+// Preflight sends the OPTIONS request a browser makes before a
+// cross-origin request with method from origin, asking to send headers.
+// An empty origin or method is left out.
+func Preflight(bucket string, key string, origin string, method string, headers ...string) (*http.Response, error) {
+
+	h := make(map[string]string)
+	if origin != "" {
+		h["Origin"] = origin
+	}
+	if method != "" {
+		h["Access-Control-Request-Method"] = method
+	}
+	if len(headers) > 0 {
+		h["Access-Control-Request-Headers"] = strings.Join(headers, ", ")
+	}
+
+	return SendCORSRequest("OPTIONS", bucket, key, h)
+}
@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+
+	"../s3server"
+)
+
+var embedded *s3server.Server
+
+// embeddedWebsiteDomain is the domain the reference server serves buckets
+// as websites under, on the same port as the S3 endpoint.
+const embeddedWebsiteDomain = "s3-website.localhost"
+
+// embeddedCert is the self-signed certificate of the reference server when
+// it serves HTTPS.
+var embeddedCert *x509.Certificate
+
+// UseEmbeddedServer reports whether the suite runs against the bundled
+// reference server instead of a gateway. S3TEST_EMBEDDED takes precedence
+// over the `embedded` key of [DEFAULT]; without either, the reference server
+// is used when no s3main endpoint is configured.
+func UseEmbeddedServer() bool {
+
+	return GetConfig().UseEmbedded()
+}
+
+// StartEmbeddedServer starts the reference server on an ephemeral port for
+// the s3main and s3alt credentials and returns its endpoint. The endpoint
+// is named localhost so that host-style requests can address buckets as
+// subdomains of it. When the suite uses SSL the server serves HTTPS with a
+// self-signed certificate that the suite's clients trust.
+func StartEmbeddedServer() (string, error) {
+
+	conf := GetConfig()
+
+	var tlsConfig *tls.Config
+
+	if UseSSL() {
+		cert, err := s3server.SelfSignedCertificate("localhost", "*.localhost", "*."+embeddedWebsiteDomain, "127.0.0.1")
+		if err != nil {
+			return "", err
+		}
+		embeddedCert = cert.Leaf
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	embedded = s3server.New(s3server.Config{
+		Region:                 conf.Main.Region,
+		Domain:                 "localhost",
+		WebsiteDomain:          embeddedWebsiteDomain,
+		TLSConfig:              tlsConfig,
+		LifecycleDebugInterval: LifecycleDay(),
+		Users: []s3server.User{{
+			AccessKey:   conf.Main.AccessKey,
+			SecretKey:   conf.Main.AccessSecret,
+			DisplayName: conf.Main.DisplayName,
+			Email:       conf.Main.Email,
+		}, {
+			AccessKey:   conf.Alt.AccessKey,
+			SecretKey:   conf.Alt.AccessSecret,
+			DisplayName: conf.Alt.DisplayName,
+			Email:       conf.Alt.Email,
+		}},
+	})
+
+	addr, err := embedded.Start()
+	if err != nil {
+		return "", err
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+
+	return net.JoinHostPort("localhost", port), err
+}
+
+// Endpoint returns the endpoint the suite talks to, starting the reference
+// server first when it is in use.
+func Endpoint() (string, error) {
+
+	if !UseEmbeddedServer() {
+		return GetConfig().Main.Endpoint, nil
+	}
+
+	endpoint, err := StartEmbeddedServer()
+	if err != nil {
+		return "", fmt.Errorf("failed to start embedded server, %v", err)
+	}
+
+	return endpoint, nil
+}
@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// UseHostStyle reports whether requests address buckets virtual-hosted
+// style, as bucket.endpoint, rather than as the first path segment.
+// S3TEST_HOST_STYLE takes precedence over `host_style` under [DEFAULT].
+func UseHostStyle() bool {
+
+	return GetConfig().Default.HostStyle
+}
+
+// endpointHost returns the host name of the configured endpoint.
+func endpointHost() string {
+
+	host := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return host
+}
+
+// ResolveToEndpoint reports whether bucket subdomains of the endpoint and of
+// the website endpoint are dialed at those endpoints directly, so that no
+// wildcard DNS record is needed. It is always the case for the reference
+// server. Against a gateway the names go through DNS, as clients' would,
+// unless S3TEST_RESOLVE_TO_ENDPOINT or `resolve_to_endpoint` under
+// [DEFAULT] is set.
+func ResolveToEndpoint() bool {
+
+	return UseEmbeddedServer() || GetConfig().Default.ResolveToEndpoint
+}
+
+// newHTTPClient returns the HTTP client shared by the suite's S3 clients.
+// Host-style requests go to bucket.endpoint, and website requests to
+// bucket.website_endpoint; when ResolveToEndpoint holds, its dialer sends
+// them to the endpoint itself.
+func newHTTPClient() (*http.Client, error) {
+
+	tlsConfig, err := clientTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up TLS, %v", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if !ResolveToEndpoint() {
+		return &http.Client{Transport: transport}, nil
+	}
+
+	hosts := []string{endpointHost()}
+	if website := GetConfig().Default.WebsiteEndpoint; website != "" {
+		if h, _, err := net.SplitHostPort(website); err == nil {
+			website = h
+		}
+		hosts = append(hosts, website)
+	}
+
+	dialer := &net.Dialer{}
+
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+
+		if h, port, err := net.SplitHostPort(addr); err == nil {
+			for _, host := range hosts {
+				if strings.HasSuffix(h, "."+host) {
+					addr = net.JoinHostPort(host, port)
+					break
+				}
+			}
+		}
+
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// GetConnWithHostStyle returns a client for the s3main user that uses the
+// given addressing style regardless of the suite-wide setting. The SDK
+// falls back to path style for bucket names that are not DNS compatible.
+func GetConnWithHostStyle(hostStyle bool) *s3.S3 {
+
+	connect()
+	return s3.New(sess, cfg.Copy().WithS3ForcePathStyle(!hostStyle))
+}
+
+// GetDNSBucketName returns a bucket name that is a valid DNS label, which
+// host-style requests require.
+func GetDNSBucketName() string {
+
+	return strings.ToLower(GetBucketName())
+}
+
+// IsHostStyleRequest reports whether an SDK request addressed its bucket
+// through the Host header.
+func IsHostStyleRequest(svc *s3.S3, bucket string) bool {
+
+	req, _ := svc.HeadBucketRequest(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err := req.Build(); err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(req.HTTPRequest.URL.Host, bucket+".")
+}
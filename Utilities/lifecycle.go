@@ -0,0 +1,155 @@
+package helpers
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// MaxLifecycleRules is the most rules a lifecycle configuration may hold.
+const MaxLifecycleRules = 1000
+
+// WithContentMD5 sets the Content-MD5 header of a request to the MD5 of
+// its body once the body is built, whether or not the SDK would have.
+func WithContentMD5() request.Option {
+
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			if r.Error != nil {
+				return
+			}
+
+			h := md5.New()
+			if _, err := aws.CopySeekableBody(h, r.Body); err != nil {
+				r.Error = err
+				return
+			}
+
+			r.HTTPRequest.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		})
+	}
+}
+
+// PrefixFilter selects the keys with a prefix.
+func PrefixFilter(prefix string) *s3.LifecycleRuleFilter {
+
+	return &s3.LifecycleRuleFilter{Prefix: aws.String(prefix)}
+}
+
+// TagFilter selects the objects carrying a tag.
+func TagFilter(key string, value string) *s3.LifecycleRuleFilter {
+
+	return &s3.LifecycleRuleFilter{Tag: &s3.Tag{Key: aws.String(key), Value: aws.String(value)}}
+}
+
+// AndFilter selects the keys with a prefix whose objects carry every one
+// of tags.
+func AndFilter(prefix string, tags map[string]string) *s3.LifecycleRuleFilter {
+
+	and := &s3.LifecycleRuleAndOperator{Tags: TagSet(tags)}
+	if prefix != "" {
+		and.Prefix = aws.String(prefix)
+	}
+
+	return &s3.LifecycleRuleFilter{And: and}
+}
+
+// ExpirationRule returns an enabled rule expiring the objects filter
+// selects after days.
+func ExpirationRule(id string, filter *s3.LifecycleRuleFilter, days int64) *s3.LifecycleRule {
+
+	return &s3.LifecycleRule{
+		ID:         aws.String(id),
+		Filter:     filter,
+		Status:     aws.String(s3.ExpirationStatusEnabled),
+		Expiration: &s3.LifecycleExpiration{Days: aws.Int64(days)},
+	}
+}
+
+// ExpirationDateRule returns an enabled rule expiring the objects filter
+// selects on date, which S3 requires to be midnight UTC.
+func ExpirationDateRule(id string, filter *s3.LifecycleRuleFilter, date time.Time) *s3.LifecycleRule {
+
+	return &s3.LifecycleRule{
+		ID:         aws.String(id),
+		Filter:     filter,
+		Status:     aws.String(s3.ExpirationStatusEnabled),
+		Expiration: &s3.LifecycleExpiration{Date: aws.Time(date)},
+	}
+}
+
+// PutLifecycleRules replaces the lifecycle configuration of a bucket with
+// rules, sending the Content-MD5 S3 requires.
+func PutLifecycleRules(svc *s3.S3, bucket string, rules ...*s3.LifecycleRule) error {
+
+	_, err := svc.PutBucketLifecycleConfigurationWithContext(aws.BackgroundContext(), &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+	}, WithContentMD5())
+
+	return err
+}
+
+func GetLifecycleRules(svc *s3.S3, bucket string) ([]*s3.LifecycleRule, error) {
+
+	resp, err := svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Rules, nil
+}
+
+func DeleteLifecycle(svc *s3.S3, bucket string) error {
+
+	_, err := svc.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{
+		Bucket: aws.String(bucket),
+	})
+
+	return err
+}
+
+// LifecycleDay returns how long a lifecycle day lasts on the gateway, the
+// lc_debug_interval of [DEFAULT], or 0 when the effect of a rule cannot be
+// watched within a run.
+func LifecycleDay() time.Duration {
+
+	return time.Duration(GetConfig().Default.LCDebugInterval) * time.Second
+}
+
+// WaitFor calls cond every poll until it reports true, fails, or timeout
+// passes. It returns the error of cond, or one saying the wait timed out.
+func WaitFor(timeout time.Duration, poll time.Duration, cond func() (bool, error)) error {
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		done, err := cond()
+		if err != nil || done {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("condition not met after %v", timeout)
+		}
+
+		time.Sleep(poll)
+	}
+}
+
+// WaitForLifecycle waits for cond as long as a rule acting after days
+// takes to be applied: days lifecycle days, plus three more for the
+// gateway's processing cycle to come round.
+func WaitForLifecycle(days int, cond func() (bool, error)) error {
+
+	day := LifecycleDay()
+
+	return WaitFor(time.Duration(days+3)*day, day/4, cond)
+}
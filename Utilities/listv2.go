@@ -0,0 +1,157 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ListV2Options are the parameters of a ListObjectsV2 request. Zero values
+// are left out of the request.
+type ListV2Options struct {
+	Prefix            string
+	Delimiter         string
+	StartAfter        string
+	ContinuationToken string
+	MaxKeys           int64
+	FetchOwner        bool
+
+	// URLEncoding asks for keys and prefixes to be URL encoded in the
+	// response. The keys and prefixes the helpers return are decoded.
+	URLEncoding bool
+}
+
+func (o ListV2Options) input(bucket string) *s3.ListObjectsV2Input {
+
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucket)}
+
+	if o.Prefix != "" {
+		input.Prefix = aws.String(o.Prefix)
+	}
+	if o.Delimiter != "" {
+		input.Delimiter = aws.String(o.Delimiter)
+	}
+	if o.StartAfter != "" {
+		input.StartAfter = aws.String(o.StartAfter)
+	}
+	if o.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(o.ContinuationToken)
+	}
+	if o.MaxKeys != 0 {
+		input.MaxKeys = aws.Int64(o.MaxKeys)
+	}
+	if o.FetchOwner {
+		input.FetchOwner = aws.Bool(true)
+	}
+	if o.URLEncoding {
+		input.EncodingType = aws.String(s3.EncodingTypeUrl)
+	}
+
+	return input
+}
+
+// ListObjectsV2Page lists one page of a bucket with ListObjectsV2 and
+// returns its keys and common prefixes.
+func ListObjectsV2Page(svc *s3.S3, bucket string, opts ListV2Options) (*s3.ListObjectsV2Output, []string, []string, error) {
+
+	keys := []string{}
+	prefixes := []string{}
+
+	resp, err := svc.ListObjectsV2(opts.input(bucket))
+	if err != nil {
+		return resp, keys, prefixes, err
+	}
+
+	decode := func(s string) (string, error) { return s, nil }
+	if aws.StringValue(resp.EncodingType) == s3.EncodingTypeUrl {
+		decode = url.QueryUnescape
+	}
+
+	for _, o := range resp.Contents {
+		key, err := decode(aws.StringValue(o.Key))
+		if err != nil {
+			return resp, keys, prefixes, err
+		}
+		keys = append(keys, key)
+	}
+
+	for _, p := range resp.CommonPrefixes {
+		prefix, err := decode(aws.StringValue(p.Prefix))
+		if err != nil {
+			return resp, keys, prefixes, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return resp, keys, prefixes, nil
+}
+
+// ListAllObjectsV2 follows continuation tokens until the listing is done
+// and returns every key and common prefix with the number of pages. It
+// fails if an entry is returned twice, if entries come out of order, or
+// if a page is inconsistent: more entries than max-keys, a KeyCount that
+// does not match, or a truncated page without a continuation token or with
+// one it was given already, which would list the same pages forever.
+func ListAllObjectsV2(svc *s3.S3, bucket string, opts ListV2Options) ([]string, []string, int, error) {
+
+	keys := []string{}
+	prefixes := []string{}
+	seen := make(map[string]bool)
+	tokens := make(map[string]bool)
+	last := ""
+	pages := 0
+
+	for {
+		resp, pageKeys, pagePrefixes, err := ListObjectsV2Page(svc, bucket, opts)
+		if err != nil {
+			return keys, prefixes, pages, err
+		}
+		pages++
+
+		count := len(pageKeys) + len(pagePrefixes)
+		if n := aws.Int64Value(resp.KeyCount); n != int64(count) {
+			return keys, prefixes, pages, fmt.Errorf("page %d has KeyCount %d but %d entries", pages, n, count)
+		}
+		if opts.MaxKeys > 0 && int64(count) > opts.MaxKeys {
+			return keys, prefixes, pages, fmt.Errorf("page %d has %d entries, more than max-keys %d", pages, count, opts.MaxKeys)
+		}
+
+		// Entries of a page must sort after every entry of the pages
+		// before it; keys and prefixes interleave within a page.
+		previous := last
+		for _, page := range [][]string{pageKeys, pagePrefixes} {
+			for _, entry := range page {
+				if seen[entry] {
+					return keys, prefixes, pages, fmt.Errorf("%q returned twice, again on page %d", entry, pages)
+				}
+				if entry <= previous {
+					return keys, prefixes, pages, fmt.Errorf("%q on page %d does not sort after %q from an earlier page", entry, pages, previous)
+				}
+				seen[entry] = true
+				if entry > last {
+					last = entry
+				}
+			}
+		}
+
+		keys = append(keys, pageKeys...)
+		prefixes = append(prefixes, pagePrefixes...)
+
+		if !aws.BoolValue(resp.IsTruncated) {
+			return keys, prefixes, pages, nil
+		}
+
+		token := aws.StringValue(resp.NextContinuationToken)
+		if token == "" {
+			return keys, prefixes, pages, fmt.Errorf("page %d is truncated but has no NextContinuationToken", pages)
+		}
+		if tokens[token] {
+			return keys, prefixes, pages, fmt.Errorf("page %d returns NextContinuationToken %q again", pages, token)
+		}
+		tokens[token] = true
+
+		opts.ContinuationToken = token
+	}
+}
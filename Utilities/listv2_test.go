@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListAllObjectsV2RepeatedToken checks that a gateway which keeps
+// answering with an empty, truncated page and the same continuation token
+// fails the listing instead of looping forever.
+func TestListAllObjectsV2RepeatedToken(t *testing.T) {
+
+	assert := assert.New(t)
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<ListBucketResult><Name>bucket</Name><KeyCount>0</KeyCount>`+
+			`<MaxKeys>1000</MaxKeys><IsTruncated>true</IsTruncated>`+
+			`<NextContinuationToken>again</NextContinuationToken></ListBucketResult>`)
+	}))
+	defer ts.Close()
+
+	svc := s3.New(session.Must(session.NewSession()), &aws.Config{
+		Credentials:      credentials.NewStaticCredentials("AKID", "secret", ""),
+		Endpoint:         aws.String(ts.URL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+		DisableSSL:       aws.Bool(true),
+	})
+
+	keys, _, pages, err := ListAllObjectsV2(svc, "bucket", ListV2Options{})
+	assert.EqualError(err, `page 2 returns NextContinuationToken "again" again`)
+	assert.Empty(keys)
+	assert.Equal(2, pages)
+	assert.Equal(2, requests)
+}
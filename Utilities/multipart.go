@@ -0,0 +1,273 @@
+package helpers
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// MinPartSize is the smallest size S3 allows for every part of a multipart
+// upload but the last.
+const MinPartSize = 5 * 1024 * 1024
+
+// MultipartUpload is an upload started by UploadParts, with the parts
+// uploaded to it and their contents.
+type MultipartUpload struct {
+	Bucket   string
+	Key      string
+	UploadID string
+
+	// Parts are the uploaded parts in part number order, ready to complete
+	// the upload with.
+	Parts []*s3.CompletedPart
+
+	// Data holds the contents of each part, in the order of Parts.
+	Data []string
+}
+
+// Content returns the contents the object will have once the upload is
+// completed with all of its parts.
+func (u *MultipartUpload) Content() string {
+
+	return strings.Join(u.Data, "")
+}
+
+// ETag returns the ETag the object will have once the upload is completed
+// with all of its parts: the MD5 of the part MD5s, followed by the number
+// of parts.
+func (u *MultipartUpload) ETag() string {
+
+	return MultipartETag(u.Data...)
+}
+
+// MultipartETag returns the ETag of an object uploaded in parts with the
+// given contents.
+func MultipartETag(parts ...string) string {
+
+	sums := md5.New()
+	for _, p := range parts {
+		sum := md5.Sum([]byte(p))
+		sums.Write(sum[:])
+	}
+
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sums.Sum(nil)), len(parts))
+}
+
+// UploadParts starts a multipart upload and uploads a part of random
+// contents for each of sizes, numbered from 1, with up to concurrency
+// parts in flight at once. The upload is left for the caller to complete
+// or abort.
+func UploadParts(svc *s3.S3, bucket string, key string, sizes []int, concurrency int) (*MultipartUpload, error) {
+
+	result, err := InitiateMultipartUpload(svc, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &MultipartUpload{
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: aws.StringValue(result.UploadId),
+		Parts:    make([]*s3.CompletedPart, len(sizes)),
+		Data:     make([]string, len(sizes)),
+	}
+
+	for i, size := range sizes {
+		u.Data[i] = String(size)
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(sizes))
+	slots := make(chan struct{}, concurrency)
+
+	for i := range sizes {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(i int) {
+			defer func() { <-slots; wg.Done() }()
+
+			u.Parts[i], errs[i] = u.UploadPart(svc, int64(i+1), u.Data[i])
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return u, err
+		}
+	}
+
+	return u, nil
+}
+
+// UploadPart uploads content as part number n of the upload, replacing
+// any part uploaded with that number before.
+func (u *MultipartUpload) UploadPart(svc *s3.S3, n int64, content string) (*s3.CompletedPart, error) {
+
+	resp, err := Uploadpart(svc, u.Bucket, u.Key, u.UploadID, content, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3.CompletedPart{ETag: resp.ETag, PartNumber: aws.Int64(n)}, nil
+}
+
+// Complete completes the upload with parts, which are sent as given so
+// that tests can send them out of order or repeated.
+func (u *MultipartUpload) Complete(svc *s3.S3, parts ...*s3.CompletedPart) (*s3.CompleteMultipartUploadOutput, error) {
+
+	return svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(u.Bucket),
+		Key:             aws.String(u.Key),
+		UploadId:        aws.String(u.UploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+}
+
+// UploadMultipart uploads an object in parts of the given sizes and
+// completes the upload with all of them.
+func UploadMultipart(svc *s3.S3, bucket string, key string, sizes []int, concurrency int) (*MultipartUpload, *s3.CompleteMultipartUploadOutput, error) {
+
+	u, err := UploadParts(svc, bucket, key, sizes, concurrency)
+	if err != nil {
+		return u, nil, err
+	}
+
+	resp, err := u.Complete(svc, u.Parts...)
+
+	return u, resp, err
+}
+
+// ListUploadsOptions are the parameters of a ListMultipartUploads request.
+// Zero values are left out of the request.
+type ListUploadsOptions struct {
+	Prefix         string
+	Delimiter      string
+	KeyMarker      string
+	UploadIDMarker string
+	MaxUploads     int64
+}
+
+func (o ListUploadsOptions) input(bucket string) *s3.ListMultipartUploadsInput {
+
+	input := &s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)}
+
+	if o.Prefix != "" {
+		input.Prefix = aws.String(o.Prefix)
+	}
+	if o.Delimiter != "" {
+		input.Delimiter = aws.String(o.Delimiter)
+	}
+	if o.KeyMarker != "" {
+		input.KeyMarker = aws.String(o.KeyMarker)
+	}
+	if o.UploadIDMarker != "" {
+		input.UploadIdMarker = aws.String(o.UploadIDMarker)
+	}
+	if o.MaxUploads != 0 {
+		input.MaxUploads = aws.Int64(o.MaxUploads)
+	}
+
+	return input
+}
+
+// ListMultipartUploadsPage lists one page of the uploads in progress in a
+// bucket.
+func ListMultipartUploadsPage(svc *s3.S3, bucket string, opts ListUploadsOptions) (*s3.ListMultipartUploadsOutput, error) {
+
+	return svc.ListMultipartUploads(opts.input(bucket))
+}
+
+// ListAllMultipartUploads follows the key and upload ID markers until the
+// listing is done and returns every upload and common prefix with the
+// number of pages. It fails if an upload is returned twice, if a page has
+// more entries than max-uploads, or if a truncated page has no markers to
+// continue from.
+func ListAllMultipartUploads(svc *s3.S3, bucket string, opts ListUploadsOptions) ([]*s3.MultipartUpload, []string, int, error) {
+
+	var uploads []*s3.MultipartUpload
+	prefixes := []string{}
+	seen := make(map[string]bool)
+	pages := 0
+
+	for {
+		resp, err := ListMultipartUploadsPage(svc, bucket, opts)
+		if err != nil {
+			return uploads, prefixes, pages, err
+		}
+		pages++
+
+		count := len(resp.Uploads) + len(resp.CommonPrefixes)
+		if opts.MaxUploads > 0 && int64(count) > opts.MaxUploads {
+			return uploads, prefixes, pages, fmt.Errorf("page %d has %d entries, more than max-uploads %d", pages, count, opts.MaxUploads)
+		}
+
+		for _, u := range resp.Uploads {
+			id := aws.StringValue(u.UploadId)
+			if seen[id] {
+				return uploads, prefixes, pages, fmt.Errorf("upload %q of %q returned twice, again on page %d", id, aws.StringValue(u.Key), pages)
+			}
+			seen[id] = true
+			uploads = append(uploads, u)
+		}
+
+		for _, p := range resp.CommonPrefixes {
+			prefix := aws.StringValue(p.Prefix)
+			if seen[prefix] {
+				return uploads, prefixes, pages, fmt.Errorf("prefix %q returned twice, again on page %d", prefix, pages)
+			}
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+
+		if !aws.BoolValue(resp.IsTruncated) {
+			return uploads, prefixes, pages, nil
+		}
+
+		if aws.StringValue(resp.NextKeyMarker) == "" {
+			return uploads, prefixes, pages, fmt.Errorf("page %d is truncated but has no NextKeyMarker", pages)
+		}
+
+		opts.KeyMarker = aws.StringValue(resp.NextKeyMarker)
+		opts.UploadIDMarker = aws.StringValue(resp.NextUploadIdMarker)
+	}
+}
+
+// AbortMultipartUploads aborts every upload in progress in a bucket, so
+// that the bucket can be deleted and the parts stop taking up space.
+func AbortMultipartUploads(svc *s3.S3, bucket string) error {
+
+	uploads, _, _, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, u := range uploads {
+		_, err := AbortMultiPartUpload(svc, bucket, aws.StringValue(u.Key), aws.StringValue(u.UploadId))
+		if err != nil && !isNoSuchUpload(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isNoSuchUpload reports whether err is the error for an upload that has
+// already been completed or aborted.
+func isNoSuchUpload(err error) bool {
+
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == s3.ErrCodeNoSuchUpload
+}
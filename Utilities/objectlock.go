@@ -0,0 +1,234 @@
+package helpers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// maxComplianceWait bounds how long DeleteLockedObjectVersions waits for
+// compliance retention to end. Fixtures should use retention shorter than
+// this.
+const maxComplianceWait = time.Minute
+
+// CreateObjectLockBucket creates a bucket with Object Lock enabled, which
+// enables versioning on it as well.
+func CreateObjectLockBucket(svc *s3.S3, bucket string) error {
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket:                     aws.String(bucket),
+		ObjectLockEnabledForBucket: aws.Bool(true),
+	})
+
+	return err
+}
+
+// ObjectLockConfig returns an Object Lock configuration giving new versions
+// a default retention of days in mode.
+func ObjectLockConfig(mode string, days int) *s3.ObjectLockConfiguration {
+
+	return &s3.ObjectLockConfiguration{
+		ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled),
+		Rule: &s3.ObjectLockRule{
+			DefaultRetention: &s3.DefaultRetention{
+				Mode: aws.String(mode),
+				Days: aws.Int64(int64(days)),
+			},
+		},
+	}
+}
+
+func PutObjectLockConfiguration(svc *s3.S3, bucket string, config *s3.ObjectLockConfiguration) error {
+
+	_, err := svc.PutObjectLockConfiguration(&s3.PutObjectLockConfigurationInput{
+		Bucket:                  aws.String(bucket),
+		ObjectLockConfiguration: config,
+	})
+
+	return err
+}
+
+func GetObjectLockConfiguration(svc *s3.S3, bucket string) (*s3.ObjectLockConfiguration, error) {
+
+	resp, err := svc.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.ObjectLockConfiguration, nil
+}
+
+// ObjectLockEnabled reports whether a bucket was created with Object Lock
+// enabled.
+func ObjectLockEnabled(svc *s3.S3, bucket string) bool {
+
+	config, err := GetObjectLockConfiguration(svc, bucket)
+
+	return err == nil && aws.StringValue(config.ObjectLockEnabled) == s3.ObjectLockEnabledEnabled
+}
+
+// PutObjectRetention sets the retention of a version of key, the current
+// one when versionID is empty. bypass sends x-amz-bypass-governance-retention,
+// which governance retention must be shortened with.
+func PutObjectRetention(svc *s3.S3, bucket string, key string, versionID string, mode string, until time.Time, bypass bool) error {
+
+	input := &s3.PutObjectRetentionInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Retention: &s3.ObjectLockRetention{
+			Mode:            aws.String(mode),
+			RetainUntilDate: aws.Time(until),
+		},
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	if bypass {
+		input.BypassGovernanceRetention = aws.Bool(true)
+	}
+
+	_, err := svc.PutObjectRetention(input)
+
+	return err
+}
+
+func GetObjectRetention(svc *s3.S3, bucket string, key string, versionID string) (*s3.ObjectLockRetention, error) {
+
+	input := &s3.GetObjectRetentionInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	resp, err := svc.GetObjectRetention(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Retention, nil
+}
+
+// PutObjectLegalHold turns the legal hold on a version of key ON or OFF,
+// the current version when versionID is empty.
+func PutObjectLegalHold(svc *s3.S3, bucket string, key string, versionID string, status string) error {
+
+	input := &s3.PutObjectLegalHoldInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(status)},
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	_, err := svc.PutObjectLegalHold(input)
+
+	return err
+}
+
+func GetObjectLegalHold(svc *s3.S3, bucket string, key string, versionID string) (string, error) {
+
+	input := &s3.GetObjectLegalHoldInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	resp, err := svc.GetObjectLegalHold(input)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(resp.LegalHold.Status), nil
+}
+
+// DeleteObjectVersionBypass deletes a version of key, bypassing its
+// governance retention.
+func DeleteObjectVersionBypass(svc *s3.S3, bucket string, key string, versionID string) error {
+
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket:                    aws.String(bucket),
+		Key:                       aws.String(key),
+		VersionId:                 aws.String(versionID),
+		BypassGovernanceRetention: aws.Bool(true),
+	})
+
+	return err
+}
+
+// DeleteLockedObjectVersions deletes every version and delete marker in a
+// bucket with Object Lock enabled. Legal holds are lifted and governance
+// retention is bypassed; compliance retention cannot be, so it is waited
+// out when it ends within maxComplianceWait, and reported otherwise.
+func DeleteLockedObjectVersions(svc *s3.S3, bucket string) error {
+
+	var objs []*s3.ObjectIdentifier
+	var retainedUntil time.Time
+
+	err := svc.ListObjectVersionsPages(&s3.ListObjectVersionsInput{Bucket: aws.String(bucket)},
+		func(page *s3.ListObjectVersionsOutput, last bool) bool {
+			for _, m := range page.DeleteMarkers {
+				objs = append(objs, &s3.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+			}
+
+			for _, v := range page.Versions {
+				key, id := aws.StringValue(v.Key), aws.StringValue(v.VersionId)
+				objs = append(objs, &s3.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+
+				if status, err := GetObjectLegalHold(svc, bucket, key, id); err == nil && status == s3.ObjectLockLegalHoldStatusOn {
+					PutObjectLegalHold(svc, bucket, key, id, s3.ObjectLockLegalHoldStatusOff)
+				}
+
+				r, err := GetObjectRetention(svc, bucket, key, id)
+				if err == nil && aws.StringValue(r.Mode) == s3.ObjectLockRetentionModeCompliance {
+					if until := aws.TimeValue(r.RetainUntilDate); until.After(retainedUntil) {
+						retainedUntil = until
+					}
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return err
+	}
+
+	if wait := time.Until(retainedUntil); wait > 0 {
+		if wait > maxComplianceWait {
+			return fmt.Errorf("compliance retention lasts until %v", retainedUntil)
+		}
+		// Leave the gateway a second of clock skew.
+		time.Sleep(wait + time.Second)
+	}
+
+	for len(objs) > 0 {
+		n := len(objs)
+		if n > 1000 {
+			n = 1000
+		}
+
+		resp, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket:                    aws.String(bucket),
+			Delete:                    &s3.Delete{Objects: objs[:n], Quiet: aws.Bool(true)},
+			BypassGovernanceRetention: aws.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Errors) > 0 {
+			e := resp.Errors[0]
+			return fmt.Errorf("failed to delete %s version %s, %s", aws.StringValue(e.Key), aws.StringValue(e.VersionId), aws.StringValue(e.Message))
+		}
+
+		objs = objs[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// PolicyStatement is one statement of a bucket policy. Principal, Action
+// and Resource take a string or a list, and Principal also a map such as
+// {"AWS": arn}, as the policy language allows.
+type PolicyStatement struct {
+	Sid       string `json:",omitempty"`
+	Effect    string
+	Principal interface{}
+	Action    interface{}
+	Resource  interface{}
+	Condition map[string]map[string]interface{} `json:",omitempty"`
+}
+
+// BucketPolicy returns the policy document made of statements.
+func BucketPolicy(statements ...PolicyStatement) string {
+
+	doc, err := json.Marshal(struct {
+		Version   string
+		Statement []PolicyStatement
+	}{"2012-10-17", statements})
+	if err != nil {
+		panic(err)
+	}
+
+	return string(doc)
+}
+
+// BucketARN returns the resource a policy names a bucket by.
+func BucketARN(bucket string) string {
+
+	return "arn:aws:s3:::" + bucket
+}
+
+// ObjectARN returns the resource a policy names a key, or with a wildcard
+// a set of keys, by.
+func ObjectARN(bucket string, key string) string {
+
+	return BucketARN(bucket) + "/" + key
+}
+
+// UserPrincipal returns the principal a policy names the user of svc by,
+// its canonical user ID.
+func UserPrincipal(svc *s3.S3) (map[string]string, error) {
+
+	id, err := GetUserID(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{"CanonicalUser": id}, nil
+}
+
+func PutBucketPolicy(svc *s3.S3, bucket string, policy string) (*s3.PutBucketPolicyOutput, error) {
+
+	return svc.PutBucketPolicy(&s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucket),
+		Policy: aws.String(policy),
+	})
+}
+
+func GetBucketPolicy(svc *s3.S3, bucket string) (string, error) {
+
+	resp, err := svc.GetBucketPolicy(&s3.GetBucketPolicyInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(resp.Policy), nil
+}
+
+func DeleteBucketPolicy(svc *s3.S3, bucket string) (*s3.DeleteBucketPolicyOutput, error) {
+
+	return svc.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{
+		Bucket: aws.String(bucket),
+	})
+}
@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// PostFilename is the file name POST uploads send their file under, which
+// a ${filename} in the key field is replaced with.
+const PostFilename = "upload.txt"
+
+// StartsWith returns a POST policy condition on the form field name
+// starting with prefix.
+func StartsWith(name string, prefix string) []interface{} {
+
+	return []interface{}{"starts-with", "$" + name, prefix}
+}
+
+// ContentLengthRange returns a POST policy condition on the size of the
+// uploaded file.
+func ContentLengthRange(min int, max int) []interface{} {
+
+	return []interface{}{"content-length-range", min, max}
+}
+
+// SignPostForm adds a policy and its SigV4 signature by the main user to
+// the fields of a POST upload to bucket. The policy expires at expiration
+// and holds conditions, matched exactly when they are fields given as a
+// map, together with exact matches on the bucket and the x-amz-algorithm,
+// x-amz-credential and x-amz-date fields it adds.
+func SignPostForm(fields map[string]string, bucket string, expiration time.Time, conditions ...interface{}) error {
+
+	connect()
+
+	creds, err := Creds.Get()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	region := GetConfig().Main.Region
+	scope := now.Format("20060102") + "/" + region + "/s3/aws4_request"
+
+	fields["x-amz-algorithm"] = "AWS4-HMAC-SHA256"
+	fields["x-amz-credential"] = creds.AccessKeyID + "/" + scope
+	fields["x-amz-date"] = now.Format("20060102T150405Z")
+
+	conditions = append(conditions,
+		map[string]string{"bucket": bucket},
+		map[string]string{"x-amz-algorithm": fields["x-amz-algorithm"]},
+		map[string]string{"x-amz-credential": fields["x-amz-credential"]},
+		map[string]string{"x-amz-date": fields["x-amz-date"]})
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": expiration.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return err
+	}
+
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+
+	key := signingKey(creds.SecretAccessKey, now.Format("20060102"), region, "s3")
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(key, fields["policy"]))
+
+	return nil
+}
+
+// PostObject sends the HTML form upload of content to bucket a browser
+// would: a multipart/form-data POST of fields, sorted by name, followed by
+// the file. It returns the response, without following redirects, and its
+// body.
+func PostObject(bucket string, fields map[string]string, content string) (*http.Response, string, error) {
+
+	u, err := ObjectURL(bucket, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		form.WriteField(name, fields[name])
+	}
+
+	file, _ := form.CreateFormFile("file", PostFilename)
+	file.Write([]byte(content))
+	form.Close()
+
+	req, err := http.NewRequest("POST", u.String(), &body)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := noRedirectClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	return resp, string(data), err
+}
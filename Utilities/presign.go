@@ -0,0 +1,201 @@
+package helpers
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// MaxPresignExpiry is the longest a SigV4 presigned URL may be valid for.
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// PresignedRequest is a presigned URL with the method it was signed for and
+// the headers that were signed along with it, which must be sent with it.
+type PresignedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+}
+
+// presign presigns an SDK request as if at signedAt, valid for expires from
+// then.
+func presign(r *request.Request, signedAt time.Time, expires time.Duration) (*PresignedRequest, error) {
+
+	r.Handlers.Sign.Swap(v4.SignRequestHandler.Name, request.NamedHandler{
+		Name: v4.SignRequestHandler.Name,
+		Fn: func(r *request.Request) {
+			v4.SignSDKRequestWithCurrentTime(r, func() time.Time { return signedAt })
+		},
+	})
+
+	u, signed, err := r.PresignRequest(expires)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signer keys the headers by their lowercased names.
+	header := make(http.Header)
+	for name, values := range signed {
+		for _, v := range values {
+			header.Add(name, v)
+		}
+	}
+
+	return &PresignedRequest{Method: r.HTTPRequest.Method, URL: u, Header: header}, nil
+}
+
+func PresignGetObject(svc *s3.S3, bucket string, key string, expires time.Duration) (*PresignedRequest, error) {
+
+	return PresignGetObjectAt(svc, bucket, key, time.Now(), expires)
+}
+
+// PresignGetObjectAt presigns a GET of key as if at signed, to check how
+// the gateway treats URLs past their expiry.
+func PresignGetObjectAt(svc *s3.S3, bucket string, key string, signed time.Time, expires time.Duration) (*PresignedRequest, error) {
+
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	return presign(req, signed, expires)
+}
+
+// PresignPutObject presigns a PUT of key. A non-empty contentType or
+// contentMD5 is signed along, so the upload must send the same header.
+func PresignPutObject(svc *s3.S3, bucket string, key string, contentType string, contentMD5 string, expires time.Duration) (*PresignedRequest, error) {
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if contentMD5 != "" {
+		input.ContentMD5 = aws.String(contentMD5)
+	}
+
+	req, _ := svc.PutObjectRequest(input)
+
+	return presign(req, time.Now(), expires)
+}
+
+func PresignHeadObject(svc *s3.S3, bucket string, key string, expires time.Duration) (*PresignedRequest, error) {
+
+	req, _ := svc.HeadObjectRequest(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	return presign(req, time.Now(), expires)
+}
+
+func PresignDeleteObject(svc *s3.S3, bucket string, key string, expires time.Duration) (*PresignedRequest, error) {
+
+	req, _ := svc.DeleteObjectRequest(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	return presign(req, time.Now(), expires)
+}
+
+func PresignUploadPart(svc *s3.S3, bucket string, key string, uploadID string, partNumber int, expires time.Duration) (*PresignedRequest, error) {
+
+	req, _ := svc.UploadPartRequest(&s3.UploadPartInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(int64(partNumber)),
+	})
+
+	return presign(req, time.Now(), expires)
+}
+
+// SendPresigned sends a presigned request with body the way a client
+// without credentials would, with the signed headers it came with. It
+// returns the response and its body.
+func SendPresigned(p *PresignedRequest, body string) (*http.Response, string, error) {
+
+	req, err := http.NewRequest(p.Method, p.URL, strings.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	for name, values := range p.Header {
+		req.Header[name] = values
+	}
+
+	return SendRequest(req)
+}
+
+// NewObjectRequest returns an unsigned request for key in bucket, or for
+// the bucket when key is empty, addressed path or host style as the
+// suite's requests are. query is added to the URL as is, so that
+// subresources like "acl" or "uploads" keep their bare form.
+func NewObjectRequest(method string, bucket string, key string, query string, body string) (*http.Request, error) {
+
+	u, err := ObjectURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	target := *u
+	target.RawQuery = query
+
+	return http.NewRequest(method, target.String(), strings.NewReader(body))
+}
+
+// SignRequest signs a raw request in its headers with SigV4 as the main
+// user, the way the suite's S3 clients do, with body as its payload.
+func SignRequest(req *http.Request, body string) error {
+
+	signer := v4.NewSigner(Creds, func(s *v4.Signer) { s.DisableURIPathEscaping = true })
+	_, err := signer.Sign(req, strings.NewReader(body), "s3", GetConfig().Main.Region, time.Now())
+
+	return err
+}
+
+// SendRequest sends a raw request with the suite's HTTP client. It returns
+// the response and its body.
+func SendRequest(req *http.Request) (*http.Response, string, error) {
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+
+	return resp, string(data), err
+}
+
+// ResponseError returns the S3 error a raw response carries as the SDK
+// would, so tests can check it like any other, or nil if the request
+// succeeded. Responses without a body, to HEAD requests, get a code made
+// from the status text.
+func ResponseError(resp *http.Response, body string) error {
+
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	var doc struct {
+		Code    string
+		Message string
+	}
+	if xml.Unmarshal([]byte(body), &doc) != nil || doc.Code == "" {
+		doc.Code = strings.Replace(http.StatusText(resp.StatusCode), " ", "", -1)
+	}
+
+	return awserr.NewRequestFailure(awserr.New(doc.Code, doc.Message, nil), resp.StatusCode, resp.Header.Get("x-amz-request-id"))
+}
@@ -0,0 +1,386 @@
+package helpers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Test outcomes in a compatibility report.
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// S3Error is an S3 error code and the HTTP status it came with. A client
+// side error has no HTTP status.
+type S3Error struct {
+	Code       string `json:"code"`
+	HTTPStatus int    `json:"http_status"`
+}
+
+func (e *S3Error) String() string {
+
+	if e == nil {
+		return "none"
+	}
+	if e.Code == "" {
+		return fmt.Sprintf("success (%d)", e.HTTPStatus)
+	}
+	return fmt.Sprintf("%s (%d)", e.Code, e.HTTPStatus)
+}
+
+// Expectation is an error a test declared through ExpectError, and the
+// error it was given.
+type Expectation struct {
+	Expected *S3Error `json:"expected"`
+	Observed *S3Error `json:"observed"`
+}
+
+func (e Expectation) met() bool {
+
+	return e.Observed != nil && *e.Observed == *e.Expected
+}
+
+// Result is the outcome of one test.
+//
+// Expectations lists every error the test declared through ExpectError, in
+// order. Expected and Observed sum them up: the first expectation that was
+// not met, or else the last one. For tests that declare none, Observed is
+// the last error response the gateway sent while the test body ran, if
+// any; responses to the teardown after it are not counted.
+type Result struct {
+	Suite        string        `json:"suite"`
+	Name         string        `json:"name"`
+	Tags         []string      `json:"tags"`
+	Status       string        `json:"status"`
+	SkipReason   string        `json:"skip_reason,omitempty"`
+	Expected     *S3Error      `json:"expected,omitempty"`
+	Observed     *S3Error      `json:"observed,omitempty"`
+	Expectations []Expectation `json:"expectations,omitempty"`
+	Started      time.Time     `json:"started"`
+	Duration     float64       `json:"duration_seconds"`
+
+	lastError *S3Error
+
+	// stopped is set once the test body is over.
+	stopped bool
+}
+
+// Report collects the results of a run.
+type Report struct {
+	Endpoint string    `json:"endpoint"`
+	Started  time.Time `json:"started"`
+	Results  []*Result `json:"results"`
+
+	mu      sync.Mutex
+	current *Result
+}
+
+var report = &Report{}
+
+// newSession returns the session the suite's clients are created from. It
+// records every response in the report.
+func newSession() *session.Session {
+
+	sess := session.Must(session.NewSession())
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{
+		Name: "s3tests.RecordResponse",
+		Fn:   report.record,
+	})
+
+	return sess
+}
+
+// StartTest begins the result of a test. Responses and expectations are
+// attributed to it until EndTest.
+func StartTest(suite, name string, tags []string) {
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	now := time.Now()
+	if report.Started.IsZero() {
+		report.Started = now
+		report.Endpoint = endpoint
+	}
+
+	report.current = &Result{Suite: suite, Name: name, Tags: tags, Status: StatusPass, Started: now}
+	report.Results = append(report.Results, report.current)
+}
+
+// StopClock ends the timing of the running test and the recording of its
+// responses, so that cleanup after it is counted in neither.
+func StopClock() {
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	if r := report.current; r != nil && !r.stopped {
+		r.Duration = time.Since(r.Started).Seconds()
+		r.stopped = true
+	}
+}
+
+// RecordSkip records why the running test is skipping itself. Tests call it
+// before t.Skip, since the testing package does not expose the message.
+func RecordSkip(reason string) {
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	if r := report.current; r != nil {
+		r.SkipReason = reason
+	}
+}
+
+// EndTest records the outcome of the running test. skipReason is only kept
+// for skipped tests, and only when RecordSkip gave none.
+func EndTest(status, skipReason string) {
+
+	StopClock()
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	r := report.current
+	if r == nil {
+		return
+	}
+
+	r.Status = status
+	if status != StatusSkip {
+		r.SkipReason = ""
+	} else if r.SkipReason == "" {
+		r.SkipReason = skipReason
+	}
+	if len(r.Expectations) == 0 {
+		r.Observed = r.lastError
+	}
+
+	report.current = nil
+}
+
+// ExpectError records that the running test expects err to be the S3 error
+// code with the HTTP status, and what err actually was.
+func ExpectError(err error, code string, status int) {
+
+	report.mu.Lock()
+	defer report.mu.Unlock()
+
+	r := report.current
+	if r == nil || r.stopped {
+		return
+	}
+
+	e := Expectation{Expected: &S3Error{Code: code, HTTPStatus: status}, Observed: s3ErrorOf(err)}
+	r.Expectations = append(r.Expectations, e)
+
+	// The summary keeps the first miss, so later expectations that were
+	// met do not hide it.
+	if r.Expected == nil || (Expectation{r.Expected, r.Observed}).met() {
+		r.Expected, r.Observed = e.Expected, e.Observed
+	}
+}
+
+// s3ErrorOf returns the code and status of err, or nil if there is none.
+func s3ErrorOf(err error) *S3Error {
+
+	if err == nil {
+		return nil
+	}
+
+	e := &S3Error{Code: "Unknown"}
+
+	if awsErr, ok := err.(awserr.Error); ok {
+		e.Code = awsErr.Code()
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		e.HTTPStatus = reqErr.StatusCode()
+	}
+
+	return e
+}
+
+func (rep *Report) record(r *request.Request) {
+
+	if r.Error == nil {
+		return
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	if rep.current != nil && !rep.current.stopped {
+		rep.current.lastError = s3ErrorOf(r.Error)
+	}
+}
+
+// WriteJSON writes the report as an indented JSON document.
+func (rep *Report) WriteJSON(w io.Writer) error {
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(rep)
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+type junitTestCase struct {
+	ClassName  string          `xml:"classname,attr"`
+	Name       string          `xml:"name,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// WriteJUnit writes the report in the JUnit XML format, one testsuite per
+// test suite. Tags and error codes are kept as testcase properties.
+func (rep *Report) WriteJUnit(w io.Writer) error {
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	var doc junitTestSuites
+	index := make(map[string]int)
+
+	for _, r := range rep.Results {
+		i, ok := index[r.Suite]
+		if !ok {
+			i = len(doc.Suites)
+			index[r.Suite] = i
+			doc.Suites = append(doc.Suites, junitTestSuite{
+				Name:       r.Suite,
+				Timestamp:  r.Started.UTC().Format("2006-01-02T15:04:05"),
+				Properties: []junitProperty{{"endpoint", rep.Endpoint}},
+			})
+		}
+		s := &doc.Suites[i]
+
+		tc := junitTestCase{
+			ClassName:  r.Suite,
+			Name:       r.Name,
+			Time:       fmt.Sprintf("%.3f", r.Duration),
+			Properties: []junitProperty{{"tags", strings.Join(r.Tags, ",")}},
+		}
+		if r.Expected != nil {
+			tc.Properties = append(tc.Properties, junitProperty{"expected", r.Expected.String()})
+		}
+		if r.Expected != nil || r.Observed != nil {
+			tc.Properties = append(tc.Properties, junitProperty{"observed", r.Observed.String()})
+		}
+
+		switch r.Status {
+		case StatusFail:
+			msg := "test failed"
+			if r.Expected != nil {
+				msg = fmt.Sprintf("expected %v, observed %v", r.Expected, r.Observed)
+			}
+			tc.Failure = &junitMessage{msg}
+			s.Failures++
+		case StatusSkip:
+			tc.Skipped = &junitMessage{r.SkipReason}
+			s.Skipped++
+		}
+
+		s.Tests++
+		s.TestCases = append(s.TestCases, tc)
+	}
+
+	for i := range doc.Suites {
+		var total float64
+		for _, r := range rep.Results {
+			if r.Suite == doc.Suites[i].Name {
+				total += r.Duration
+			}
+		}
+		doc.Suites[i].Time = fmt.Sprintf("%.3f", total)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteReport writes the report of the run to the files named by
+// S3TEST_REPORT_JSON and S3TEST_REPORT_JUNIT, falling back to `report_json`
+// and `report_junit` under [DEFAULT]. Either may be left unset.
+func WriteReport() error {
+
+	settings := GetConfig().Default
+
+	for _, out := range []struct {
+		path  string
+		write func(io.Writer) error
+	}{
+		{settings.ReportJSON, report.WriteJSON},
+		{settings.ReportJUnit, report.WriteJUnit},
+	} {
+		path := out.path
+		if path == "" {
+			continue
+		}
+
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+
+		err = out.write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+
+	assert := assert.New(t)
+
+	saved := report
+	report = &Report{}
+	defer func() { report = saved }()
+
+	StartTest("S3Suite", "TestDenied", []string{TagACL})
+	denied := awserr.NewRequestFailure(awserr.New("NoSuchKey", "", nil), 404, "")
+	ExpectError(denied, "AccessDenied", 403)
+	EndTest(StatusFail, "")
+
+	StartTest("S3Suite", "TestSkipped", []string{TagLifecycle})
+	EndTest(StatusSkip, "excluded")
+
+	// A test skipping itself keeps its own reason over the tag selection's.
+	StartTest("S3Suite", "TestSkippedItself", []string{TagTLS})
+	RecordSkip("requires is_secure")
+	EndTest(StatusSkip, "")
+
+	// The first missed expectation is kept over later ones that were met.
+	StartTest("S3Suite", "TestSeveral", []string{TagObject})
+	ExpectError(awserr.NewRequestFailure(awserr.New("NoSuchKey", "", nil), 404, ""), "NoSuchKey", 404)
+	ExpectError(nil, "AccessDenied", 403)
+	ExpectError(denied, "NoSuchKey", 404)
+	EndTest(StatusFail, "")
+
+	// Errors from the teardown, after the clock stops, are not observed.
+	StartTest("S3Suite", "TestTeardown", []string{TagBucket})
+	report.record(&request.Request{Error: denied})
+	StopClock()
+	report.record(&request.Request{Error: awserr.NewRequestFailure(awserr.New("BucketNotEmpty", "", nil), 409, "")})
+	EndTest(StatusPass, "")
+
+	var doc Report
+	var buf bytes.Buffer
+
+	assert.Nil(report.WriteJSON(&buf))
+	assert.Nil(json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(5, len(doc.Results))
+	assert.Equal(&S3Error{"AccessDenied", 403}, doc.Results[0].Expected)
+	assert.Equal(&S3Error{"NoSuchKey", 404}, doc.Results[0].Observed)
+	assert.Equal(StatusSkip, doc.Results[1].Status)
+	assert.Equal("excluded", doc.Results[1].SkipReason)
+	assert.Equal("requires is_secure", doc.Results[2].SkipReason)
+	assert.Equal(3, len(doc.Results[3].Expectations))
+	assert.Equal(&S3Error{"AccessDenied", 403}, doc.Results[3].Expected)
+	assert.Nil(doc.Results[3].Observed)
+	assert.Nil(doc.Results[4].Expected)
+	assert.Equal(&S3Error{"NoSuchKey", 404}, doc.Results[4].Observed)
+
+	var junit junitTestSuites
+	buf.Reset()
+
+	assert.Nil(report.WriteJUnit(&buf))
+	assert.Nil(xml.Unmarshal(buf.Bytes(), &junit))
+	assert.Equal(1, len(junit.Suites))
+	assert.Equal(5, junit.Suites[0].Tests)
+	assert.Equal(2, junit.Suites[0].Failures)
+	assert.Equal(2, junit.Suites[0].Skipped)
+	assert.Equal("requires is_secure", junit.Suites[0].TestCases[2].Skipped.Message)
+	assert.Equal("expected AccessDenied (403), observed NoSuchKey (404)", junit.Suites[0].TestCases[0].Failure.Message)
+}
@@ -13,48 +13,121 @@ import (
 	"bytes"
 	"golang.org/x/net/context"
 	"fmt"
-	"github.com/spf13/viper"
 	"io"
+	"strconv"
 	"strings"
+	"sync"
 	"os"
 	"time"
 	"net/http"
 )
 
-func LoadConfig() error {
+var (
+	connectOnce sync.Once
+	connectErr  error
 
-	viper.SetConfigName("config")  
-  	viper.AddConfigPath("../")
+	endpoint string
 
-  	err := viper.ReadInConfig() 
-  	if err != nil {
-    	fmt.Println("Config file not found...")
-  	}
+	Creds    *credentials.Credentials
+	AltCreds *credentials.Credentials
 
-  	return err
+	cfg     *aws.Config
+	altCfg  *aws.Config
+	anonCfg *aws.Config
+
+	sess       *session.Session
+	svc        *s3.S3
+	altSvc     *s3.S3
+	anonSvc    *s3.S3
+	uploader   *s3manager.Uploader
+	downloader *s3manager.Downloader
+)
+
+// Connect creates the suite's clients from the configuration, starting the
+// reference server if it is in use. Only the first call does any work; later
+// calls return its error.
+func Connect() error {
+
+	connect()
+	return connectErr
 }
 
-var err = LoadConfig()
+// connect creates the suite's clients from the configuration the first
+// time one is asked for. The clients are left nil if that fails; call
+// Connect first to handle the error.
+func connect() {
+
+	connectOnce.Do(func() {
+
+		conf := GetConfig()
+
+		endpoint, connectErr = Endpoint()
+		if connectErr != nil {
+			return
+		}
+
+		httpClient, err := newHTTPClient()
+		if err != nil {
+			connectErr = err
+			return
+		}
+
+		Creds = credentials.NewStaticCredentials(conf.Main.AccessKey, conf.Main.AccessSecret, "")
+		AltCreds = credentials.NewStaticCredentials(conf.Alt.AccessKey, conf.Alt.AccessSecret, "")
 
-var Creds = credentials.NewStaticCredentials(viper.GetString("s3main.access_key"), viper.GetString("s3main.access_secret"), "")
+		cfg = aws.NewConfig().WithRegion(conf.Main.Region).
+			WithEndpoint(endpoint).
+			WithDisableSSL(!UseSSL()).
+			WithLogLevel(3).
+			WithS3ForcePathStyle(!UseHostStyle()).
+			WithHTTPClient(httpClient).
+			WithCredentials(Creds)
 
-var cfg = aws.NewConfig().WithRegion(viper.GetString("s3main.region")).
-	WithEndpoint(viper.GetString("s3main.endpoint")).
-	WithDisableSSL(true).
-	WithLogLevel(3).
-	WithS3ForcePathStyle(true).
-	WithCredentials(Creds)
+		altCfg = cfg.Copy().WithRegion(conf.Alt.Region).
+			WithCredentials(AltCreds)
 
-var sess = session.Must(session.NewSession())
-var svc = s3.New(sess, cfg)
-var uploader = s3manager.NewUploader(sess)
-var downloader = s3manager.NewDownloader(sess)
+		anonCfg = cfg.Copy().WithCredentials(credentials.AnonymousCredentials)
+
+		sess = newSession()
+		svc = s3.New(sess, cfg)
+		altSvc = s3.New(sess, altCfg)
+		anonSvc = s3.New(sess, anonCfg)
+		uploader = s3manager.NewUploader(sess)
+		downloader = s3manager.NewDownloader(sess)
+	})
+}
 
 func GetConn() (*s3.S3) {
 
+	connect()
 	return svc	
 }
 
+// GetAltConn returns a client for the s3alt user, a second principal that
+// does not own any of the buckets created through GetConn.
+func GetAltConn() (*s3.S3) {
+
+	connect()
+	return altSvc
+}
+
+// GetAnonConn returns a client that sends unsigned requests.
+func GetAnonConn() (*s3.S3) {
+
+	connect()
+	return anonSvc
+}
+
+func GetUserID(svc *s3.S3) (string, error) {
+
+	result, err := svc.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.StringValue(result.Owner.ID), nil
+}
+
 func WithIfNoneMatch(conditions ...string) request.Option {
     return func(r *request.Request) {
        for _, v := range conditions {
@@ -110,10 +183,12 @@ func CreateObjects(svc *s3.S3, bucket string, objects map[string]string) error {
 			Key:    &key,
 		})
 
-		err = err
+		if err != nil {
+			return err
+		}
 	}
 
-	return err
+	return nil
 }
 
 func DeleteBucket(svc *s3.S3, bucket string) error {
@@ -277,8 +352,8 @@ func GetObjectWithRange(svc *s3.S3, bucket string, key string, range_value strin
 func DeleteObject(svc *s3.S3, bucket string, key string) error {
 
 	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String("Bucketname"),
-		Key:    aws.String("ObjectKey"),
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
 	})
 
 	return err
@@ -302,6 +377,136 @@ func DeleteObjects(svc *s3.S3, bucket string) error {
 	return err
 }
 
+func SetBucketVersioning(svc *s3.S3, bucket string, status string) (*s3.PutBucketVersioningOutput, error) {
+
+	return svc.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucket),
+		VersioningConfiguration: &s3.VersioningConfiguration{
+			Status: aws.String(status),
+		},
+	})
+}
+
+func GetBucketVersioning(svc *s3.S3, bucket string) (string, error) {
+
+	resp, err := svc.GetBucketVersioning(&s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucket),
+	})
+
+	return aws.StringValue(resp.Status), err
+}
+
+// PutObjectVersion writes an object and returns the version ID assigned
+// to it, which is empty for buckets that never had versioning configured.
+func PutObjectVersion(svc *s3.S3, bucket string, key string, content string) (string, error) {
+
+	resp, err := svc.PutObject(&s3.PutObjectInput{
+		Body:   strings.NewReader(content),
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	return aws.StringValue(resp.VersionId), err
+}
+
+func GetObjectVersion(svc *s3.S3, bucket string, key string, versionid string) (string, error) {
+
+	resp, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionid),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	buf := bytes.NewBuffer(nil)
+	_, err = io.Copy(buf, resp.Body)
+
+	return buf.String(), err
+}
+
+func HeadObjectVersion(svc *s3.S3, bucket string, key string, versionid string) (*s3.HeadObjectOutput, error) {
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+
+	if versionid != "" {
+		input.VersionId = aws.String(versionid)
+	}
+
+	return svc.HeadObject(input)
+}
+
+// DeleteObjectVersion deletes one version of an object, or the current
+// version when versionid is empty, which in a versioned bucket leaves a
+// delete marker behind.
+func DeleteObjectVersion(svc *s3.S3, bucket string, key string, versionid string) (*s3.DeleteObjectOutput, error) {
+
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+
+	if versionid != "" {
+		input.VersionId = aws.String(versionid)
+	}
+
+	return svc.DeleteObject(input)
+}
+
+func ListObjectVersions(svc *s3.S3, bucket string, prefix string) (*s3.ListObjectVersionsOutput, error) {
+
+	return svc.ListObjectVersions(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+}
+
+// DeleteObjectVersions permanently deletes every version and delete marker
+// in a bucket.
+func DeleteObjectVersions(svc *s3.S3, bucket string) error {
+
+	input := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}
+
+	for {
+		resp, err := svc.ListObjectVersions(input)
+		if err != nil {
+			return err
+		}
+
+		var objs []*s3.ObjectIdentifier
+		for _, v := range resp.Versions {
+			objs = append(objs, &s3.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range resp.DeleteMarkers {
+			objs = append(objs, &s3.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+
+		if len(objs) > 0 {
+			_, err = svc.DeleteObjects(&s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &s3.Delete{Objects: objs, Quiet: aws.Bool(true)},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !aws.BoolValue(resp.IsTruncated) {
+			return nil
+		}
+
+		input.KeyMarker = resp.NextKeyMarker
+		input.VersionIdMarker = resp.NextVersionIdMarker
+	}
+}
+
 func GetKeys(svc *s3.S3, bucket string) (*s3.ListObjectsOutput, []string, error) {
 	var keys []string
 
@@ -351,7 +556,7 @@ func GetKeysWithMaxKeysAndMarker(svc *s3.S3, bucket string, maxkeys int64, marke
 	var keys []string
 
 	resp, err := svc.ListObjects(&s3.ListObjectsInput{
-		Bucket:  aws.String("bucket"),
+		Bucket:  aws.String(bucket),
 		MaxKeys: aws.Int64(maxkeys),
 		Marker:  aws.String(marker),
 	})
@@ -396,14 +601,37 @@ func DeletePrefixedBuckets(svc *s3.S3){
   for _, b := range buckets.Buckets {
     bucket := aws.StringValue(b.Name)
 
-    if !strings.HasPrefix(bucket, prefix) {
+    if !strings.HasPrefix(bucket, GetPrefix()) {
       continue
     }
+
+    // A policy left behind by a test may deny the owner the calls below.
+    DeleteBucketPolicy(svc, bucket)
     
     if err := DeleteObjects(svc, bucket); err != nil {
       fmt.Fprintf(os.Stderr, "failed to delete objects %q, %v", bucket, err)
     }
 
+    // Versioned buckets keep old versions and delete markers around after
+    // their objects are deleted, and cannot be removed until those are gone.
+    // Locked versions must have their holds lifted and retention bypassed
+    // or over first.
+    if ObjectLockEnabled(svc, bucket) {
+      if err := DeleteLockedObjectVersions(svc, bucket); err != nil {
+        fmt.Fprintf(os.Stderr, "failed to delete locked object versions %q, %v", bucket, err)
+      }
+    } else if status, err := GetBucketVersioning(svc, bucket); err == nil && status != "" {
+      if err := DeleteObjectVersions(svc, bucket); err != nil {
+        fmt.Fprintf(os.Stderr, "failed to delete object versions %q, %v", bucket, err)
+      }
+    }
+
+    // Uploads left in progress keep their parts stored and the bucket
+    // from being deleted.
+    if err := AbortMultipartUploads(svc, bucket); err != nil {
+      fmt.Fprintf(os.Stderr, "failed to abort multipart uploads %q, %v", bucket, err)
+    }
+
     if err := DeleteBucket(svc, bucket); err != nil {
       fmt.Fprintf(os.Stderr, "failed to delete bucket %q, %v", bucket, err)
     }
@@ -433,8 +661,8 @@ func SSEKMSkeyIdCustomerWrite(svc *s3.S3, filesize int) (string, string, error)
 	data :=  strings.Repeat("A", filesize)
 	key := "testobj"
 	bucket := GetBucketName()
-	sse := viper.GetString("s3main.SSE")
-	kmskeyid := viper.GetString("s3main.kmskeyid")
+	sse := GetConfig().Main.SSE
+	kmskeyid := GetConfig().Main.KMSKeyID
 
 	err := CreateBucket(svc, bucket)
 
@@ -450,7 +678,7 @@ func SSEKMSCustomerWrite(svc *s3.S3, filesize int) (string, string, error) {
 	data :=  strings.Repeat("A", filesize)
 	key := "testobj"
 	bucket := GetBucketName()
-	sse := viper.GetString("s3main.SSE")
+	sse := GetConfig().Main.SSE
 
 	err := CreateBucket(svc, bucket)
 
@@ -662,25 +890,14 @@ func GetObj(svc *s3.S3, bucket string, key string) (*s3.GetObjectOutput, error)
 
 func PutObjectWithIfMatch (svc *s3.S3, bucket string, key string, content string, tag string) error {
 
-	data, err := GetObject(svc, bucket, key)
-
-	if data != "" {
-
-		fmt.Sprintf("some data, %v", data)
-	}
-
-	if err == nil{
-
-		ctx := context.Background()
-		ctx, _ = context.WithTimeout(ctx, time.Minute)
-
-		_, err = svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
-		    Bucket: aws.String(bucket),
-		    Key:    aws.String(key),
-		    Body:   strings.NewReader(content),
-		}, WithIfNoneMatch(tag))
+	ctx := context.Background()
+	ctx, _ = context.WithTimeout(ctx, time.Minute)
 
-	}
+	_, err := svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+	    Bucket: aws.String(bucket),
+	    Key:    aws.String(key),
+	    Body:   strings.NewReader(content),
+	}, WithIfMatch(tag))
 
 	return err
 }
@@ -690,7 +907,7 @@ func PutObjectWithIfNoneMatch (svc *s3.S3, bucket string, key string, content st
 	ctx := context.Background()
 	ctx, _ = context.WithTimeout(ctx, time.Minute)
 
-	_, err = svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+	_, err := svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
 	    Bucket: aws.String(bucket),
 	    Key:    aws.String(key),
 	    Body:   strings.NewReader(content),
@@ -806,7 +1023,7 @@ func SetupObjectWithHeader(svc *s3.S3, bucket string, key string, content string
 	ctx := context.Background()
 	ctx, _ = context.WithTimeout(ctx, time.Minute)
 
-	_, err = svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+	_, err := svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 		Body:   strings.NewReader(content),
@@ -820,7 +1037,7 @@ func SetupBucketWithHeader(svc *s3.S3, bucket string, headers map[string]string)
 	ctx := context.Background()
 	ctx, _ = context.WithTimeout(ctx, time.Minute)
 
-	_, err = svc.CreateBucketWithContext(ctx, &s3.CreateBucketInput{
+	_, err := svc.CreateBucketWithContext(ctx, &s3.CreateBucketInput{
 		Bucket: aws.String(bucket),
 	}, AddHeaders(headers))
 
@@ -874,6 +1091,37 @@ func GetLifecycle(svc *s3.S3, bucket string) (*s3.GetBucketLifecycleConfiguratio
 	return result, err
 }
 
+func PutBucketTagging(svc *s3.S3, bucket string, tags map[string]string) error {
+
+	_, err := svc.PutBucketTagging(&s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucket),
+		Tagging: &s3.Tagging{TagSet: TagSet(tags)},
+	})
+
+	return err
+}
+
+func GetBucketTagging(svc *s3.S3, bucket string) (map[string]string, error) {
+
+	result, err := svc.GetBucketTagging(&s3.GetBucketTaggingInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return TagMap(result.TagSet), nil
+}
+
+func DeleteBucketTagging(svc *s3.S3, bucket string) error {
+
+	_, err := svc.DeleteBucketTagging(&s3.DeleteBucketTaggingInput{
+		Bucket: aws.String(bucket),
+	})
+
+	return err
+}
+
 func SetACL (svc *s3.S3, bucket string, acl string)(*s3.PutBucketAclOutput, error){
 
 	req, resp := svc.PutBucketAclRequest(&s3.PutBucketAclInput{
@@ -886,9 +1134,37 @@ func SetACL (svc *s3.S3, bucket string, acl string)(*s3.PutBucketAclOutput, erro
 	return resp, err
 }
 
+func SetObjectACL (svc *s3.S3, bucket string, key string, acl string)(*s3.PutObjectAclOutput, error){
+
+	req, resp := svc.PutObjectAclRequest(&s3.PutObjectAclInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		ACL: 	aws.String(acl),
+	})
+
+	err := req.Send()
+
+	return resp, err
+}
+
+func GetBucketACL (svc *s3.S3, bucket string)(*s3.GetBucketAclOutput, error){
+
+	return svc.GetBucketAcl(&s3.GetBucketAclInput{
+		Bucket: aws.String(bucket),
+	})
+}
+
+func GetObjectACL (svc *s3.S3, bucket string, key string)(*s3.GetObjectAclOutput, error){
+
+	return svc.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+}
+
 func SetupRequest(serviceName, region, body string) (*http.Request, io.ReadSeeker) {
 
-	endpoint := "https://" + serviceName + "." + region + "." + viper.GetString("s3main.endpoint")
+	endpoint := "https://" + serviceName + "." + region + "." + GetConfig().Main.Endpoint
 	reader := strings.NewReader(body)
 	req, _ := http.NewRequest("POST", endpoint, reader)
 	req.Header.Add("X-Amz-Target", "prefix.Operation")
@@ -908,7 +1184,7 @@ func SetupRawRequest(proto, method, url, body string) (*http.Request, io.ReadSee
 	req, _ := http.NewRequest(method, endpoint, reader)
 	req.Header.Add("X-Amz-Target", "prefix.Operation")
 	req.Header.Add("Content-Type", "application/x-amz-json-1.0")
-	req.Header.Add("Content-Length", string(len(body)))
+	req.Header.Add("Content-Length", strconv.Itoa(len(body)))
 	req.Header.Add("X-Amz-Meta-Other-Header", "some-value=!@#$%^&* (+)")
 	req.Header.Add("X-Amz-Meta-Other-Header_With_Underscore", "some-value=!@#$%^&* (+)")
 	req.Header.Add("X-amz-Meta-Other-Header_With_Underscore", "some-value=!@#$%^&* (+)")
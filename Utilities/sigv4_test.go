@@ -0,0 +1,204 @@
+package helpers
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"../s3server"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSigningKey checks the key derived in the AWS documentation example of
+// deriving a SigV4 signing key.
+func TestSigningKey(t *testing.T) {
+
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d",
+		hex.EncodeToString(signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")))
+}
+
+// verifierServer starts a server that answers 200 to requests the verifier
+// accepts, and with the error it fails the others with otherwise, as S3
+// would.
+func verifierServer(v *VerifierV4) *httptest.Server {
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		err := v.Verify(r, body)
+		if err == nil {
+			return
+		}
+
+		failure := err.(awserr.RequestFailure)
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(failure.StatusCode())
+		xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"Error"`
+			Code    string
+			Message string
+		}{Code: failure.Code(), Message: failure.Message()})
+	}))
+}
+
+func TestVerifierV4(t *testing.T) {
+
+	now := time.Now().UTC()
+	creds := credentials.NewStaticCredentials("AKIDMAIN", "secret", "")
+
+	verifier := &VerifierV4{s3server.VerifierV4{
+		Region:  "us-east-1",
+		Service: "s3",
+		Secret: func(accessKey string) (string, bool) {
+			return "secret", accessKey == "AKIDMAIN"
+		},
+		Now: func() time.Time { return now },
+	}}
+
+	ts := verifierServer(verifier)
+	defer ts.Close()
+
+	host := strings.TrimPrefix(ts.URL, "http://")
+
+	// sign returns a PUT of body to path signed in its headers by signer.
+	sign := func(signer func(r *http.Request, body string), path string, body string) *http.Request {
+		req, _ := SetupRawRequest("http", "PUT", host+path, body)
+		signer(req, body)
+		return req
+	}
+
+	// presign returns a GET of path presigned by creds for expires.
+	presign := func(creds *credentials.Credentials, region string, path string, signedAt time.Time, expires time.Duration) *http.Request {
+		req, _ := SetupRawRequest("http", "GET", host+path, "")
+		req.Header.Del("Content-Length")
+		signer := SetupSigner(creds)
+		signer.Presign(req, nil, "s3", region, expires, signedAt)
+		return req
+	}
+
+	signWith := func(creds *credentials.Credentials, service string, region string, signedAt time.Time) func(r *http.Request, body string) {
+		return func(r *http.Request, body string) {
+			signer := SetupSigner(creds)
+			signer.Sign(r, strings.NewReader(body), service, region, signedAt)
+		}
+	}
+	signed := signWith(creds, "s3", "us-east-1", now)
+
+	cases := []struct {
+		name    string
+		request func() *http.Request
+		code    string
+		status  int
+	}{
+		{"signed", func() *http.Request {
+			return sign(signed, "/bucket/key", "hello")
+		}, "", http.StatusOK},
+
+		{"signed escaped path", func() *http.Request {
+			return sign(signed, "/bucket/a%20key/with%2Fslash", "hello")
+		}, "", http.StatusOK},
+
+		{"presigned", func() *http.Request {
+			return presign(creds, "us-east-1", "/bucket/key", now, 5*time.Minute)
+		}, "", http.StatusOK},
+
+		{"altered header", func() *http.Request {
+			req := sign(signed, "/bucket/key", "hello")
+			req.Header.Set("X-Amz-Meta-Other-Header", "other-value")
+			return req
+		}, "SignatureDoesNotMatch", http.StatusForbidden},
+
+		{"added signed header", func() *http.Request {
+			req := sign(signed, "/bucket/key", "hello")
+			req.Header.Add("X-Amz-Target", "prefix.Other")
+			return req
+		}, "SignatureDoesNotMatch", http.StatusForbidden},
+
+		{"altered path", func() *http.Request {
+			req := sign(signed, "/bucket/key", "hello")
+			req.URL.Path = "/bucket/other"
+			return req
+		}, "SignatureDoesNotMatch", http.StatusForbidden},
+
+		{"altered query", func() *http.Request {
+			req := sign(signed, "/bucket/key", "hello")
+			req.URL.RawQuery = "acl"
+			return req
+		}, "SignatureDoesNotMatch", http.StatusForbidden},
+
+		{"altered body", func() *http.Request {
+			req := sign(signed, "/bucket/key", "hello")
+			req.Body = ioutil.NopCloser(strings.NewReader("jello"))
+			return req
+		}, "XAmzContentSHA256Mismatch", http.StatusBadRequest},
+
+		{"wrong secret", func() *http.Request {
+			return sign(signWith(credentials.NewStaticCredentials("AKIDMAIN", "wrong", ""), "s3", "us-east-1", now), "/bucket/key", "hello")
+		}, "SignatureDoesNotMatch", http.StatusForbidden},
+
+		{"unknown access key", func() *http.Request {
+			return sign(signWith(credentials.NewStaticCredentials("AKIDOTHER", "secret", ""), "s3", "us-east-1", now), "/bucket/key", "hello")
+		}, "InvalidAccessKeyId", http.StatusForbidden},
+
+		{"wrong region", func() *http.Request {
+			return sign(signWith(creds, "s3", "us-west-2", now), "/bucket/key", "hello")
+		}, "AuthorizationHeaderMalformed", http.StatusBadRequest},
+
+		{"wrong service", func() *http.Request {
+			return sign(signWith(creds, "iam", "us-east-1", now), "/bucket/key", "hello")
+		}, "AuthorizationHeaderMalformed", http.StatusBadRequest},
+
+		{"skewed past", func() *http.Request {
+			return sign(signWith(creds, "s3", "us-east-1", now.Add(-20*time.Minute)), "/bucket/key", "hello")
+		}, "RequestTimeTooSkewed", http.StatusForbidden},
+
+		{"skewed future", func() *http.Request {
+			return sign(signWith(creds, "s3", "us-east-1", now.Add(20*time.Minute)), "/bucket/key", "hello")
+		}, "RequestTimeTooSkewed", http.StatusForbidden},
+
+		{"unsigned", func() *http.Request {
+			req, _ := SetupRawRequest("http", "PUT", host+"/bucket/key", "hello")
+			return req
+		}, "AccessDenied", http.StatusForbidden},
+
+		{"presigned altered path", func() *http.Request {
+			req := presign(creds, "us-east-1", "/bucket/key", now, 5*time.Minute)
+			req.URL.Path = "/bucket/other"
+			return req
+		}, "SignatureDoesNotMatch", http.StatusForbidden},
+
+		{"presigned wrong region", func() *http.Request {
+			return presign(creds, "us-west-2", "/bucket/key", now, 5*time.Minute)
+		}, "AuthorizationQueryParametersError", http.StatusBadRequest},
+
+		{"presigned expired", func() *http.Request {
+			return presign(creds, "us-east-1", "/bucket/key", now.Add(-10*time.Minute), 5*time.Minute)
+		}, "AccessDenied", http.StatusForbidden},
+
+		{"presigned over a week", func() *http.Request {
+			return presign(creds, "us-east-1", "/bucket/key", now, MaxPresignExpiry+time.Second)
+		}, "AuthorizationQueryParametersError", http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		resp, err := ts.Client().Do(c.request())
+		if !assert.Nil(t, err, c.name) {
+			continue
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		body := string(data)
+
+		assert.Equal(t, c.status, resp.StatusCode, c.name+": "+body)
+		if err := ResponseError(resp, body); c.code != "" && assert.NotNil(t, err, c.name) {
+			assert.Equal(t, c.code, err.(awserr.RequestFailure).Code(), c.name)
+		}
+	}
+}
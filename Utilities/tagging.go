@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Limits S3 puts on tag sets.
+const (
+	MaxObjectTags  = 10
+	MaxBucketTags  = 50
+	MaxTagKeyLen   = 128
+	MaxTagValueLen = 256
+)
+
+// TagSet turns a map of tags into an S3 tag set, sorted by key.
+func TagSet(tags map[string]string) []*s3.Tag {
+
+	set := []*s3.Tag{}
+	for k, v := range tags {
+		set = append(set, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	sort.Slice(set, func(i, j int) bool { return *set[i].Key < *set[j].Key })
+
+	return set
+}
+
+// TagMap turns an S3 tag set back into a map.
+func TagMap(set []*s3.Tag) map[string]string {
+
+	tags := make(map[string]string)
+	for _, t := range set {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags
+}
+
+// TaggingHeader encodes tags for the x-amz-tagging header.
+func TaggingHeader(tags map[string]string) string {
+
+	v := url.Values{}
+	for k, value := range tags {
+		v.Set(k, value)
+	}
+	return v.Encode()
+}
+
+// MakeTags returns n tags with random keys and values of the given lengths.
+func MakeTags(n int, keyLen int, valueLen int) map[string]string {
+
+	tags := make(map[string]string)
+	for len(tags) < n {
+		tags[String(keyLen)] = String(valueLen)
+	}
+	return tags
+}
+
+func PutObjectTagging(svc *s3.S3, bucket string, key string, tags map[string]string) error {
+
+	_, err := svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		Tagging: &s3.Tagging{TagSet: TagSet(tags)},
+	})
+
+	return err
+}
+
+func GetObjectTagging(svc *s3.S3, bucket string, key string) (map[string]string, error) {
+
+	resp, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return TagMap(resp.TagSet), nil
+}
+
+func DeleteObjectTagging(svc *s3.S3, bucket string, key string) error {
+
+	_, err := svc.DeleteObjectTagging(&s3.DeleteObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+// PutObjectWithTagging writes an object with tags sent in the x-amz-tagging
+// header.
+func PutObjectWithTagging(svc *s3.S3, bucket string, key string, content string, tags map[string]string) error {
+
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		Body:    strings.NewReader(content),
+		Tagging: aws.String(TaggingHeader(tags)),
+	})
+
+	return err
+}
+
+// CopyObjectWithTagging copies source, given as bucket/key, to key in
+// bucket. The directive is sent as x-amz-tagging-directive when not empty,
+// and tags in x-amz-tagging when not nil.
+func CopyObjectWithTagging(svc *s3.S3, bucket string, source string, key string, directive string, tags map[string]string) error {
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		CopySource: aws.String(source),
+		Key:        aws.String(key),
+	}
+
+	if directive != "" {
+		input.TaggingDirective = aws.String(directive)
+	}
+	if tags != nil {
+		input.Tagging = aws.String(TaggingHeader(tags))
+	}
+
+	_, err := svc.CopyObject(input)
+
+	return err
+}
+
+// InitiateMultipartUploadWithTagging starts a multipart upload whose
+// object will carry tags once completed.
+func InitiateMultipartUploadWithTagging(svc *s3.S3, bucket string, key string, tags map[string]string) (*MultipartUpload, error) {
+
+	resp, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		Tagging: aws.String(TaggingHeader(tags)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultipartUpload{Bucket: bucket, Key: key, UploadID: aws.StringValue(resp.UploadId)}, nil
+}
+
+// GetObjectTaggingCount returns the x-amz-tagging-count of a GET of the
+// object, 0 when the header is missing.
+func GetObjectTaggingCount(svc *s3.S3, bucket string, key string) (int64, error) {
+
+	resp, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return aws.Int64Value(resp.TagCount), nil
+}
@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"fmt"
+)
+
+// Feature tags name the part of the S3 API a test exercises. Every test in
+// the suites carries at least one of them.
+const (
+	TagBucket              = "bucket"
+	TagObject              = "object"
+	TagList                = "list"
+	TagACL                 = "acl"
+	TagPermission          = "permission"
+	TagMetadata            = "metadata"
+	TagRange               = "range"
+	TagConditional         = "conditional"
+	TagHeaders             = "headers"
+	TagMultipart           = "multipart"
+	TagEncryption          = "encryption"
+	TagSSEKMS              = "sse-kms"
+	TagLifecycle           = "lifecycle"
+	TagLifecycleExpiration = "lifecycle-expiration"
+	TagVersioning          = "versioning"
+	TagSigning             = "signing"
+	TagHostStyle           = "host-style"
+	TagTLS                 = "tls"
+	TagPolicy              = "policy"
+	TagTagging             = "tagging"
+	TagCORS                = "cors"
+	TagWebsite             = "website"
+	TagObjectLock          = "object-lock"
+	TagPostObject          = "post-object"
+	TagPresign             = "presign"
+	TagChunked             = "chunked"
+)
+
+// Quirk tags mark tests known to fail on a given backend, in the spirit of
+// the fails_on_* attributes of the Python s3-tests.
+const (
+	TagFailsOnRGW      = "fails_on_rgw"
+	TagFailsOnAWS      = "fails_on_aws"
+	TagFailsOnEmbedded = "fails_on_embedded"
+
+	// TagBrokenClient marks tests that fail whatever the backend, in the
+	// SDK or Go's HTTP client before a request is sent, or on a signature
+	// pinned to the sample config. They only run when included by name.
+	TagBrokenClient = "broken_client"
+)
+
+// embeddedExcludes are the tags never run against the reference server:
+// features it does not implement and tests that only hold for a gateway.
+var embeddedExcludes = []string{
+	TagFailsOnEmbedded,
+	TagFailsOnAWS,
+	TagSSEKMS,
+}
+
+// IncludedTags returns the tags a test must carry one of to run. It is read
+// from S3TEST_TAGS, falling back to `include_tags` under [DEFAULT]. An
+// empty list selects every test.
+func IncludedTags() []string {
+
+	return GetConfig().Default.IncludeTags
+}
+
+// ExcludedTags returns the tags that cause a test to be skipped. It is read
+// from S3TEST_EXCLUDE_TAGS, falling back to `exclude_tags` under [DEFAULT].
+func ExcludedTags() []string {
+
+	return GetConfig().Default.ExcludeTags
+}
+
+// SkipReason returns why a test carrying tags should not run, or an empty
+// string when it should. Exclusions win over inclusions.
+func SkipReason(tags []string) string {
+
+	if UseEmbeddedServer() {
+		for _, t := range embeddedExcludes {
+			if Contains(tags, t) {
+				return fmt.Sprintf("tagged %q, which is always excluded against the embedded server", t)
+			}
+		}
+	}
+
+	if Contains(tags, TagBrokenClient) && !Contains(IncludedTags(), TagBrokenClient) {
+		return fmt.Sprintf("tagged %q, which is only run when included", TagBrokenClient)
+	}
+
+	for _, t := range ExcludedTags() {
+		if Contains(tags, t) {
+			return fmt.Sprintf("tagged %q, which is excluded", t)
+		}
+	}
+
+	included := IncludedTags()
+	if len(included) == 0 {
+		return ""
+	}
+
+	for _, t := range included {
+		if Contains(tags, t) {
+			return ""
+		}
+	}
+
+	return fmt.Sprintf("tagged %v, none of which is included in %v", tags, included)
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipReason(t *testing.T) {
+
+	assert := assert.New(t)
+
+	saved := config
+	defer func() { config = saved }()
+
+	off, on := false, true
+	config = &Config{Default: DefaultConfig{
+		Embedded:    &off,
+		IncludeTags: []string{TagObject, TagList},
+		ExcludeTags: []string{TagSSEKMS},
+	}}
+
+	assert.Equal([]string{"object", "list"}, IncludedTags())
+	assert.Equal("", SkipReason([]string{TagObject, TagEncryption}))
+	assert.NotEqual("", SkipReason([]string{TagObject, TagSSEKMS}))
+	assert.NotEqual("", SkipReason([]string{TagBucket}))
+	assert.NotEqual("", SkipReason([]string{TagObject, TagBrokenClient}))
+
+	config.Default.IncludeTags = []string{TagBrokenClient}
+	assert.Equal("", SkipReason([]string{TagObject, TagBrokenClient}))
+
+	config.Default.Embedded = &on
+	assert.NotEqual("", SkipReason([]string{TagObject, TagFailsOnEmbedded}))
+}
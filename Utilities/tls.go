@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// UseSSL reports whether the suite connects over HTTPS. S3TEST_IS_SECURE
+// takes precedence over `is_secure` under [s3main], which takes precedence
+// over `is_secure` under [DEFAULT]. Values such as "yes" and "no" are
+// accepted as well as booleans.
+func UseSSL() bool {
+
+	return GetConfig().Secure()
+}
+
+func isTrue(v string) bool {
+
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// clientTLSConfig builds the client TLS settings from [DEFAULT]: `ca_bundle`, a
+// PEM file of extra trusted roots, `client_cert` and `client_key`, a PEM
+// key pair presented to the gateway, and `insecure_skip_verify`.
+func clientTLSConfig() (*tls.Config, error) {
+
+	settings := GetConfig().Default
+
+	conf := &tls.Config{
+		InsecureSkipVerify: settings.InsecureSkipVerify,
+	}
+
+	if bundle := settings.CABundle; bundle != "" || embeddedCert != nil {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+
+		if bundle != "" {
+			pem, err := ioutil.ReadFile(bundle)
+			if err != nil {
+				return nil, err
+			}
+			if !roots.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", bundle)
+			}
+		}
+
+		if embeddedCert != nil {
+			roots.AddCert(embeddedCert)
+		}
+
+		conf.RootCAs = roots
+	}
+
+	cert, key := settings.ClientCert, settings.ClientKey
+	if cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{pair}
+	}
+
+	return conf, nil
+}
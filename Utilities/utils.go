@@ -2,9 +2,8 @@ package helpers
 
 import (  
   "math/rand"
+  "strings"
   "time"
-
-  "github.com/spf13/viper"
   "fmt"
 )
 
@@ -27,11 +26,10 @@ func String(length int) string {
 }
 
 var bucket_counter = 1
-var prefix = viper.GetString("fixtures.bucket_prefix")
 
 func GetPrefix() string {
 
-  return prefix
+  return GetConfig().Fixtures.BucketPrefix
 }
 
 func GetBucketName() string {
@@ -42,6 +40,11 @@ func GetBucketName() string {
 
   name := fmt.Sprintf("%s-%s-%d", prefix, random, num)
 
+  // Host-style requests need a name that is a valid DNS label.
+  if UseHostStyle() {
+    name = strings.ToLower(name)
+  }
+
   return name
 }
 
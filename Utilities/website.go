@@ -0,0 +1,113 @@
+package helpers
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// WebsiteConfig returns a website configuration serving index for keys
+// ending in a slash, and the errorDocument key for errors when it is not
+// empty, with rules evaluated first.
+func WebsiteConfig(index string, errorDocument string, rules ...*s3.RoutingRule) *s3.WebsiteConfiguration {
+
+	config := &s3.WebsiteConfiguration{IndexDocument: &s3.IndexDocument{Suffix: aws.String(index)}}
+	if errorDocument != "" {
+		config.ErrorDocument = &s3.ErrorDocument{Key: aws.String(errorDocument)}
+	}
+	if len(rules) > 0 {
+		config.RoutingRules = rules
+	}
+
+	return config
+}
+
+func PutBucketWebsite(svc *s3.S3, bucket string, config *s3.WebsiteConfiguration) error {
+
+	_, err := svc.PutBucketWebsite(&s3.PutBucketWebsiteInput{
+		Bucket:               aws.String(bucket),
+		WebsiteConfiguration: config,
+	})
+
+	return err
+}
+
+func GetBucketWebsite(svc *s3.S3, bucket string) (*s3.GetBucketWebsiteOutput, error) {
+
+	return svc.GetBucketWebsite(&s3.GetBucketWebsiteInput{
+		Bucket: aws.String(bucket),
+	})
+}
+
+func DeleteBucketWebsite(svc *s3.S3, bucket string) error {
+
+	_, err := svc.DeleteBucketWebsite(&s3.DeleteBucketWebsiteInput{
+		Bucket: aws.String(bucket),
+	})
+
+	return err
+}
+
+// WebsiteEndpoint returns the host:port buckets are served as websites
+// under: `website_endpoint` of [DEFAULT], or the website domain of the
+// reference server. It is empty when no website endpoint is configured.
+func WebsiteEndpoint() string {
+
+	if !UseEmbeddedServer() {
+		return GetConfig().Default.WebsiteEndpoint
+	}
+
+	connect()
+
+	_, port, _ := net.SplitHostPort(endpoint)
+	return net.JoinHostPort(embeddedWebsiteDomain, port)
+}
+
+// websiteScheme returns the scheme website requests are sent with.
+func websiteScheme() string {
+
+	if UseSSL() {
+		return "https"
+	}
+	return "http"
+}
+
+// WebsiteURL returns the address of path on the website endpoint of bucket.
+func WebsiteURL(bucket string, path string) string {
+
+	return websiteScheme() + "://" + bucket + "." + WebsiteEndpoint() + "/" + path
+}
+
+// noRedirectClient returns a client like the suite's that returns redirects
+// instead of following them.
+func noRedirectClient() *http.Client {
+
+	client := *cfg.HTTPClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &client
+}
+
+// WebsiteRequest sends an unsigned request for path to the website endpoint
+// of bucket, the way a browser would, without following redirects. It
+// returns the response and its body.
+func WebsiteRequest(method string, bucket string, path string) (*http.Response, string, error) {
+
+	connect()
+
+	req, _ := SetupRawRequest(websiteScheme(), method, bucket+"."+WebsiteEndpoint()+"/"+path, "")
+
+	resp, err := noRedirectClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	return resp, string(body), err
+}
@@ -0,0 +1,316 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+)
+
+// Permissions that can be granted in an access control list.
+const (
+	permRead        = "READ"
+	permWrite       = "WRITE"
+	permReadACP     = "READ_ACP"
+	permWriteACP    = "WRITE_ACP"
+	permFullControl = "FULL_CONTROL"
+)
+
+// Well known group URIs.
+const (
+	groupAllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	groupAuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	groupLogDelivery        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// grant gives a single permission to a user or a group. Grants made by
+// email address are stored against the user's canonical ID, as S3 does.
+type grant struct {
+	id         string
+	uri        string
+	permission string
+}
+
+// acl is the access control list of a bucket or object.
+type acl struct {
+	owner  *User
+	grants []grant
+}
+
+// cannedACL builds the grants for a canned ACL value. bucketOwner is only
+// consulted by the bucket-owner-* values, which apply to objects. A nil
+// owner is the anonymous principal, which gets no grant of its own.
+func (s *Server) cannedACL(name string, owner, bucketOwner *User) (*acl, error) {
+
+	a := &acl{owner: owner}
+	if owner != nil {
+		a.grants = append(a.grants, grant{id: owner.ID, permission: permFullControl})
+	}
+
+	switch name {
+	case "", "private":
+	case "public-read":
+		a.grants = append(a.grants, grant{uri: groupAllUsers, permission: permRead})
+	case "public-read-write":
+		a.grants = append(a.grants,
+			grant{uri: groupAllUsers, permission: permRead},
+			grant{uri: groupAllUsers, permission: permWrite})
+	case "authenticated-read":
+		a.grants = append(a.grants, grant{uri: groupAuthenticatedUsers, permission: permRead})
+	case "bucket-owner-read":
+		if bucketOwner != nil && bucketOwner.ID != owner.ID {
+			a.grants = append(a.grants, grant{id: bucketOwner.ID, permission: permRead})
+		}
+	case "bucket-owner-full-control":
+		if bucketOwner != nil && bucketOwner.ID != owner.ID {
+			a.grants = append(a.grants, grant{id: bucketOwner.ID, permission: permFullControl})
+		}
+	case "log-delivery-write":
+		a.grants = append(a.grants,
+			grant{uri: groupLogDelivery, permission: permWrite},
+			grant{uri: groupLogDelivery, permission: permReadACP})
+	default:
+		return nil, errInvalidArgument.withMessage("Invalid canned ACL: " + name)
+	}
+
+	return a, nil
+}
+
+// allows reports whether the ACL grants perm to user. A nil user is the
+// anonymous principal.
+func (a *acl) allows(user *User, perm string) bool {
+
+	if user != nil && a.owner != nil && user.ID == a.owner.ID {
+		// Owners can always read and change the ACL itself.
+		if perm == permReadACP || perm == permWriteACP {
+			return true
+		}
+	}
+
+	for _, g := range a.grants {
+		if g.permission != perm && g.permission != permFullControl {
+			continue
+		}
+
+		switch {
+		case g.uri == groupAllUsers:
+			return true
+		case g.uri == groupAuthenticatedUsers && user != nil:
+			return true
+		case user != nil && g.id != "" && g.id == user.ID:
+			return true
+		}
+	}
+
+	return false
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+func ownerOf(u *User) *owner {
+
+	if u == nil {
+		return nil
+	}
+	return &owner{ID: u.ID, DisplayName: u.DisplayName}
+}
+
+type grantee struct {
+	XMLNS        string `xml:"xmlns:xsi,attr"`
+	Type         string `xml:"xsi:type,attr"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+}
+
+type accessControlPolicy struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ AccessControlPolicy"`
+	Owner   *owner   `xml:"Owner"`
+	Grants  []struct {
+		Grantee    grantee `xml:"Grantee"`
+		Permission string  `xml:"Permission"`
+	} `xml:"AccessControlList>Grant"`
+}
+
+// policy renders the ACL as an AccessControlPolicy document.
+func (s *Server) policy(a *acl) *accessControlPolicy {
+
+	p := &accessControlPolicy{Owner: ownerOf(a.owner)}
+
+	for _, g := range a.grants {
+		e := grantee{XMLNS: xsiNamespace}
+
+		switch {
+		case g.uri != "":
+			e.Type, e.URI = "Group", g.uri
+		default:
+			e.Type, e.ID = "CanonicalUser", g.id
+			if u := s.userByID(g.id); u != nil {
+				e.DisplayName = u.DisplayName
+			}
+		}
+
+		p.Grants = append(p.Grants, struct {
+			Grantee    grantee `xml:"Grantee"`
+			Permission string  `xml:"Permission"`
+		}{e, g.permission})
+	}
+
+	return p
+}
+
+// parsePolicy reads an AccessControlPolicy document sent by a client.
+func (s *Server) parsePolicy(body []byte, current *acl) (*acl, error) {
+
+	var doc struct {
+		Owner struct {
+			ID string `xml:"ID"`
+		} `xml:"Owner"`
+		Grants []struct {
+			Grantee struct {
+				Type         string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+				ID           string `xml:"ID"`
+				EmailAddress string `xml:"EmailAddress"`
+				URI          string `xml:"URI"`
+			} `xml:"Grantee"`
+			Permission string `xml:"Permission"`
+		} `xml:"AccessControlList>Grant"`
+	}
+
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, errMalformedXML
+	}
+
+	if doc.Owner.ID != "" && (current.owner == nil || doc.Owner.ID != current.owner.ID) {
+		return nil, errAccessDenied
+	}
+
+	a := &acl{owner: current.owner}
+
+	for _, g := range doc.Grants {
+		switch g.Permission {
+		case permRead, permWrite, permReadACP, permWriteACP, permFullControl:
+		default:
+			return nil, errMalformedXML
+		}
+
+		switch g.Grantee.Type {
+		case "CanonicalUser":
+			if s.userByID(g.Grantee.ID) == nil {
+				return nil, errInvalidArgument.withMessage("Invalid id")
+			}
+			a.grants = append(a.grants, grant{id: g.Grantee.ID, permission: g.Permission})
+		case "AmazonCustomerByEmail":
+			u := s.userByEmail(g.Grantee.EmailAddress)
+			if u == nil {
+				return nil, errUnresolvableGrantByEmail
+			}
+			a.grants = append(a.grants, grant{id: u.ID, permission: g.Permission})
+		case "Group":
+			a.grants = append(a.grants, grant{uri: g.Grantee.URI, permission: g.Permission})
+		default:
+			return nil, errMalformedXML
+		}
+	}
+
+	return a, nil
+}
+
+// grantHeaders reads the x-amz-grant-* headers into an ACL. It returns a
+// nil ACL when none of the headers are present.
+func (s *Server) grantHeaders(r *http.Request, owner *User) (*acl, error) {
+
+	headers := map[string]string{
+		"x-amz-grant-read":         permRead,
+		"x-amz-grant-write":        permWrite,
+		"x-amz-grant-read-acp":     permReadACP,
+		"x-amz-grant-write-acp":    permWriteACP,
+		"x-amz-grant-full-control": permFullControl,
+	}
+
+	var a *acl
+
+	for header, perm := range headers {
+		value := r.Header.Get(header)
+		if value == "" {
+			continue
+		}
+
+		if a == nil {
+			a = &acl{owner: owner}
+		}
+
+		for _, item := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+			if len(kv) != 2 {
+				return nil, errInvalidArgument
+			}
+
+			v := strings.Trim(kv[1], `"`)
+
+			switch strings.ToLower(kv[0]) {
+			case "id":
+				if s.userByID(v) == nil {
+					return nil, errInvalidArgument.withMessage("Invalid id")
+				}
+				a.grants = append(a.grants, grant{id: v, permission: perm})
+			case "emailaddress":
+				u := s.userByEmail(v)
+				if u == nil {
+					return nil, errUnresolvableGrantByEmail
+				}
+				a.grants = append(a.grants, grant{id: u.ID, permission: perm})
+			case "uri":
+				a.grants = append(a.grants, grant{uri: v, permission: perm})
+			default:
+				return nil, errInvalidArgument
+			}
+		}
+	}
+
+	return a, nil
+}
+
+// newACL returns the ACL for a new bucket or object, honouring either a
+// canned x-amz-acl header or explicit x-amz-grant-* headers.
+func (s *Server) newACL(r *http.Request, owner, bucketOwner *User) (*acl, error) {
+
+	granted, err := s.grantHeaders(r, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	if granted != nil {
+		if r.Header.Get("x-amz-acl") != "" {
+			return nil, errInvalidRequest.withMessage("Specifying both Canned ACLs and Header Grants is not allowed")
+		}
+		return granted, nil
+	}
+
+	return s.cannedACL(r.Header.Get("x-amz-acl"), owner, bucketOwner)
+}
+
+func (s *Server) userByID(id string) *User {
+
+	for _, u := range s.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) userByEmail(email string) *User {
+
+	for _, u := range s.users {
+		if email != "" && strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package s3server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPresignExpires is the longest, in seconds, a presigned URL may be valid
+// for: a week.
+const maxPresignExpires = 7 * 24 * 60 * 60
+
+// maxClockSkew is how far the date of a request signed in its headers may
+// be from the server's clock.
+const maxClockSkew = 15 * time.Minute
+
+// authenticate resolves the principal a request was made by, whose body
+// has already been read into body. Requests without credentials are
+// anonymous and resolve to a nil user.
+//
+// SigV4 signatures, in headers or presigned URLs, are verified with
+// verifierV4, and SigV2 ones with verifyV2. The verified SigV4 signature is
+// returned as well, for the chunk signatures of an aws-chunked body to
+// chain from.
+func (s *Server) authenticate(r *http.Request, body []byte) (*User, *signatureV4, error) {
+
+	accessKey, ok, err := accessKeyOf(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, nil
+	}
+
+	if accessKey == "" {
+		return nil, nil, errAccessDenied
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[accessKey]
+	if !ok {
+		return nil, nil, errInvalidAccessKeyID
+	}
+
+	if !isSignedV4(r) {
+		if err := s.verifyV2(r, user); err != nil {
+			return nil, nil, err
+		}
+		return user, nil, nil
+	}
+
+	signature, err := s.verifierV4().verify(r, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, signature, nil
+}
+
+// verifierV4 returns the verifier of the SigV4 requests the server's users
+// sign. It must be used with s.mu held.
+func (s *Server) verifierV4() *VerifierV4 {
+
+	return &VerifierV4{
+		Region:  s.config.Region,
+		Service: "s3",
+		Secret: func(accessKey string) (string, bool) {
+			user, ok := s.users[accessKey]
+			if !ok {
+				return "", false
+			}
+			return user.SecretKey, true
+		},
+		Now:                    s.now,
+		MaxSkew:                maxClockSkew,
+		DisableURIPathEscaping: true,
+	}
+}
+
+// checkRequestTime checks that a request signed in its headers is dated,
+// by x-amz-date or else Date, within maxClockSkew of the server's clock.
+func (s *Server) checkRequestTime(r *http.Request) error {
+
+	var date time.Time
+	var err error
+
+	if v := r.Header.Get("X-Amz-Date"); v != "" {
+		date, err = time.Parse("20060102T150405Z", v)
+		if err != nil {
+			date, err = http.ParseTime(v)
+		}
+	} else {
+		date, err = http.ParseTime(r.Header.Get("Date"))
+	}
+	if err != nil {
+		return errAccessDenied.withMessage("AWS authentication requires a valid Date or x-amz-date header")
+	}
+
+	if skew := s.now().Sub(date); skew > maxClockSkew || skew < -maxClockSkew {
+		return errRequestTimeTooSkewed
+	}
+
+	return nil
+}
+
+// checkPresignV2Expiry checks that a URL presigned with SigV2 has not
+// expired.
+func (s *Server) checkPresignV2Expiry(query url.Values) error {
+
+	expires, err := strconv.ParseInt(query.Get("Expires"), 10, 64)
+	if err != nil {
+		return errAccessDenied.withMessage("Query-string authentication requires the Signature, Expires and AWSAccessKeyId parameters")
+	}
+
+	if s.now().After(time.Unix(expires, 0)) {
+		return errAccessDenied.withMessage("Request has expired")
+	}
+
+	return nil
+}
+
+// accessKeyOf extracts the access key from a SigV4 or SigV2 Authorization
+// header or presigned query string. ok is false when the request carries
+// none of them.
+func accessKeyOf(r *http.Request) (accessKey string, ok bool, err error) {
+
+	query := r.URL.Query()
+
+	if credential := query.Get("X-Amz-Credential"); credential != "" {
+		return strings.SplitN(credential, "/", 2)[0], true, nil
+	}
+
+	if accessKey := query.Get("AWSAccessKeyId"); accessKey != "" {
+		return accessKey, true, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return "", false, nil
+	}
+
+	const prefixV2 = "AWS "
+	if strings.HasPrefix(auth, prefixV2) {
+		i := strings.LastIndex(auth, ":")
+		if i < len(prefixV2) {
+			return "", true, errInvalidArgument.withMessage("AWS authorization header is invalid.  Expected AwsAccessKeyId:signature")
+		}
+		return auth[len(prefixV2):i], true, nil
+	}
+
+	const prefix = "AWS4-HMAC-SHA256 "
+	if !strings.HasPrefix(auth, prefix) {
+		return "", true, nil
+	}
+
+	for _, field := range strings.Split(strings.TrimPrefix(auth, prefix), ",") {
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "Credential=") {
+			credential := strings.TrimPrefix(field, "Credential=")
+			return strings.SplitN(credential, "/", 2)[0], true, nil
+		}
+	}
+
+	return "", true, nil
+}
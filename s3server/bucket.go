@@ -0,0 +1,372 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type bucket struct {
+	name    string
+	owner   *User
+	created time.Time
+	acl     *acl
+
+	// policy is nil until a bucket policy is set.
+	policy *bucketPolicy
+
+	tags []tag
+
+	// lifecycle is nil until a lifecycle configuration is set.
+	lifecycle *lifecycleConfiguration
+
+	// cors is nil until a CORS configuration is set.
+	cors *corsConfiguration
+
+	// website is nil until a website configuration is set.
+	website *websiteConfiguration
+
+	// objectLock is nil unless the bucket was created with Object Lock
+	// enabled, which also enables versioning for good.
+	objectLock *objectLockConfiguration
+
+	// objects holds the current version of every key that has not been
+	// deleted; versions holds every version of every key, oldest first,
+	// including delete markers.
+	objects  map[string]*object
+	versions map[string][]*object
+
+	// versioning is empty until versioning is first configured.
+	versioning string
+
+	uploads map[string]*upload
+}
+
+// Bucket names are checked with the relaxed rules RGW and the legacy
+// us-east-1 region accept, so mixed-case fixture names work.
+var bucketNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._\-]{1,253}[A-Za-z0-9]$`)
+
+func (s *Server) bucket(name string) (*bucket, error) {
+
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, errNoSuchBucket
+	}
+	return b, nil
+}
+
+// bucketFor returns the requested bucket if the requester holds perm on it,
+// or the bucket policy allows the request.
+func (s *Server) bucketFor(req *request, perm string) (*bucket, error) {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.permitted(req, b, req.action, req.key, b.acl.allows(req.user, perm)) {
+		return nil, errAccessDenied
+	}
+
+	return b, nil
+}
+
+// ownedBucket returns the requested bucket if the requester owns it, or
+// the bucket policy allows the request.
+func (s *Server) ownedBucket(req *request) (*bucket, error) {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := req.user != nil && req.user.ID == b.owner.ID
+	if !s.permitted(req, b, req.action, "", owner) {
+		return nil, errAccessDenied
+	}
+
+	return b, nil
+}
+
+func (s *Server) createBucket(req *request) error {
+
+	if req.user == nil {
+		return errAccessDenied
+	}
+
+	if !bucketNameRe.MatchString(req.bucket) {
+		return errInvalidBucketName
+	}
+
+	if len(req.body) > 0 {
+		var conf struct {
+			LocationConstraint string `xml:"LocationConstraint"`
+		}
+		if err := xml.Unmarshal(req.body, &conf); err != nil {
+			return errMalformedXML
+		}
+	}
+
+	a, err := s.newACL(req.r, req.user, nil)
+	if err != nil {
+		return err
+	}
+
+	if b, ok := s.buckets[req.bucket]; ok {
+		if b.owner.ID != req.user.ID {
+			return errBucketAlreadyExists
+		}
+
+		// Like us-east-1, re-creating an owned bucket succeeds and resets its ACL.
+		b.acl = a
+		req.w.Header().Set("Location", "/"+req.bucket)
+		req.w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	b := &bucket{
+		name:     req.bucket,
+		owner:    req.user,
+		created:  s.now().UTC(),
+		acl:      a,
+		objects:  make(map[string]*object),
+		versions: make(map[string][]*object),
+		uploads:  make(map[string]*upload),
+	}
+
+	if strings.EqualFold(req.r.Header.Get("x-amz-bucket-object-lock-enabled"), "true") {
+		b.objectLock = &objectLockConfiguration{ObjectLockEnabled: "Enabled"}
+		b.versioning = versioningEnabled
+	}
+
+	s.buckets[req.bucket] = b
+
+	req.w.Header().Set("Location", "/"+req.bucket)
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) headBucket(req *request) error {
+
+	if _, err := s.bucketFor(req, permRead); err != nil {
+		return err
+	}
+
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) deleteBucket(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if len(b.versions) > 0 || len(b.uploads) > 0 {
+		return errBucketNotEmpty
+	}
+
+	delete(s.buckets, b.name)
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   *owner   `xml:"Owner"`
+	Buckets []struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	} `xml:"Buckets>Bucket"`
+}
+
+func (s *Server) listBuckets(req *request) error {
+
+	if req.user == nil {
+		return errAccessDenied
+	}
+
+	result := listAllMyBucketsResult{Owner: ownerOf(req.user)}
+
+	var names []string
+	for name, b := range s.buckets {
+		if b.owner.ID == req.user.ID {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		result.Buckets = append(result.Buckets, struct {
+			Name         string `xml:"Name"`
+			CreationDate string `xml:"CreationDate"`
+		}{name, formatTime(s.buckets[name].created)})
+	}
+
+	writeXML(req.w, http.StatusOK, result)
+
+	return nil
+}
+
+func (s *Server) getBucketLocation(req *request) error {
+
+	if _, err := s.ownedBucket(req); err != nil {
+		return err
+	}
+
+	writeXML(req.w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+		Location string   `xml:",chardata"`
+	}{Location: s.config.Region})
+
+	return nil
+}
+
+func (s *Server) getBucketACL(req *request) error {
+
+	b, err := s.bucketFor(req, permReadACP)
+	if err != nil {
+		return err
+	}
+
+	writeXML(req.w, http.StatusOK, s.policy(b.acl))
+
+	return nil
+}
+
+func (s *Server) putBucketACL(req *request) error {
+
+	b, err := s.bucketFor(req, permWriteACP)
+	if err != nil {
+		return err
+	}
+
+	a, err := s.aclFromRequest(req, b.acl, b.owner, nil)
+	if err != nil {
+		return err
+	}
+
+	b.acl = a
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// aclFromRequest reads the new ACL of a PutBucketAcl or PutObjectAcl
+// request, which may be a canned header, grant headers or a policy body.
+func (s *Server) aclFromRequest(req *request, current *acl, owner, bucketOwner *User) (*acl, error) {
+
+	if len(req.body) > 0 {
+		return s.parsePolicy(req.body, current)
+	}
+
+	return s.newACL(req.r, owner, bucketOwner)
+}
+
+type deletedEntry struct {
+	Key                   string `xml:"Key"`
+	VersionID             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionID string `xml:"DeleteMarkerVersionId,omitempty"`
+}
+
+type deleteError struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message,omitempty"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedEntry `xml:"Deleted"`
+	Errors  []deleteError  `xml:"Error"`
+}
+
+func (s *Server) deleteObjects(req *request) error {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return err
+	}
+
+	// Without a policy the ACL decides for every key at once; with one,
+	// each key is checked and those denied are reported as errors.
+	aclAllows := b.acl.allows(req.user, permWrite)
+	if b.policy == nil && !aclAllows {
+		return errAccessDenied
+	}
+
+	if err := checkContentMD5(req.r, req.body); err != nil {
+		return err
+	}
+
+	var doc struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key       string `xml:"Key"`
+			VersionID string `xml:"VersionId"`
+		} `xml:"Object"`
+	}
+
+	if err := xml.Unmarshal(req.body, &doc); err != nil {
+		return errMalformedXML
+	}
+
+	if len(doc.Objects) > 1000 {
+		return errMalformedXML
+	}
+
+	var result deleteResult
+
+	for _, o := range doc.Objects {
+		action := "s3:DeleteObject"
+		if o.VersionID != "" {
+			action = "s3:DeleteObjectVersion"
+		}
+		if !s.permitted(req, b, action, o.Key, aclAllows) {
+			result.Errors = append(result.Errors, deleteError{Key: o.Key, VersionID: o.VersionID, Code: errAccessDenied.Code, Message: "Access Denied"})
+			continue
+		}
+
+		if v, err := b.lookup(o.Key, o.VersionID); o.VersionID != "" && err == nil {
+			if v.protected(s.now(), s.bypassGovernance(req, b, o.Key)) != nil {
+				result.Errors = append(result.Errors, deleteError{Key: o.Key, VersionID: o.VersionID, Code: errObjectLocked.Code, Message: errObjectLocked.Message})
+				continue
+			}
+		}
+
+		entry := deletedEntry{Key: o.Key}
+
+		if o.VersionID != "" {
+			entry.VersionID = o.VersionID
+			if v := b.dropVersion(o.Key, o.VersionID); v != nil && v.deleteMarker {
+				entry.DeleteMarker = true
+				entry.DeleteMarkerVersionID = v.versionID
+			}
+		} else if marker := b.remove(o.Key, req.user, s.now().UTC()); marker != nil {
+			entry.DeleteMarker = true
+			entry.DeleteMarkerVersionID = marker.versionID
+		}
+
+		if !doc.Quiet {
+			result.Deleted = append(result.Deleted, entry)
+		}
+	}
+
+	writeXML(req.w, http.StatusOK, result)
+
+	return nil
+}
+
+func formatTime(t time.Time) string {
+
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
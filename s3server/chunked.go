@@ -0,0 +1,168 @@
+package s3server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// checksumHashes are the trailing checksums an aws-chunked upload may end
+// with, by header name.
+var checksumHashes = map[string]func() hash.Hash{
+	"x-amz-checksum-crc32":  func() hash.Hash { return crc32.NewIEEE() },
+	"x-amz-checksum-crc32c": func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+	"x-amz-checksum-sha1":   sha1.New,
+	"x-amz-checksum-sha256": sha256.New,
+}
+
+// isChunked reports whether a request body is aws-chunked encoded, which
+// its x-amz-content-sha256 announces.
+func isChunked(r *http.Request) bool {
+
+	return strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-")
+}
+
+// readChunkLine reads a line of an aws-chunked body, without its CRLF.
+func readChunkLine(reader *bufio.Reader) (string, error) {
+
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasSuffix(line, "\r\n") {
+		return "", errIncompleteBody
+	}
+
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// decodeChunked returns the payload of an aws-chunked request body: the
+// data of its chunks, up to the zero-length chunk that ends them. The
+// payload must be as long as x-amz-decoded-content-length says, and match
+// the checksums named by x-amz-trailer that follow the last chunk.
+// aws-chunked is taken out of the request's Content-Encoding, so it is not
+// stored with the object.
+//
+// The chunks of a STREAMING-AWS4-HMAC-SHA256-PAYLOAD body are signed, each
+// chunk signature chaining from the one before and the first from
+// signature, the verified signature of the request.
+func decodeChunked(r *http.Request, body []byte, signature *signatureV4) ([]byte, error) {
+
+	signed := strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-"+AlgorithmV4+"-PAYLOAD")
+	if signed && signature == nil {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	var previous string
+	if signed {
+		previous = signature.signature
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(body))
+
+	var data []byte
+
+	for {
+		line, err := readChunkLine(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		header := strings.SplitN(line, ";", 2)
+		size, err := strconv.ParseInt(header[0], 16, 64)
+		if err != nil || size < 0 {
+			return nil, errIncompleteBody
+		}
+
+		// The size is the client's word, so the chunk is copied rather than
+		// allocated up front: a body cannot run out of memory with it.
+		var chunk bytes.Buffer
+		if _, err := io.CopyN(&chunk, reader, size); err != nil {
+			return nil, errIncompleteBody
+		}
+
+		if signed {
+			var sent string
+			if len(header) == 2 {
+				sent = strings.TrimPrefix(header[1], "chunk-signature=")
+			}
+			previous = ChunkSignatureV4(signature.key, signature.date, signature.scope, previous, chunk.Bytes())
+			if !hmac.Equal([]byte(previous), []byte(sent)) {
+				return nil, errSignatureDoesNotMatch
+			}
+		}
+
+		if size == 0 {
+			break
+		}
+		if line, err := readChunkLine(reader); err != nil || line != "" {
+			return nil, errIncompleteBody
+		}
+
+		data = append(data, chunk.Bytes()...)
+	}
+
+	trailers := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			trailers[strings.ToLower(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	decoded, err := strconv.Atoi(r.Header.Get("X-Amz-Decoded-Content-Length"))
+	if err != nil || decoded != len(data) {
+		return nil, errIncompleteBody.withMessage("You did not provide the number of bytes specified by the x-amz-decoded-content-length HTTP header")
+	}
+
+	for _, name := range strings.Split(r.Header.Get("X-Amz-Trailer"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		newHash, ok := checksumHashes[name]
+		if !ok {
+			return nil, errInvalidRequest.withMessage("The value specified in the x-amz-trailer header is not supported")
+		}
+
+		value, ok := trailers[name]
+		if !ok {
+			return nil, errInvalidRequest.withMessage("x-amz-trailer names " + name + " but the body does not carry it")
+		}
+
+		h := newHash()
+		h.Write(data)
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != value {
+			algorithm := strings.ToUpper(strings.TrimPrefix(name, "x-amz-checksum-"))
+			return nil, errBadDigest.withMessage("The " + algorithm + " you specified did not match the calculated checksum.")
+		}
+	}
+
+	var encodings []string
+	for _, encoding := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
+		if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != "aws-chunked" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	if len(encodings) > 0 {
+		r.Header.Set("Content-Encoding", strings.Join(encodings, ","))
+	} else {
+		r.Header.Del("Content-Encoding")
+	}
+
+	return data, nil
+}
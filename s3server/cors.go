@@ -0,0 +1,273 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type corsConfiguration struct {
+	XMLName xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CORSConfiguration"`
+	Rules   []corsRule `xml:"CORSRule"`
+}
+
+type corsRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader"`
+	ExposeHeaders  []string `xml:"ExposeHeader"`
+	MaxAgeSeconds  *int     `xml:"MaxAgeSeconds"`
+}
+
+// corsMethods are the methods a CORS rule may allow.
+var corsMethods = map[string]bool{"GET": true, "PUT": true, "HEAD": true, "POST": true, "DELETE": true}
+
+// corsVary lists the request headers a response to a bucket with a CORS
+// configuration depends on.
+const corsVary = "Origin, Access-Control-Request-Headers, Access-Control-Request-Method"
+
+// wildcardMatch matches s against a pattern holding at most one *, which
+// stands for any run of characters.
+func wildcardMatch(pattern, s string) bool {
+
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return pattern == s
+	}
+
+	prefix, suffix := pattern[:i], pattern[i+1:]
+
+	return len(s) >= len(prefix)+len(suffix) && strings.HasPrefix(s, prefix) && strings.HasSuffix(s, suffix)
+}
+
+// check validates a rule on its own.
+func (r *corsRule) check() error {
+
+	if len(r.AllowedOrigins) == 0 || len(r.AllowedMethods) == 0 {
+		return errMalformedXML
+	}
+
+	for _, m := range r.AllowedMethods {
+		if !corsMethods[m] {
+			return errInvalidRequest.withMessage("Found unsupported HTTP method in CORS config. Unsupported method is " + m)
+		}
+	}
+
+	for _, o := range r.AllowedOrigins {
+		if strings.Count(o, "*") > 1 {
+			return errInvalidRequest.withMessage("AllowedOrigin \"" + o + "\" can not have more than one wildcard.")
+		}
+	}
+
+	for _, h := range r.AllowedHeaders {
+		if strings.Count(h, "*") > 1 {
+			return errInvalidRequest.withMessage("AllowedHeader \"" + h + "\" can not have more than one wildcard.")
+		}
+	}
+
+	return nil
+}
+
+// allowsOrigin returns the allowed origin of the rule that origin matches.
+func (r *corsRule) allowsOrigin(origin string) (string, bool) {
+
+	for _, o := range r.AllowedOrigins {
+		if wildcardMatch(o, origin) {
+			return o, true
+		}
+	}
+	return "", false
+}
+
+func (r *corsRule) allowsMethod(method string) bool {
+
+	for _, m := range r.AllowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether every one of headers matches an allowed
+// header of the rule. Header names are compared case-insensitively.
+func (r *corsRule) allowsHeaders(headers []string) bool {
+
+next:
+	for _, h := range headers {
+		for _, allowed := range r.AllowedHeaders {
+			if wildcardMatch(strings.ToLower(allowed), strings.ToLower(h)) {
+				continue next
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// match returns the first rule allowing a cross-origin request, and the
+// allowed origin it matched.
+func (c *corsConfiguration) match(origin, method string, headers []string) (*corsRule, string) {
+
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if o, ok := r.allowsOrigin(origin); ok && r.allowsMethod(method) && r.allowsHeaders(headers) {
+			return r, o
+		}
+	}
+	return nil, ""
+}
+
+// writeCORSHeaders sets the Access-Control-Allow-* headers for a request
+// from origin that a rule allows. An origin allowed by the * wildcard is
+// answered with *; any other is echoed, with credentials allowed.
+func writeCORSHeaders(h http.Header, r *corsRule, allowed, origin string) {
+
+	if allowed == "*" {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	h.Set("Access-Control-Allow-Methods", strings.Join(r.AllowedMethods, ", "))
+
+	if len(r.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(r.ExposeHeaders, ", "))
+	}
+	if r.MaxAgeSeconds != nil {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(*r.MaxAgeSeconds))
+	}
+}
+
+// corsHeaders sets the CORS headers of an actual request, one that is not
+// a preflight, from an origin the bucket allows. Requests from other
+// origins are served without them.
+func (s *Server) corsHeaders(req *request) {
+
+	b, ok := s.buckets[req.bucket]
+	if !ok || b.cors == nil {
+		return
+	}
+
+	req.w.Header().Set("Vary", corsVary)
+
+	origin := req.r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+
+	if r, allowed := b.cors.match(origin, req.r.Method, nil); r != nil {
+		writeCORSHeaders(req.w.Header(), r, allowed, origin)
+	}
+}
+
+// preflight answers an OPTIONS request asking whether a cross-origin
+// request may be sent. It needs no credentials.
+func (s *Server) preflight(req *request) error {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return err
+	}
+
+	origin := req.r.Header.Get("Origin")
+	if origin == "" {
+		return errBadRequest.withMessage("Insufficient information. Origin request header needed.")
+	}
+
+	method := req.r.Header.Get("Access-Control-Request-Method")
+	if method == "" {
+		return errBadRequest.withMessage("Invalid Access-Control-Request-Method: null")
+	}
+
+	if b.cors == nil {
+		return errAccessForbidden.withMessage("CORSResponse: CORS is not enabled for this bucket.")
+	}
+
+	var headers []string
+	for _, h := range strings.Split(req.r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, h)
+		}
+	}
+
+	req.w.Header().Set("Vary", corsVary)
+
+	r, allowed := b.cors.match(origin, method, headers)
+	if r == nil {
+		return errAccessForbidden.withMessage("CORSResponse: This CORS request is not allowed. This is usually because the evalution of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.")
+	}
+
+	writeCORSHeaders(req.w.Header(), r, allowed, origin)
+	if len(headers) > 0 {
+		req.w.Header().Set("Access-Control-Allow-Headers", strings.ToLower(strings.Join(headers, ", ")))
+	}
+
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) putBucketCors(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := req.r.Header["Content-Md5"]; !ok {
+		return errInvalidRequest.withMessage("Missing required header for this request: Content-Md5")
+	}
+
+	if err := checkContentMD5(req.r, req.body); err != nil {
+		return err
+	}
+
+	var c corsConfiguration
+	if err := xml.Unmarshal(req.body, &c); err != nil || len(c.Rules) == 0 {
+		return errMalformedXML
+	}
+
+	for i := range c.Rules {
+		if err := c.Rules[i].check(); err != nil {
+			return err
+		}
+	}
+
+	b.cors = &c
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) getBucketCors(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if b.cors == nil {
+		return errNoSuchCORSConfiguration
+	}
+
+	writeXML(req.w, http.StatusOK, b.cors)
+
+	return nil
+}
+
+func (s *Server) deleteBucketCors(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	b.cors = nil
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// Error is an S3 error response.
+type Error struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *Error) Error() string {
+
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+// newError returns an error with no message. Like RGW, which this suite
+// was written against, only errors that need explaining carry one.
+func newError(status int, code string) *Error {
+
+	return &Error{Code: code, Status: status}
+}
+
+var (
+	errAccessDenied                    = newError(http.StatusForbidden, "AccessDenied")
+	errAccessForbidden                 = newError(http.StatusForbidden, "AccessForbidden")
+	errAuthorizationHeaderMalformed    = newError(http.StatusBadRequest, "AuthorizationHeaderMalformed")
+	errAuthorizationQueryParameters    = newError(http.StatusBadRequest, "AuthorizationQueryParametersError")
+	errBadDigest                       = newError(http.StatusBadRequest, "BadDigest")
+	errBadRequest                      = newError(http.StatusBadRequest, "BadRequest")
+	errBucketAlreadyExists             = newError(http.StatusConflict, "BucketAlreadyExists")
+	errBucketNotEmpty                  = newError(http.StatusConflict, "BucketNotEmpty")
+	errEntityTooLarge                  = newError(http.StatusBadRequest, "EntityTooLarge")
+	errEntityTooSmall                  = newError(http.StatusBadRequest, "EntityTooSmall")
+	errIncompleteBody                  = newError(http.StatusBadRequest, "IncompleteBody")
+	errInvalidAccessKeyID              = newError(http.StatusForbidden, "InvalidAccessKeyId")
+	errInvalidArgument                 = newError(http.StatusBadRequest, "InvalidArgument")
+	errInvalidBucketName               = newError(http.StatusBadRequest, "InvalidBucketName")
+	errInvalidBucketState              = newError(http.StatusConflict, "InvalidBucketState")
+	errInvalidDigest                   = newError(http.StatusBadRequest, "InvalidDigest")
+	errInvalidPart                     = newError(http.StatusBadRequest, "InvalidPart")
+	errInvalidPartOrder                = newError(http.StatusBadRequest, "InvalidPartOrder")
+	errInvalidPolicyDocument           = newError(http.StatusBadRequest, "InvalidPolicyDocument")
+	errInvalidRange                    = newError(http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+	errInvalidRequest                  = newError(http.StatusBadRequest, "InvalidRequest")
+	errInvalidTag                      = newError(http.StatusBadRequest, "InvalidTag")
+	errMalformedPolicy                 = newError(http.StatusBadRequest, "MalformedPolicy")
+	errMalformedPOSTRequest            = newError(http.StatusBadRequest, "MalformedPOSTRequest").withMessage("The body of your POST request is not well-formed multipart/form-data.")
+	errMalformedXML                    = newError(http.StatusBadRequest, "MalformedXML")
+	errMethodNotAllowed                = newError(http.StatusMethodNotAllowed, "MethodNotAllowed")
+	errNoSuchBucket                    = newError(http.StatusNotFound, "NoSuchBucket")
+	errNoSuchBucketPolicy              = newError(http.StatusNotFound, "NoSuchBucketPolicy").withMessage("The bucket policy does not exist")
+	errNoSuchCORSConfiguration         = newError(http.StatusNotFound, "NoSuchCORSConfiguration").withMessage("The CORS configuration does not exist")
+	errNoSuchKey                       = newError(http.StatusNotFound, "NoSuchKey")
+	errNoSuchLifecycleConfiguration    = newError(http.StatusNotFound, "NoSuchLifecycleConfiguration")
+	errNoSuchObjectLockConfiguration   = newError(http.StatusNotFound, "NoSuchObjectLockConfiguration").withMessage("The specified object does not have a ObjectLock configuration")
+	errNoSuchTagSet                    = newError(http.StatusNotFound, "NoSuchTagSet").withMessage("The TagSet does not exist")
+	errNoSuchUpload                    = newError(http.StatusNotFound, "NoSuchUpload")
+	errNoSuchVersion                   = newError(http.StatusNotFound, "NoSuchVersion")
+	errNoSuchWebsiteConfiguration      = newError(http.StatusNotFound, "NoSuchWebsiteConfiguration").withMessage("The specified bucket does not have a website configuration")
+	errNotImplemented                  = newError(http.StatusNotImplemented, "NotImplemented")
+	errNotModified                     = newError(http.StatusNotModified, "NotModified")
+	errObjectLockConfigurationNotFound = newError(http.StatusNotFound, "ObjectLockConfigurationNotFoundError").withMessage("Object Lock configuration does not exist for this bucket")
+	errObjectLocked                    = newError(http.StatusForbidden, "AccessDenied").withMessage("Access Denied because object protected by object lock.")
+	errPreconditionFailed              = newError(http.StatusPreconditionFailed, "PreconditionFailed")
+	errRequestTimeTooSkewed            = newError(http.StatusForbidden, "RequestTimeTooSkewed").withMessage("The difference between the request time and the current time is too large.")
+	errSignatureDoesNotMatch           = newError(http.StatusForbidden, "SignatureDoesNotMatch").withMessage("The request signature we calculated does not match the signature you provided. Check your key and signing method.")
+	errSSECustomerKeyRequired          = newError(http.StatusBadRequest, "InvalidRequest").withMessage("The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+	errUnresolvableGrantByEmail        = newError(http.StatusBadRequest, "UnresolvableGrantByEmailAddress")
+	errXAmzContentSHA256Mismatch       = newError(http.StatusBadRequest, "XAmzContentSHA256Mismatch").withMessage("The provided 'x-amz-content-sha256' header does not match what was computed.")
+)
+
+// withMessage returns a copy of e carrying a more specific message.
+func (e *Error) withMessage(message string) *Error {
+
+	return &Error{Code: e.Code, Message: message, Status: e.Status}
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message,omitempty"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId"`
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+
+	e, ok := err.(*Error)
+	if !ok {
+		e = newError(http.StatusInternalServerError, "InternalError").withMessage(err.Error())
+	}
+
+	// HEAD responses and 304s carry no body; clients fall back to the status.
+	if r.Method == "HEAD" || e.Status == http.StatusNotModified {
+		w.WriteHeader(e.Status)
+		return
+	}
+
+	writeXML(w, e.Status, errorResponse{
+		Code:      e.Code,
+		Message:   e.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("x-amz-request-id"),
+	})
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+
+	body, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
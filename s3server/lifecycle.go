@@ -0,0 +1,466 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLifecycleRules is the most rules a lifecycle configuration may hold.
+const maxLifecycleRules = 1000
+
+type lifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LifecycleConfiguration"`
+	Rules   []lifecycleRule `xml:"Rule"`
+}
+
+type lifecycleRule struct {
+	ID     string           `xml:"ID,omitempty"`
+	Prefix *string          `xml:"Prefix"`
+	Filter *lifecycleFilter `xml:"Filter"`
+	Status string           `xml:"Status"`
+
+	Expiration                     *lifecycleExpiration      `xml:"Expiration"`
+	Transitions                    []lifecycleTransition     `xml:"Transition"`
+	NoncurrentVersionExpiration    *noncurrentExpiration     `xml:"NoncurrentVersionExpiration"`
+	NoncurrentVersionTransitions   []noncurrentTransition    `xml:"NoncurrentVersionTransition"`
+	AbortIncompleteMultipartUpload *abortIncompleteMultipart `xml:"AbortIncompleteMultipartUpload"`
+}
+
+type lifecycleFilter struct {
+	Prefix *string `xml:"Prefix"`
+	Tag    *tag    `xml:"Tag"`
+	And    *struct {
+		Prefix string `xml:"Prefix,omitempty"`
+		Tags   []tag  `xml:"Tag"`
+	} `xml:"And"`
+}
+
+type lifecycleExpiration struct {
+	Days                      *int   `xml:"Days"`
+	Date                      string `xml:"Date,omitempty"`
+	ExpiredObjectDeleteMarker *bool  `xml:"ExpiredObjectDeleteMarker"`
+}
+
+type lifecycleTransition struct {
+	Days         *int   `xml:"Days"`
+	Date         string `xml:"Date,omitempty"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type noncurrentExpiration struct {
+	NoncurrentDays int `xml:"NoncurrentDays"`
+}
+
+type noncurrentTransition struct {
+	NoncurrentDays int    `xml:"NoncurrentDays"`
+	StorageClass   string `xml:"StorageClass"`
+}
+
+type abortIncompleteMultipart struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+// transitionMinDays are the storage classes objects may transition to,
+// with the fewest days they must have been stored first.
+var transitionMinDays = map[string]int{
+	"STANDARD_IA":         30,
+	"ONEZONE_IA":          30,
+	"INTELLIGENT_TIERING": 0,
+	"GLACIER":             0,
+	"GLACIER_IR":          0,
+	"DEEP_ARCHIVE":        0,
+}
+
+// prefix returns the key prefix the rule applies to, wherever it is set.
+func (r *lifecycleRule) prefix() string {
+
+	switch {
+	case r.Prefix != nil:
+		return *r.Prefix
+	case r.Filter == nil:
+		return ""
+	case r.Filter.Prefix != nil:
+		return *r.Filter.Prefix
+	case r.Filter.And != nil:
+		return r.Filter.And.Prefix
+	}
+	return ""
+}
+
+// tags returns the tags an object must carry for the rule to apply to it.
+func (r *lifecycleRule) tags() []tag {
+
+	switch {
+	case r.Filter == nil:
+		return nil
+	case r.Filter.Tag != nil:
+		return []tag{*r.Filter.Tag}
+	case r.Filter.And != nil:
+		return r.Filter.And.Tags
+	}
+	return nil
+}
+
+// positiveDays checks the number of days of an action.
+func positiveDays(days int, field, action string) error {
+
+	if days <= 0 {
+		return errInvalidArgument.withMessage("'" + field + "' for " + action + " action must be a positive integer")
+	}
+	return nil
+}
+
+// midnightDate checks a lifecycle date, which must be midnight UTC.
+func midnightDate(date string) error {
+
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return errInvalidArgument.withMessage("Invalid date format: " + date)
+	}
+
+	if !t.Equal(t.UTC().Truncate(24 * time.Hour)) {
+		return errInvalidArgument.withMessage("'Date' must be at midnight GMT")
+	}
+
+	return nil
+}
+
+// check validates a rule on its own.
+func (r *lifecycleRule) check() error {
+
+	if len(r.ID) > 255 {
+		return errInvalidArgument.withMessage("ID length should not exceed allowed limit of 255")
+	}
+
+	if r.Status != "Enabled" && r.Status != "Disabled" {
+		return errMalformedXML
+	}
+
+	if r.Prefix != nil && r.Filter != nil {
+		return errMalformedXML
+	}
+
+	if f := r.Filter; f != nil {
+		set := 0
+		for _, present := range []bool{f.Prefix != nil, f.Tag != nil, f.And != nil} {
+			if present {
+				set++
+			}
+		}
+		if set > 1 {
+			return errMalformedXML
+		}
+	}
+
+	if err := checkTags(r.tags()); err != nil {
+		return err
+	}
+
+	if r.Expiration == nil && len(r.Transitions) == 0 && r.NoncurrentVersionExpiration == nil &&
+		len(r.NoncurrentVersionTransitions) == 0 && r.AbortIncompleteMultipartUpload == nil {
+		return errInvalidRequest.withMessage("At least one action needs to be specified in a rule")
+	}
+
+	if e := r.Expiration; e != nil {
+		set := 0
+		for _, present := range []bool{e.Days != nil, e.Date != "", e.ExpiredObjectDeleteMarker != nil} {
+			if present {
+				set++
+			}
+		}
+
+		switch {
+		case set != 1:
+			return errMalformedXML
+		case e.Date != "":
+			if err := midnightDate(e.Date); err != nil {
+				return err
+			}
+		case e.Days != nil:
+			if err := positiveDays(*e.Days, "Days", "Expiration"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, t := range r.Transitions {
+		min, ok := transitionMinDays[t.StorageClass]
+		if !ok {
+			return errMalformedXML
+		}
+
+		switch {
+		case (t.Days == nil) == (t.Date == ""):
+			return errMalformedXML
+		case t.Date != "":
+			if err := midnightDate(t.Date); err != nil {
+				return err
+			}
+		case *t.Days < min:
+			return errInvalidArgument.withMessage("'Days' in Transition action must be greater than or equal to " + strconv.Itoa(min) + " for storageClass '" + t.StorageClass + "'")
+		}
+
+		if t.Days != nil && r.Expiration != nil && r.Expiration.Days != nil && *t.Days >= *r.Expiration.Days {
+			return errInvalidArgument.withMessage("'Days' in the Expiration action for filter '" + r.prefix() + "' must be greater than 'Days' in the Transition action")
+		}
+	}
+
+	if n := r.NoncurrentVersionExpiration; n != nil {
+		if err := positiveDays(n.NoncurrentDays, "NoncurrentDays", "NoncurrentVersionExpiration"); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range r.NoncurrentVersionTransitions {
+		if _, ok := transitionMinDays[t.StorageClass]; !ok {
+			return errMalformedXML
+		}
+		if err := positiveDays(t.NoncurrentDays, "NoncurrentDays", "NoncurrentVersionTransition"); err != nil {
+			return err
+		}
+	}
+
+	if a := r.AbortIncompleteMultipartUpload; a != nil {
+		if len(r.tags()) > 0 {
+			return errInvalidRequest.withMessage("AbortIncompleteMultipartUpload cannot be specified with Tags.")
+		}
+		if err := positiveDays(a.DaysAfterInitiation, "DaysAfterInitiation", "AbortIncompleteMultipartUpload"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseLifecycle parses and validates a lifecycle configuration. Like RGW,
+// it refuses two expiration rules whose prefixes overlap, since which one
+// expires a key would then be ambiguous.
+func parseLifecycle(body []byte) (*lifecycleConfiguration, error) {
+
+	var c lifecycleConfiguration
+	if err := xml.Unmarshal(body, &c); err != nil || len(c.Rules) == 0 {
+		return nil, errMalformedXML
+	}
+
+	if len(c.Rules) > maxLifecycleRules {
+		return nil, errMalformedXML.withMessage("The number of rules in the lifecycle configuration cannot exceed " + strconv.Itoa(maxLifecycleRules))
+	}
+
+	ids := make(map[string]bool)
+
+	for i := range c.Rules {
+		r := &c.Rules[i]
+
+		if r.ID == "" {
+			r.ID = newID(16)
+		}
+		if ids[r.ID] {
+			return nil, errInvalidArgument.withMessage("Rule ID must be unique. Found same ID for more than one rule")
+		}
+		ids[r.ID] = true
+
+		if err := r.check(); err != nil {
+			return nil, err
+		}
+
+		for _, other := range c.Rules[:i] {
+			if r.Expiration == nil || other.Expiration == nil || len(r.tags()) > 0 || len(other.tags()) > 0 {
+				continue
+			}
+			p, q := r.prefix(), other.prefix()
+			if strings.HasPrefix(p, q) || strings.HasPrefix(q, p) {
+				return nil, errInvalidRequest.withMessage("Found overlapping prefixes '" + q + "' and '" + p + "' for same action type 'Expiration'")
+			}
+		}
+	}
+
+	return &c, nil
+}
+
+func (s *Server) putBucketLifecycle(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := req.r.Header["Content-Md5"]; !ok {
+		return errInvalidRequest.withMessage("Missing required header for this request: Content-Md5")
+	}
+
+	if err := checkContentMD5(req.r, req.body); err != nil {
+		return err
+	}
+
+	c, err := parseLifecycle(req.body)
+	if err != nil {
+		return err
+	}
+
+	b.lifecycle = c
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) getBucketLifecycle(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if b.lifecycle == nil {
+		return errNoSuchLifecycleConfiguration
+	}
+
+	writeXML(req.w, http.StatusOK, b.lifecycle)
+
+	return nil
+}
+
+func (s *Server) deleteBucketLifecycle(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	b.lifecycle = nil
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// matches reports whether the rule applies to a version of an object: its
+// key has the rule's prefix and it carries every tag the rule filters on.
+func (r *lifecycleRule) matches(obj *object) bool {
+
+	if !strings.HasPrefix(obj.key, r.prefix()) {
+		return false
+	}
+
+next:
+	for _, want := range r.tags() {
+		for _, t := range obj.tags {
+			if t == want {
+				continue next
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// lifecycleDay returns how long a day of a lifecycle rule lasts.
+func (s *Server) lifecycleDay() time.Duration {
+
+	if s.config.LifecycleDebugInterval > 0 {
+		return s.config.LifecycleDebugInterval
+	}
+	return 24 * time.Hour
+}
+
+// elapsed reports whether days lifecycle days have passed between since
+// and now.
+func (s *Server) elapsed(since time.Time, days int, now time.Time) bool {
+
+	return !now.Before(since.Add(time.Duration(days) * s.lifecycleDay()))
+}
+
+// expired reports whether the current version obj is due for expiration.
+func (s *Server) expired(e *lifecycleExpiration, obj *object, now time.Time) bool {
+
+	switch {
+	case e.Days != nil:
+		return s.elapsed(obj.lastModified, *e.Days, now)
+	case e.Date != "":
+		date, err := time.Parse(time.RFC3339, e.Date)
+		return err == nil && !now.Before(date)
+	}
+	return false
+}
+
+// applyLifecycle carries out the enabled lifecycle rules of every bucket:
+// current versions expire, leaving a delete marker when versioning is
+// configured, noncurrent versions and lone delete markers are removed, and
+// incomplete multipart uploads are aborted. The caller holds s.mu.
+func (s *Server) applyLifecycle() {
+
+	now := s.now().UTC()
+
+	for _, b := range s.buckets {
+		if b.lifecycle == nil {
+			continue
+		}
+
+		for i := range b.lifecycle.Rules {
+			if r := &b.lifecycle.Rules[i]; r.Status == "Enabled" {
+				s.applyRule(b, r, now)
+			}
+		}
+	}
+}
+
+func (s *Server) applyRule(b *bucket, r *lifecycleRule, now time.Time) {
+
+	var keys []string
+	for key := range b.versions {
+		if strings.HasPrefix(key, r.prefix()) {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if e := r.Expiration; e != nil {
+			if obj, ok := b.objects[key]; ok && r.matches(obj) && s.expired(e, obj, now) {
+				b.remove(key, b.owner, now)
+			}
+		}
+
+		if n := r.NoncurrentVersionExpiration; n != nil {
+			versions := b.versions[key]
+			for i := 0; i < len(versions)-1; i++ {
+				if r.matches(versions[i]) && s.elapsed(versions[i+1].lastModified, n.NoncurrentDays, now) && versions[i].protected(now, false) == nil {
+					b.dropVersion(key, versions[i].versionID)
+				}
+			}
+		}
+
+		if e := r.Expiration; e != nil && e.ExpiredObjectDeleteMarker != nil && *e.ExpiredObjectDeleteMarker {
+			if versions := b.versions[key]; len(versions) == 1 && versions[0].deleteMarker {
+				b.dropVersion(key, versions[0].versionID)
+			}
+		}
+	}
+
+	if a := r.AbortIncompleteMultipartUpload; a != nil {
+		for id, u := range b.uploads {
+			if strings.HasPrefix(u.key, r.prefix()) && s.elapsed(u.initiated, a.DaysAfterInitiation, now) {
+				delete(b.uploads, id)
+			}
+		}
+	}
+}
+
+// runLifecycle applies lifecycle rules every interval until done is closed.
+func (s *Server) runLifecycle(interval time.Duration, done <-chan struct{}) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.applyLifecycle()
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package s3server
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type listEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+	Owner        *owner `xml:"Owner,omitempty"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name           string         `xml:"Name"`
+	Prefix         string         `xml:"Prefix"`
+	Marker         string         `xml:"Marker"`
+	NextMarker     string         `xml:"NextMarker,omitempty"`
+	MaxKeys        int            `xml:"MaxKeys"`
+	Delimiter      string         `xml:"Delimiter,omitempty"`
+	EncodingType   string         `xml:"EncodingType,omitempty"`
+	IsTruncated    bool           `xml:"IsTruncated"`
+	Contents       []listEntry    `xml:"Contents"`
+	CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type listBucketV2Result struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []listEntry    `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// listing is the outcome of walking a bucket's keys.
+type listing struct {
+	keys      []string
+	prefixes  []string
+	truncated bool
+	last      string
+}
+
+// walk lists the keys of b that sort after marker, rolling keys that share
+// a prefix up to the delimiter into common prefixes, until maxKeys entries
+// have been collected.
+func walk(b *bucket, prefix, delimiter, marker string, maxKeys int) listing {
+
+	var names []string
+	for name := range b.objects {
+		if strings.HasPrefix(name, prefix) && name > marker {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var l listing
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		entry, isPrefix := name, false
+
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				entry, isPrefix = name[:len(prefix)+i+len(delimiter)], true
+				if seen[entry] || entry <= marker {
+					continue
+				}
+			}
+		}
+
+		if len(l.keys)+len(l.prefixes) >= maxKeys {
+			// Like S3, an empty page asked for with max-keys=0 is not truncated.
+			l.truncated = maxKeys > 0
+			break
+		}
+
+		if isPrefix {
+			seen[entry] = true
+			l.prefixes = append(l.prefixes, entry)
+		} else {
+			l.keys = append(l.keys, entry)
+		}
+		l.last = entry
+	}
+
+	return l
+}
+
+// maxKeysParam parses the max-keys style parameter name, defaulting to 1000.
+func maxKeysParam(req *request, name string) (int, error) {
+
+	v := req.param(name)
+	if v == "" {
+		return 1000, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errInvalidArgument.withMessage("Provided " + name + " not an integer or within integer range")
+	}
+
+	if n > 1000 {
+		n = 1000
+	}
+
+	return n, nil
+}
+
+// encodingParam reads the encoding-type parameter. It returns a function
+// that encodes the keys and prefixes of the listing accordingly.
+func encodingParam(req *request) (string, func(string) string, error) {
+
+	switch encoding := req.param("encoding-type"); encoding {
+	case "":
+		return "", func(s string) string { return s }, nil
+	case "url":
+		return encoding, urlEncode, nil
+	default:
+		return "", nil, errInvalidArgument.withMessage("Invalid Encoding Method specified in Request")
+	}
+}
+
+// urlEncode percent-encodes every byte of s but unreserved characters and
+// slashes, so that keys XML cannot carry survive the listing.
+func urlEncode(s string) string {
+
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+
+	return b.String()
+}
+
+// listEntries returns the listing entries for keys of b.
+func listEntries(b *bucket, keys []string, withOwner bool, encode func(string) string) []listEntry {
+
+	var entries []listEntry
+
+	for _, key := range keys {
+		obj := b.objects[key]
+		entry := listEntry{
+			Key:          encode(key),
+			LastModified: formatTime(obj.lastModified),
+			ETag:         obj.etag,
+			Size:         len(obj.data),
+			StorageClass: "STANDARD",
+		}
+		if withOwner {
+			entry.Owner = ownerOf(obj.owner)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func commonPrefixes(prefixes []string, encode func(string) string) []commonPrefix {
+
+	var list []commonPrefix
+	for _, p := range prefixes {
+		list = append(list, commonPrefix{encode(p)})
+	}
+	return list
+}
+
+func (s *Server) listObjects(req *request) error {
+
+	b, err := s.bucketFor(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	maxKeys, err := maxKeysParam(req, "max-keys")
+	if err != nil {
+		return err
+	}
+
+	encoding, encode, err := encodingParam(req)
+	if err != nil {
+		return err
+	}
+
+	prefix, delimiter, marker := req.param("prefix"), req.param("delimiter"), req.param("marker")
+
+	l := walk(b, prefix, delimiter, marker, maxKeys)
+
+	result := listBucketResult{
+		Name:           b.name,
+		Prefix:         encode(prefix),
+		Marker:         encode(marker),
+		MaxKeys:        maxKeys,
+		Delimiter:      encode(delimiter),
+		EncodingType:   encoding,
+		IsTruncated:    l.truncated,
+		Contents:       listEntries(b, l.keys, true, encode),
+		CommonPrefixes: commonPrefixes(l.prefixes, encode),
+	}
+
+	// NextMarker is only returned when a delimiter is used; otherwise
+	// clients continue from the last key.
+	if l.truncated && delimiter != "" {
+		result.NextMarker = encode(l.last)
+	}
+
+	writeXML(req.w, http.StatusOK, result)
+
+	return nil
+}
+
+// listObjectsV2 answers GET ?list-type=2. Continuation tokens are the last
+// entry of the previous page, base64 encoded so that clients treat them as
+// opaque; a token takes precedence over start-after.
+func (s *Server) listObjectsV2(req *request) error {
+
+	b, err := s.bucketFor(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	maxKeys, err := maxKeysParam(req, "max-keys")
+	if err != nil {
+		return err
+	}
+
+	encoding, encode, err := encodingParam(req)
+	if err != nil {
+		return err
+	}
+
+	prefix, delimiter := req.param("prefix"), req.param("delimiter")
+	startAfter, token := req.param("start-after"), req.param("continuation-token")
+
+	marker := startAfter
+	if req.has("continuation-token") {
+		last, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil || len(last) == 0 {
+			return errInvalidArgument.withMessage("The continuation token provided is incorrect")
+		}
+		marker = string(last)
+	}
+
+	l := walk(b, prefix, delimiter, marker, maxKeys)
+
+	result := listBucketV2Result{
+		Name:              b.name,
+		Prefix:            encode(prefix),
+		StartAfter:        encode(startAfter),
+		ContinuationToken: token,
+		KeyCount:          len(l.keys) + len(l.prefixes),
+		MaxKeys:           maxKeys,
+		Delimiter:         encode(delimiter),
+		EncodingType:      encoding,
+		IsTruncated:       l.truncated,
+		Contents:          listEntries(b, l.keys, req.param("fetch-owner") == "true", encode),
+		CommonPrefixes:    commonPrefixes(l.prefixes, encode),
+	}
+
+	if l.truncated {
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(l.last))
+	}
+
+	writeXML(req.w, http.StatusOK, result)
+
+	return nil
+}
@@ -0,0 +1,513 @@
+package s3server
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type part struct {
+	number       int
+	data         []byte
+	etag         string
+	lastModified time.Time
+}
+
+type upload struct {
+	id        string
+	key       string
+	initiated time.Time
+	owner     *User
+	acl       *acl
+	header    http.Header
+	sse       string
+	parts     map[int]*part
+	tags      []tag
+
+	retention *objectRetention
+	legalHold string
+
+	sseCustomerKeyMD5 string
+}
+
+// upload returns the in-progress upload named by the uploadId parameter.
+func (s *Server) upload(req *request, b *bucket) (*upload, error) {
+
+	u, ok := b.uploads[req.param("uploadId")]
+	if !ok || u.key != req.key {
+		return nil, errNoSuchUpload
+	}
+	return u, nil
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+func (s *Server) createMultipartUpload(req *request) error {
+
+	b, err := s.bucketFor(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	keyMD5, err := sseCustomerKey(req.r.Header, "x-amz-server-side-encryption-customer-")
+	if err != nil {
+		return err
+	}
+
+	sse, err := serverSideEncryption(req.r)
+	if err != nil {
+		return err
+	}
+
+	tags, err := taggingHeader(req.r)
+	if err != nil {
+		return err
+	}
+
+	retention, legalHold, err := s.lockFromRequest(req.r, b)
+	if err != nil {
+		return err
+	}
+
+	a, err := s.newACL(req.r, req.user, b.owner)
+	if err != nil {
+		return err
+	}
+
+	u := &upload{
+		id:                newID(16),
+		key:               req.key,
+		initiated:         s.now().UTC(),
+		owner:             req.user,
+		acl:               a,
+		header:            objectHeaders(req.r),
+		sse:               sse,
+		parts:             make(map[int]*part),
+		tags:              tags,
+		sseCustomerKeyMD5: keyMD5,
+		retention:         retention,
+		legalHold:         legalHold,
+	}
+
+	b.uploads[u.id] = u
+
+	writeEncryptionHeaders(req.w.Header(), &object{sse: sse, sseCustomerKeyMD5: keyMD5})
+	writeXML(req.w, http.StatusOK, initiateMultipartUploadResult{
+		Bucket:   b.name,
+		Key:      req.key,
+		UploadID: u.id,
+	})
+
+	return nil
+}
+
+// partNumber parses the partNumber parameter, which must be in 1..10000.
+func partNumber(req *request) (int, error) {
+
+	n, err := strconv.Atoi(req.param("partNumber"))
+	if err != nil || n < 1 || n > 10000 {
+		return 0, errInvalidArgument.withMessage("Part number must be an integer between 1 and 10000, inclusive")
+	}
+	return n, nil
+}
+
+type copyPartResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyPartResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+func (s *Server) uploadPart(req *request) error {
+
+	b, err := s.bucketFor(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.upload(req, b)
+	if err != nil {
+		return err
+	}
+
+	n, err := partNumber(req)
+	if err != nil {
+		return err
+	}
+
+	copying := req.r.Header.Get("x-amz-copy-source") != ""
+
+	data := req.body
+	if copying {
+		src, err := s.copySource(req)
+		if err != nil {
+			return err
+		}
+
+		data = src.data
+		if v := req.r.Header.Get("x-amz-copy-source-range"); v != "" {
+			start, end, ok, err := parseRange(v, int64(len(src.data)))
+			if err != nil || !ok {
+				return errInvalidArgument.withMessage("The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+			}
+			data = src.data[start : end+1]
+		}
+	} else if err := checkContentMD5(req.r, data); err != nil {
+		return err
+	}
+
+	keyMD5, err := sseCustomerKey(req.r.Header, "x-amz-server-side-encryption-customer-")
+	if err != nil {
+		return err
+	}
+
+	if keyMD5 != u.sseCustomerKeyMD5 {
+		return errInvalidRequest.withMessage("The provided encryption parameters did not match the ones used originally.")
+	}
+
+	p := &part{number: n, data: data, etag: etagOf(data), lastModified: s.now().UTC()}
+	u.parts[n] = p
+
+	if copying {
+		writeXML(req.w, http.StatusOK, copyPartResult{
+			LastModified: formatTime(p.lastModified),
+			ETag:         p.etag,
+		})
+		return nil
+	}
+
+	req.w.Header().Set("ETag", p.etag)
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+func (s *Server) completeMultipartUpload(req *request) error {
+
+	b, err := s.bucketFor(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.upload(req, b)
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+
+	if err := xml.Unmarshal(req.body, &doc); err != nil || len(doc.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	var data []byte
+	sums := md5.New()
+
+	for i, p := range doc.Parts {
+		if i > 0 && p.PartNumber <= doc.Parts[i-1].PartNumber {
+			return errInvalidPartOrder
+		}
+
+		stored, ok := u.parts[p.PartNumber]
+		if !ok || strings.Trim(p.ETag, `"`) != strings.Trim(stored.etag, `"`) {
+			return errInvalidPart
+		}
+
+		if i < len(doc.Parts)-1 && int64(len(stored.data)) < s.config.MinPartSize {
+			return errEntityTooSmall
+		}
+
+		data = append(data, stored.data...)
+
+		raw, _ := hex.DecodeString(strings.Trim(stored.etag, `"`))
+		sums.Write(raw)
+	}
+
+	obj := &object{
+		key:               u.key,
+		data:              data,
+		etag:              fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sums.Sum(nil)), len(doc.Parts)),
+		lastModified:      s.now().UTC(),
+		owner:             u.owner,
+		acl:               u.acl,
+		header:            u.header,
+		sse:               u.sse,
+		sseCustomerKeyMD5: u.sseCustomerKeyMD5,
+		tags:              u.tags,
+		retention:         u.retention,
+		legalHold:         u.legalHold,
+	}
+
+	b.put(obj)
+	delete(b.uploads, u.id)
+
+	writeVersionHeaders(req.w.Header(), b, obj)
+
+	writeXML(req.w, http.StatusOK, completeMultipartUploadResult{
+		Location: "/" + b.name + "/" + u.key,
+		Bucket:   b.name,
+		Key:      u.key,
+		ETag:     obj.etag,
+	})
+
+	return nil
+}
+
+func (s *Server) abortMultipartUpload(req *request) error {
+
+	b, err := s.bucketFor(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.upload(req, b)
+	if err != nil {
+		return err
+	}
+
+	delete(b.uploads, u.id)
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string   `xml:"Bucket"`
+	Key                  string   `xml:"Key"`
+	UploadID             string   `xml:"UploadId"`
+	Initiator            *owner   `xml:"Initiator"`
+	Owner                *owner   `xml:"Owner"`
+	StorageClass         string   `xml:"StorageClass"`
+	PartNumberMarker     int      `xml:"PartNumberMarker"`
+	NextPartNumberMarker int      `xml:"NextPartNumberMarker"`
+	MaxParts             int      `xml:"MaxParts"`
+	IsTruncated          bool     `xml:"IsTruncated"`
+	Parts                []struct {
+		PartNumber   int    `xml:"PartNumber"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int    `xml:"Size"`
+	} `xml:"Part"`
+}
+
+func (s *Server) listParts(req *request) error {
+
+	b, err := s.bucketFor(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.upload(req, b)
+	if err != nil {
+		return err
+	}
+
+	maxParts, err := maxKeysParam(req, "max-parts")
+	if err != nil {
+		return err
+	}
+
+	marker := 0
+	if v := req.param("part-number-marker"); v != "" {
+		if marker, err = strconv.Atoi(v); err != nil {
+			return errInvalidArgument.withMessage("Provided part-number-marker not an integer")
+		}
+	}
+
+	var numbers []int
+	for n := range u.parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+
+	result := listPartsResult{
+		Bucket:           b.name,
+		Key:              u.key,
+		UploadID:         u.id,
+		Initiator:        ownerOf(u.owner),
+		Owner:            ownerOf(u.owner),
+		StorageClass:     "STANDARD",
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+
+	for _, n := range numbers {
+		if len(result.Parts) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+
+		p := u.parts[n]
+		result.Parts = append(result.Parts, struct {
+			PartNumber   int    `xml:"PartNumber"`
+			LastModified string `xml:"LastModified"`
+			ETag         string `xml:"ETag"`
+			Size         int    `xml:"Size"`
+		}{n, formatTime(p.lastModified), p.etag, len(p.data)})
+		result.NextPartNumberMarker = n
+	}
+
+	writeXML(req.w, http.StatusOK, result)
+
+	return nil
+}
+
+type uploadEntry struct {
+	Key          string `xml:"Key"`
+	UploadID     string `xml:"UploadId"`
+	Initiator    *owner `xml:"Initiator"`
+	Owner        *owner `xml:"Owner"`
+	StorageClass string `xml:"StorageClass"`
+	Initiated    string `xml:"Initiated"`
+}
+
+type listMultipartUploadsResult struct {
+	XMLName            xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListMultipartUploadsResult"`
+	Bucket             string         `xml:"Bucket"`
+	KeyMarker          string         `xml:"KeyMarker"`
+	UploadIDMarker     string         `xml:"UploadIdMarker"`
+	NextKeyMarker      string         `xml:"NextKeyMarker,omitempty"`
+	NextUploadIDMarker string         `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string         `xml:"Prefix"`
+	Delimiter          string         `xml:"Delimiter,omitempty"`
+	EncodingType       string         `xml:"EncodingType,omitempty"`
+	MaxUploads         int            `xml:"MaxUploads"`
+	IsTruncated        bool           `xml:"IsTruncated"`
+	Uploads            []uploadEntry  `xml:"Upload"`
+	CommonPrefixes     []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// listMultipartUploads answers GET ?uploads. Uploads are listed by key and
+// then by initiation time; an upload-id-marker resumes after that upload of
+// the key-marker key, and without one the key-marker key is skipped.
+func (s *Server) listMultipartUploads(req *request) error {
+
+	b, err := s.bucketFor(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	maxUploads, err := maxKeysParam(req, "max-uploads")
+	if err != nil {
+		return err
+	}
+
+	encoding, encode, err := encodingParam(req)
+	if err != nil {
+		return err
+	}
+
+	prefix, delimiter := req.param("prefix"), req.param("delimiter")
+	keyMarker, uploadMarker := req.param("key-marker"), req.param("upload-id-marker")
+
+	var uploads []*upload
+	for _, u := range b.uploads {
+		if strings.HasPrefix(u.key, prefix) && u.key >= keyMarker {
+			uploads = append(uploads, u)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		a, b := uploads[i], uploads[j]
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		if !a.initiated.Equal(b.initiated) {
+			return a.initiated.Before(b.initiated)
+		}
+		return a.id < b.id
+	})
+
+	// Skip the uploads of the marker key up to and including the marker
+	// upload, or all of them when there is no upload marker.
+	skip := 0
+	for skip < len(uploads) && uploads[skip].key == keyMarker {
+		skip++
+		if uploadMarker != "" && uploads[skip-1].id == uploadMarker {
+			break
+		}
+	}
+	uploads = uploads[skip:]
+
+	result := listMultipartUploadsResult{
+		Bucket:         b.name,
+		KeyMarker:      encode(keyMarker),
+		UploadIDMarker: uploadMarker,
+		Prefix:         encode(prefix),
+		Delimiter:      encode(delimiter),
+		EncodingType:   encoding,
+		MaxUploads:     maxUploads,
+	}
+
+	count := 0
+	seen := make(map[string]bool)
+
+	for _, u := range uploads {
+		if delimiter != "" {
+			if i := strings.Index(u.key[len(prefix):], delimiter); i >= 0 {
+				p := u.key[:len(prefix)+i+len(delimiter)]
+				if seen[p] || p <= keyMarker {
+					continue
+				}
+				if count == maxUploads {
+					result.IsTruncated = maxUploads > 0
+					break
+				}
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{encode(p)})
+				result.NextKeyMarker, result.NextUploadIDMarker = encode(p), ""
+				count++
+				continue
+			}
+		}
+
+		if count == maxUploads {
+			result.IsTruncated = maxUploads > 0
+			break
+		}
+
+		result.Uploads = append(result.Uploads, uploadEntry{
+			Key:          encode(u.key),
+			UploadID:     u.id,
+			Initiator:    ownerOf(u.owner),
+			Owner:        ownerOf(u.owner),
+			StorageClass: "STANDARD",
+			Initiated:    formatTime(u.initiated),
+		})
+		result.NextKeyMarker, result.NextUploadIDMarker = encode(u.key), u.id
+		count++
+	}
+
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextUploadIDMarker = "", ""
+	}
+
+	writeXML(req.w, http.StatusOK, result)
+
+	return nil
+}
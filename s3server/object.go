@@ -0,0 +1,713 @@
+package s3server
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type object struct {
+	key          string
+	versionID    string
+	deleteMarker bool
+	data         []byte
+	etag         string
+	lastModified time.Time
+	owner        *User
+	acl          *acl
+	header       http.Header
+
+	// sse is the x-amz-server-side-encryption value the object was written with.
+	sse string
+
+	// sseCustomerKeyMD5 is set for objects written with SSE-C.
+	sseCustomerKeyMD5 string
+
+	tags []tag
+
+	// retention is nil and legalHold empty until they are first set.
+	retention *objectRetention
+	legalHold string
+}
+
+// storedHeaders are the request headers kept with an object and returned
+// when it is read.
+var storedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+	"Expires",
+	"X-Amz-Website-Redirect-Location",
+}
+
+// objectHeaders copies the headers of r that are stored with an object.
+func objectHeaders(r *http.Request) http.Header {
+
+	h := make(http.Header)
+
+	for _, name := range storedHeaders {
+		if v := r.Header.Get(name); v != "" {
+			h.Set(name, v)
+		}
+	}
+
+	for name, values := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			h[name] = values
+		}
+	}
+
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "binary/octet-stream")
+	}
+
+	return h
+}
+
+func etagOf(data []byte) string {
+
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// hasCustomerKey reports whether h carries SSE-C headers for the object
+// or for a copy source.
+func hasCustomerKey(h http.Header) bool {
+
+	for name := range h {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-server-side-encryption-customer-") ||
+			strings.HasPrefix(name, "x-amz-copy-source-server-side-encryption-customer-") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContentMD5 validates the Content-MD5 header, if any, against body.
+func checkContentMD5(r *http.Request, body []byte) error {
+
+	values, ok := r.Header["Content-Md5"]
+	if !ok {
+		return nil
+	}
+
+	want, err := base64.StdEncoding.DecodeString(strings.TrimSpace(values[0]))
+	if err != nil || len(want) != md5.Size {
+		return errInvalidDigest
+	}
+
+	got := md5.Sum(body)
+	if !bytes.Equal(want, got[:]) {
+		return errBadDigest
+	}
+
+	return nil
+}
+
+// sseCustomerKey validates the SSE-C headers with the given prefix and
+// returns the MD5 of the customer key, or "" when none were sent.
+func sseCustomerKey(h http.Header, prefix string) (string, error) {
+
+	algorithm := h.Get(prefix + "algorithm")
+	key := h.Get(prefix + "key")
+	keyMD5 := h.Get(prefix + "key-MD5")
+
+	if algorithm == "" && key == "" && keyMD5 == "" {
+		return "", nil
+	}
+
+	if algorithm != "AES256" {
+		return "", errInvalidArgument.withMessage("Requests specifying Server Side Encryption with Customer provided keys must provide a valid encryption algorithm.")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return "", errInvalidArgument.withMessage("The secret key was invalid for the specified algorithm.")
+	}
+
+	if keyMD5 == "" {
+		return "", errInvalidArgument.withMessage("Requests specifying Server Side Encryption with Customer provided keys must provide the client calculated MD5 of the secret key.")
+	}
+
+	sum := md5.Sum(raw)
+	if base64.StdEncoding.EncodeToString(sum[:]) != keyMD5 {
+		return "", errInvalidArgument.withMessage("The calculated MD5 hash of the key did not match the hash that was provided.")
+	}
+
+	return keyMD5, nil
+}
+
+// serverSideEncryption validates the SSE-S3/SSE-KMS headers of r.
+func serverSideEncryption(r *http.Request) (string, error) {
+
+	sse := r.Header.Get("x-amz-server-side-encryption")
+	kmsKeyID := r.Header.Get("x-amz-server-side-encryption-aws-kms-key-id")
+
+	switch sse {
+	case "":
+		if kmsKeyID != "" {
+			return "", errInvalidArgument.withMessage("Server Side Encryption with KMS managed key requires HTTP header x-amz-server-side-encryption : aws:kms")
+		}
+	case "AES256":
+		if kmsKeyID != "" {
+			return "", errInvalidArgument.withMessage("Server Side Encryption with KMS managed key requires HTTP header x-amz-server-side-encryption : aws:kms")
+		}
+	case "aws:kms":
+		return "", errNotImplemented.withMessage("Server Side Encryption with KMS managed keys is not supported.")
+	default:
+		return "", errInvalidArgument.withMessage("The encryption method specified is not supported")
+	}
+
+	return sse, nil
+}
+
+// checkSSECustomerKey checks the SSE-C headers of a read against obj.
+func checkSSECustomerKey(h http.Header, prefix string, obj *object) error {
+
+	keyMD5, err := sseCustomerKey(h, prefix)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case obj.sseCustomerKeyMD5 == "" && keyMD5 != "":
+		return errInvalidRequest.withMessage("The encryption parameters are not applicable to this object.")
+	case obj.sseCustomerKeyMD5 != "" && keyMD5 == "":
+		return errSSECustomerKeyRequired
+	case obj.sseCustomerKeyMD5 != keyMD5:
+		return errAccessDenied
+	}
+
+	return nil
+}
+
+func writeEncryptionHeaders(h http.Header, obj *object) {
+
+	if obj.sse != "" {
+		h.Set("x-amz-server-side-encryption", obj.sse)
+	}
+
+	if obj.sseCustomerKeyMD5 != "" {
+		h.Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+		h.Set("x-amz-server-side-encryption-customer-key-MD5", obj.sseCustomerKeyMD5)
+	}
+}
+
+// etagMatches reports whether etag appears in an If-Match style list.
+func etagMatches(list, etag string) bool {
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.Trim(candidate, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkConditions evaluates the conditional headers with the given prefix
+// against obj, in the order S3 applies them.
+func checkConditions(h http.Header, prefix string, obj *object) error {
+
+	modified := obj.lastModified.Truncate(time.Second)
+
+	if v := h.Get(prefix + "If-Match"); v != "" {
+		if !etagMatches(v, obj.etag) {
+			return errPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(h.Get(prefix + "If-Unmodified-Since")); err == nil && modified.After(t) {
+		return errPreconditionFailed
+	}
+
+	if v := h.Get(prefix + "If-None-Match"); v != "" {
+		if etagMatches(v, obj.etag) {
+			if prefix != "" {
+				return errPreconditionFailed
+			}
+			return errNotModified
+		}
+	} else if t, err := http.ParseTime(h.Get(prefix + "If-Modified-Since")); err == nil && !modified.After(t) {
+		if prefix != "" {
+			return errPreconditionFailed
+		}
+		return errNotModified
+	}
+
+	return nil
+}
+
+// parseRange parses a single byte range for an object of the given size.
+// ok is false when the header should be ignored and the whole object sent.
+func parseRange(header string, size int64) (start, end int64, ok bool, err error) {
+
+	if !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return 0, 0, false, nil
+	}
+
+	spec := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(spec) != 2 {
+		return 0, 0, false, nil
+	}
+
+	if spec[0] == "" {
+		n, perr := strconv.ParseInt(spec[1], 10, 64)
+		if perr != nil || n < 0 {
+			return 0, 0, false, nil
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, errInvalidRange
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true, nil
+	}
+
+	start, perr := strconv.ParseInt(spec[0], 10, 64)
+	if perr != nil {
+		return 0, 0, false, nil
+	}
+
+	end = size - 1
+	if spec[1] != "" {
+		end, perr = strconv.ParseInt(spec[1], 10, 64)
+		if perr != nil || end < start {
+			return 0, 0, false, nil
+		}
+	}
+
+	if start >= size {
+		return 0, 0, false, errInvalidRange
+	}
+
+	if end >= size {
+		end = size - 1
+	}
+
+	return start, end, true, nil
+}
+
+func (s *Server) putObject(req *request) error {
+
+	b, obj, err := s.writeObject(req)
+	if err != nil {
+		return err
+	}
+
+	req.w.Header().Set("ETag", obj.etag)
+	writeEncryptionHeaders(req.w.Header(), obj)
+	writeVersionHeaders(req.w.Header(), b, obj)
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+// writeObject stores the body of a request as a new version of its key,
+// with the metadata, ACL, encryption, tags and lock its headers ask for.
+func (s *Server) writeObject(req *request) (*bucket, *object, error) {
+
+	b, err := s.bucketFor(req, permWrite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkContentMD5(req.r, req.body); err != nil {
+		return nil, nil, err
+	}
+
+	if existing, ok := b.objects[req.key]; ok {
+		if err := checkConditions(req.r.Header, "", existing); err == errNotModified {
+			return nil, nil, errPreconditionFailed
+		} else if err != nil {
+			return nil, nil, err
+		}
+	} else if req.r.Header.Get("If-Match") != "" {
+		return nil, nil, errNoSuchKey
+	}
+
+	keyMD5, err := sseCustomerKey(req.r.Header, "x-amz-server-side-encryption-customer-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sse, err := serverSideEncryption(req.r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags, err := taggingHeader(req.r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	retention, legalHold, err := s.lockFromRequest(req.r, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a, err := s.newACL(req.r, req.user, b.owner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj := &object{
+		key:               req.key,
+		data:              req.body,
+		etag:              etagOf(req.body),
+		lastModified:      s.now().UTC(),
+		owner:             req.user,
+		acl:               a,
+		header:            objectHeaders(req.r),
+		sse:               sse,
+		sseCustomerKeyMD5: keyMD5,
+		tags:              tags,
+		retention:         retention,
+		legalHold:         legalHold,
+	}
+
+	b.put(obj)
+
+	return b, obj, nil
+}
+
+// readableObject looks up the requested object and checks that the
+// requester may read it.
+func (s *Server) readableObject(req *request) (*bucket, *object, error) {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := b.lookup(req.key, req.param("versionId"))
+	if obj != nil && obj.deleteMarker {
+		if req.w != nil {
+			writeVersionHeaders(req.w.Header(), b, obj)
+		}
+		return nil, nil, err
+	}
+
+	listable := s.permitted(req, b, "s3:ListBucket", "", b.acl.allows(req.user, permRead))
+	if err == errNoSuchKey && !listable {
+		// Without list permission S3 does not reveal that a key is missing.
+		return nil, nil, errAccessDenied
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	action := "s3:GetObject"
+	if req.has("versionId") {
+		action = "s3:GetObjectVersion"
+	}
+
+	if !s.permitted(req, b, action, req.key, obj.acl.allows(req.user, permRead)) {
+		return nil, nil, errAccessDenied
+	}
+
+	return b, obj, nil
+}
+
+// responseOverrides maps the query parameters that override response
+// headers of a GET to the headers they replace.
+var responseOverrides = map[string]string{
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+	"response-content-language":    "Content-Language",
+	"response-content-type":        "Content-Type",
+	"response-expires":             "Expires",
+}
+
+func (s *Server) getObject(req *request) error {
+
+	b, obj, err := s.readableObject(req)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSSECustomerKey(req.r.Header, "x-amz-server-side-encryption-customer-", obj); err != nil {
+		return err
+	}
+
+	writeVersionHeaders(req.w.Header(), b, obj)
+
+	if err := checkConditions(req.r.Header, "", obj); err != nil {
+		if err == errNotModified {
+			req.w.Header().Set("ETag", obj.etag)
+			req.w.Header().Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+		}
+		return err
+	}
+
+	size := int64(len(obj.data))
+	start, end, partial, err := parseRange(req.r.Header.Get("Range"), size)
+	if err != nil {
+		req.w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+		return err
+	}
+
+	h := req.w.Header()
+	for name, values := range obj.header {
+		h[name] = values
+	}
+
+	for param, header := range responseOverrides {
+		if v := req.param(param); v != "" {
+			if req.user == nil {
+				return errInvalidRequest.withMessage("Request specific response headers cannot be used for anonymous GET requests.")
+			}
+			h.Set(header, v)
+		}
+	}
+
+	h.Set("ETag", obj.etag)
+	h.Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	h.Set("Accept-Ranges", "bytes")
+	writeEncryptionHeaders(h, obj)
+	writeTaggingCount(h, obj)
+	writeLockHeaders(h, obj)
+
+	body := obj.data
+	status := http.StatusOK
+
+	if partial {
+		body = obj.data[start : end+1]
+		status = http.StatusPartialContent
+		h.Set("Content-Range", "bytes "+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10)+"/"+strconv.FormatInt(size, 10))
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	req.w.WriteHeader(status)
+
+	if req.r.Method != "HEAD" {
+		req.w.Write(body)
+	}
+
+	return nil
+}
+
+func (s *Server) deleteObject(req *request) error {
+
+	b, err := s.bucketFor(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	h := req.w.Header()
+
+	if req.has("versionId") {
+		id := req.param("versionId")
+		if v, err := b.lookup(req.key, id); err == nil {
+			if err := v.protected(s.now(), s.bypassGovernance(req, b, req.key)); err != nil {
+				return err
+			}
+		}
+		if v := b.dropVersion(req.key, id); v != nil && v.deleteMarker {
+			h.Set("x-amz-delete-marker", "true")
+		}
+		h.Set("x-amz-version-id", id)
+	} else if marker := b.remove(req.key, req.user, s.now().UTC()); marker != nil {
+		writeVersionHeaders(h, b, marker)
+	}
+
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// copySource resolves the x-amz-copy-source header of req to a readable
+// object, optionally a specific version of it.
+func (s *Server) copySource(req *request) (*object, error) {
+
+	source := req.r.Header.Get("x-amz-copy-source")
+
+	var query url.Values
+	if i := strings.Index(source, "?"); i >= 0 {
+		query, _ = url.ParseQuery(source[i+1:])
+		source = source[:i]
+	}
+
+	source, err := url.QueryUnescape(source)
+	if err != nil {
+		return nil, errInvalidArgument.withMessage("Invalid copy source encoding")
+	}
+
+	bucketName, key := splitPath(source)
+	if bucketName == "" || key == "" {
+		return nil, errInvalidArgument.withMessage("Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+	}
+
+	b, obj, err := s.readableObject(&request{r: req.r, user: req.user, bucket: bucketName, key: key, query: query})
+	if err == errMethodNotAllowed {
+		// Copying from a delete marker is a bad request, not a bad method.
+		return nil, errInvalidRequest
+	} else if err != nil {
+		return nil, err
+	}
+
+	if b.versioning != "" {
+		req.w.Header().Set("x-amz-copy-source-version-id", obj.versionID)
+	}
+
+	if err := checkConditions(req.r.Header, "x-amz-copy-source-", obj); err != nil {
+		return nil, err
+	}
+
+	if err := checkSSECustomerKey(req.r.Header, "x-amz-copy-source-server-side-encryption-customer-", obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+func (s *Server) copyObject(req *request) error {
+
+	src, err := s.copySource(req)
+	if err != nil {
+		return err
+	}
+
+	b, err := s.bucketFor(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	directive := req.r.Header.Get("x-amz-metadata-directive")
+	if directive != "" && directive != "COPY" && directive != "REPLACE" {
+		return errInvalidArgument.withMessage("Unknown metadata directive.")
+	}
+
+	tags := src.tags
+	switch req.r.Header.Get("x-amz-tagging-directive") {
+	case "", "COPY":
+	case "REPLACE":
+		if tags, err = taggingHeader(req.r); err != nil {
+			return err
+		}
+	default:
+		return errInvalidArgument.withMessage("Unknown tagging directive.")
+	}
+
+	keyMD5, err := sseCustomerKey(req.r.Header, "x-amz-server-side-encryption-customer-")
+	if err != nil {
+		return err
+	}
+
+	sse, err := serverSideEncryption(req.r)
+	if err != nil {
+		return err
+	}
+
+	if existing, ok := b.objects[req.key]; ok && existing == src && directive != "REPLACE" &&
+		keyMD5 == src.sseCustomerKeyMD5 && sse == "" {
+		return errInvalidRequest.withMessage("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.")
+	}
+
+	retention, legalHold, err := s.lockFromRequest(req.r, b)
+	if err != nil {
+		return err
+	}
+
+	a, err := s.newACL(req.r, req.user, b.owner)
+	if err != nil {
+		return err
+	}
+
+	header := src.header
+	if directive == "REPLACE" {
+		header = objectHeaders(req.r)
+	}
+
+	obj := &object{
+		key:               req.key,
+		data:              src.data,
+		etag:              etagOf(src.data),
+		lastModified:      s.now().UTC(),
+		owner:             req.user,
+		acl:               a,
+		header:            header,
+		sse:               sse,
+		sseCustomerKeyMD5: keyMD5,
+		tags:              tags,
+		retention:         retention,
+		legalHold:         legalHold,
+	}
+
+	b.put(obj)
+
+	writeEncryptionHeaders(req.w.Header(), obj)
+	writeVersionHeaders(req.w.Header(), b, obj)
+	writeXML(req.w, http.StatusOK, copyObjectResult{
+		LastModified: formatTime(obj.lastModified),
+		ETag:         obj.etag,
+	})
+
+	return nil
+}
+
+func (s *Server) getObjectACL(req *request) error {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return err
+	}
+
+	obj, ok := b.objects[req.key]
+	if !ok {
+		return errNoSuchKey
+	}
+
+	if !s.permitted(req, b, req.action, req.key, obj.acl.allows(req.user, permReadACP)) {
+		return errAccessDenied
+	}
+
+	writeXML(req.w, http.StatusOK, s.policy(obj.acl))
+
+	return nil
+}
+
+func (s *Server) putObjectACL(req *request) error {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return err
+	}
+
+	obj, ok := b.objects[req.key]
+	if !ok {
+		return errNoSuchKey
+	}
+
+	if !s.permitted(req, b, req.action, req.key, obj.acl.allows(req.user, permWriteACP)) {
+		return errAccessDenied
+	}
+
+	a, err := s.aclFromRequest(req, obj.acl, obj.owner, b.owner)
+	if err != nil {
+		return err
+	}
+
+	obj.acl = a
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
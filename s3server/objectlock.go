@@ -0,0 +1,347 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Object Lock retention modes. Governance retention may be lifted by users
+// allowed to bypass it; compliance retention holds until it ends.
+const (
+	lockGovernance = "GOVERNANCE"
+	lockCompliance = "COMPLIANCE"
+)
+
+// Legal hold states. An object that never had a legal hold set has an
+// empty state.
+const (
+	legalHoldOn  = "ON"
+	legalHoldOff = "OFF"
+)
+
+type objectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled"`
+	Rule              *objectLockRule `xml:"Rule"`
+}
+
+type objectLockRule struct {
+	DefaultRetention struct {
+		Mode  string `xml:"Mode"`
+		Days  *int   `xml:"Days"`
+		Years *int   `xml:"Years"`
+	} `xml:"DefaultRetention"`
+}
+
+// objectRetention is the retention period of an object version.
+type objectRetention struct {
+	mode  string
+	until time.Time
+}
+
+type retentionDocument struct {
+	XMLName         xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Retention"`
+	Mode            string   `xml:"Mode"`
+	RetainUntilDate string   `xml:"RetainUntilDate"`
+}
+
+type legalHoldDocument struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+func validLockMode(mode string) bool {
+
+	return mode == lockGovernance || mode == lockCompliance
+}
+
+// check validates an Object Lock configuration. Its rule, when there is
+// one, sets a default retention of either days or years.
+func (c *objectLockConfiguration) check() error {
+
+	if c.ObjectLockEnabled != "Enabled" {
+		return errMalformedXML
+	}
+
+	if c.Rule == nil {
+		return nil
+	}
+
+	d := c.Rule.DefaultRetention
+	if !validLockMode(d.Mode) || (d.Days == nil) == (d.Years == nil) {
+		return errMalformedXML
+	}
+
+	if (d.Days != nil && *d.Days <= 0) || (d.Years != nil && *d.Years <= 0) {
+		return errInvalidArgument.withMessage("Default retention period must be a positive integer value.")
+	}
+
+	return nil
+}
+
+// defaultRetention returns the retention new versions get from the bucket
+// rule, or nil when there is none.
+func (c *objectLockConfiguration) defaultRetention(now time.Time) *objectRetention {
+
+	if c == nil || c.Rule == nil {
+		return nil
+	}
+
+	d := c.Rule.DefaultRetention
+	if d.Days != nil {
+		return &objectRetention{mode: d.Mode, until: now.AddDate(0, 0, *d.Days)}
+	}
+	return &objectRetention{mode: d.Mode, until: now.AddDate(*d.Years, 0, 0)}
+}
+
+// protected returns errObjectLocked if obj may not be deleted at now. A
+// legal hold always protects it; a retention period does unless it is in
+// governance mode and bypass is set.
+func (obj *object) protected(now time.Time, bypass bool) error {
+
+	if obj.legalHold == legalHoldOn {
+		return errObjectLocked
+	}
+
+	if r := obj.retention; r != nil && r.until.After(now) {
+		if r.mode == lockCompliance || !bypass {
+			return errObjectLocked
+		}
+	}
+
+	return nil
+}
+
+// bypassGovernance reports whether a request asks to bypass governance
+// retention on key and is allowed to.
+func (s *Server) bypassGovernance(req *request, b *bucket, key string) bool {
+
+	if !strings.EqualFold(req.r.Header.Get("x-amz-bypass-governance-retention"), "true") {
+		return false
+	}
+
+	owner := req.user != nil && req.user.ID == b.owner.ID
+
+	return s.permitted(req, b, "s3:BypassGovernanceRetention", key, owner)
+}
+
+// lockFromRequest reads the retention and legal hold a write request sets
+// on the version it creates with the x-amz-object-lock-* headers. Versions
+// written without a retention get the bucket's default one.
+func (s *Server) lockFromRequest(r *http.Request, b *bucket) (*objectRetention, string, error) {
+
+	mode := r.Header.Get("x-amz-object-lock-mode")
+	date := r.Header.Get("x-amz-object-lock-retain-until-date")
+	hold := r.Header.Get("x-amz-object-lock-legal-hold")
+
+	if mode == "" && date == "" && hold == "" {
+		return b.objectLock.defaultRetention(s.now().UTC()), "", nil
+	}
+
+	if b.objectLock == nil {
+		return nil, "", errInvalidRequest.withMessage("Bucket is missing ObjectLockConfiguration")
+	}
+
+	if hold != "" && hold != legalHoldOn && hold != legalHoldOff {
+		return nil, "", errInvalidArgument.withMessage("Legal Hold must be either of 'ON' or 'OFF'")
+	}
+
+	if mode == "" && date == "" {
+		return b.objectLock.defaultRetention(s.now().UTC()), hold, nil
+	}
+
+	if mode == "" || date == "" {
+		return nil, "", errInvalidArgument.withMessage("x-amz-object-lock-retain-until-date and x-amz-object-lock-mode must both be supplied")
+	}
+
+	retention, err := s.parseRetention(mode, date)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return retention, hold, nil
+}
+
+// parseRetention parses a retention mode and the date it lasts until,
+// which must be in the future.
+func (s *Server) parseRetention(mode, date string) (*objectRetention, error) {
+
+	if !validLockMode(mode) {
+		return nil, errInvalidArgument.withMessage("Unknown wormMode directive.")
+	}
+
+	until, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return nil, errInvalidArgument.withMessage("The retain until date must be provided in ISO 8601 format")
+	}
+
+	if !until.After(s.now()) {
+		return nil, errInvalidArgument.withMessage("The retain until date must be in the future!")
+	}
+
+	return &objectRetention{mode: mode, until: until.UTC()}, nil
+}
+
+// writeLockHeaders reports the retention and legal hold of obj on reads.
+func writeLockHeaders(h http.Header, obj *object) {
+
+	if r := obj.retention; r != nil {
+		h.Set("x-amz-object-lock-mode", r.mode)
+		h.Set("x-amz-object-lock-retain-until-date", formatTime(r.until))
+	}
+
+	if obj.legalHold != "" {
+		h.Set("x-amz-object-lock-legal-hold", obj.legalHold)
+	}
+}
+
+// lockedObject looks up the object version whose retention or legal hold a
+// request reads or writes, in a bucket with Object Lock enabled. Access is
+// checked the way it is for the version's tags.
+func (s *Server) lockedObject(req *request, perm string) (*bucket, *object, error) {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b.objectLock == nil {
+		return nil, nil, errInvalidRequest.withMessage("Bucket is missing Object Lock Configuration")
+	}
+
+	return s.taggedObject(req, perm)
+}
+
+func (s *Server) putObjectLockConfiguration(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if b.objectLock == nil {
+		return errInvalidBucketState.withMessage("Object Lock configuration cannot be enabled on existing buckets")
+	}
+
+	var c objectLockConfiguration
+	if err := xml.Unmarshal(req.body, &c); err != nil {
+		return errMalformedXML
+	}
+
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	b.objectLock = &c
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) getObjectLockConfiguration(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if b.objectLock == nil {
+		return errObjectLockConfigurationNotFound
+	}
+
+	writeXML(req.w, http.StatusOK, b.objectLock)
+
+	return nil
+}
+
+func (s *Server) putObjectRetention(req *request) error {
+
+	b, obj, err := s.lockedObject(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	var doc retentionDocument
+	if err := xml.Unmarshal(req.body, &doc); err != nil || !validLockMode(doc.Mode) {
+		return errMalformedXML
+	}
+
+	retention, err := s.parseRetention(doc.Mode, doc.RetainUntilDate)
+	if err != nil {
+		return err
+	}
+
+	// Retention may always be extended, or moved from governance to
+	// compliance mode. Shortening it, or moving it back to governance mode,
+	// is only possible for governance retention, by bypassing it.
+	if current := obj.retention; current != nil && current.until.After(s.now()) {
+		weaker := retention.until.Before(current.until) || (current.mode == lockCompliance && retention.mode == lockGovernance)
+		if weaker && (current.mode == lockCompliance || !s.bypassGovernance(req, b, req.key)) {
+			return errObjectLocked
+		}
+	}
+
+	obj.retention = retention
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) getObjectRetention(req *request) error {
+
+	_, obj, err := s.lockedObject(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	if obj.retention == nil {
+		return errNoSuchObjectLockConfiguration
+	}
+
+	writeXML(req.w, http.StatusOK, retentionDocument{
+		Mode:            obj.retention.mode,
+		RetainUntilDate: formatTime(obj.retention.until),
+	})
+
+	return nil
+}
+
+func (s *Server) putObjectLegalHold(req *request) error {
+
+	_, obj, err := s.lockedObject(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	var doc legalHoldDocument
+	if err := xml.Unmarshal(req.body, &doc); err != nil {
+		return errMalformedXML
+	}
+
+	if doc.Status != legalHoldOn && doc.Status != legalHoldOff {
+		return errMalformedXML
+	}
+
+	obj.legalHold = doc.Status
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) getObjectLegalHold(req *request) error {
+
+	_, obj, err := s.lockedObject(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	if obj.legalHold == "" {
+		return errNoSuchObjectLockConfiguration
+	}
+
+	writeXML(req.w, http.StatusOK, legalHoldDocument{Status: obj.legalHold})
+
+	return nil
+}
@@ -0,0 +1,800 @@
+package s3server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Policy statement effects. An explicit deny wins over any allow, and a
+// request no statement applies to falls back to the ACLs.
+const (
+	effectNone  = ""
+	effectAllow = "Allow"
+	effectDeny  = "Deny"
+)
+
+// knownActions are the S3 actions a policy may name without a wildcard.
+var knownActions = map[string]bool{}
+
+func init() {
+
+	for _, a := range []string{
+		"AbortMultipartUpload", "BypassGovernanceRetention", "CreateBucket",
+		"DeleteBucket", "DeleteBucketPolicy", "DeleteBucketWebsite",
+		"DeleteObject", "DeleteObjectTagging", "DeleteObjectVersion",
+		"DeleteObjectVersionTagging", "GetBucketAcl", "GetBucketCORS",
+		"GetBucketLocation", "GetBucketLogging", "GetBucketNotification",
+		"GetBucketObjectLockConfiguration", "GetBucketPolicy",
+		"GetBucketPolicyStatus", "GetBucketPublicAccessBlock",
+		"GetBucketTagging", "GetBucketVersioning", "GetBucketWebsite",
+		"GetEncryptionConfiguration", "GetLifecycleConfiguration", "GetObject",
+		"GetObjectAcl", "GetObjectLegalHold", "GetObjectRetention",
+		"GetObjectTagging", "GetObjectVersion", "GetObjectVersionAcl",
+		"GetObjectVersionTagging", "GetReplicationConfiguration",
+		"ListAllMyBuckets", "ListBucket", "ListBucketMultipartUploads",
+		"ListBucketVersions", "ListMultipartUploadParts", "PutBucketAcl",
+		"PutBucketCORS", "PutBucketLogging", "PutBucketNotification",
+		"PutBucketObjectLockConfiguration", "PutBucketPolicy",
+		"PutBucketPublicAccessBlock", "PutBucketTagging", "PutBucketVersioning",
+		"PutBucketWebsite", "PutEncryptionConfiguration",
+		"PutLifecycleConfiguration", "PutObject", "PutObjectAcl",
+		"PutObjectLegalHold", "PutObjectRetention", "PutObjectTagging",
+		"PutObjectVersionAcl", "PutObjectVersionTagging",
+		"PutReplicationConfiguration", "RestoreObject",
+	} {
+		knownActions["s3:"+strings.ToLower(a)] = true
+	}
+}
+
+// conditionOperators are the condition operators policies may use, each
+// also accepted with an IfExists suffix.
+var conditionOperators = map[string]bool{
+	"stringequals": true, "stringnotequals": true,
+	"stringequalsignorecase": true, "stringnotequalsignorecase": true,
+	"stringlike": true, "stringnotlike": true,
+	"numericequals": true, "numericnotequals": true,
+	"numericlessthan": true, "numericlessthanequals": true,
+	"numericgreaterthan": true, "numericgreaterthanequals": true,
+	"ipaddress": true, "notipaddress": true,
+	"bool": true, "null": true,
+}
+
+type condition struct {
+	operator string
+	ifExists bool
+	key      string
+	values   []string
+}
+
+type statement struct {
+	effect       string
+	principals   []string
+	actions      []string
+	notActions   []string
+	resources    []string
+	notResources []string
+	conditions   []condition
+}
+
+// bucketPolicy is a parsed bucket policy. The document is kept as sent so
+// that GetBucketPolicy returns it unchanged.
+type bucketPolicy struct {
+	document   []byte
+	statements []statement
+}
+
+func malformedPolicy(message string) error {
+
+	return errMalformedPolicy.withMessage(message)
+}
+
+// parseBucketPolicy reads and validates a policy document for bucket b.
+func (s *Server) parseBucketPolicy(body []byte, b *bucket) (*bucketPolicy, error) {
+
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return nil, malformedPolicy("Policies must be valid JSON and the first byte must be '{'")
+	}
+
+	var doc struct {
+		Version   string
+		Statement json.RawMessage
+	}
+
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, malformedPolicy("Policies must be valid JSON and the first byte must be '{'")
+	}
+
+	if doc.Version != "" && doc.Version != "2012-10-17" && doc.Version != "2008-10-17" {
+		return nil, malformedPolicy("Invalid policy document version")
+	}
+
+	var raw []json.RawMessage
+	if len(doc.Statement) > 0 && doc.Statement[0] == '{' {
+		raw = []json.RawMessage{doc.Statement}
+	} else if err := json.Unmarshal(doc.Statement, &raw); err != nil || len(raw) == 0 {
+		return nil, malformedPolicy("Missing required field Statement")
+	}
+
+	p := &bucketPolicy{document: append([]byte(nil), body...)}
+
+	for _, r := range raw {
+		st, err := s.parseStatement(r, b)
+		if err != nil {
+			return nil, err
+		}
+		p.statements = append(p.statements, st)
+	}
+
+	return p, nil
+}
+
+func (s *Server) parseStatement(raw json.RawMessage, b *bucket) (statement, error) {
+
+	var doc struct {
+		Sid         string
+		Effect      string
+		Principal   json.RawMessage
+		Action      json.RawMessage
+		NotAction   json.RawMessage
+		Resource    json.RawMessage
+		NotResource json.RawMessage
+		Condition   map[string]map[string]json.RawMessage
+	}
+
+	var st statement
+
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return st, malformedPolicy("Policies must be valid JSON and the first byte must be '{'")
+	}
+
+	switch doc.Effect {
+	case effectAllow, effectDeny:
+		st.effect = doc.Effect
+	case "":
+		return st, malformedPolicy("Missing required field Effect")
+	default:
+		return st, malformedPolicy("Invalid effect: " + doc.Effect)
+	}
+
+	principals, err := s.parsePrincipal(doc.Principal)
+	if err != nil {
+		return st, err
+	}
+	st.principals = principals
+
+	if st.actions, err = stringList(doc.Action); err != nil {
+		return st, malformedPolicy("Policy has invalid action")
+	}
+	if st.notActions, err = stringList(doc.NotAction); err != nil {
+		return st, malformedPolicy("Policy has invalid action")
+	}
+	if len(st.actions) == 0 && len(st.notActions) == 0 {
+		return st, malformedPolicy("Missing required field Action")
+	}
+	for _, a := range append(st.actions, st.notActions...) {
+		if a != "*" && !strings.HasPrefix(a, "s3:") {
+			return st, malformedPolicy("Policy has invalid action")
+		}
+		if !strings.ContainsAny(a, "*?") && !knownActions[strings.ToLower(a)] {
+			return st, malformedPolicy("Policy has invalid action")
+		}
+	}
+
+	if st.resources, err = stringList(doc.Resource); err != nil {
+		return st, malformedPolicy("Policy has invalid resource")
+	}
+	if st.notResources, err = stringList(doc.NotResource); err != nil {
+		return st, malformedPolicy("Policy has invalid resource")
+	}
+	if len(st.resources) == 0 && len(st.notResources) == 0 {
+		return st, malformedPolicy("Missing required field Resource")
+	}
+	for _, r := range append(st.resources, st.notResources...) {
+		if !resourceInBucket(r, b.name) {
+			return st, malformedPolicy("Policy has invalid resource")
+		}
+	}
+
+	for op, keys := range doc.Condition {
+		c := condition{operator: strings.ToLower(op)}
+		if strings.HasSuffix(c.operator, "ifexists") {
+			c.operator, c.ifExists = strings.TrimSuffix(c.operator, "ifexists"), true
+		}
+		if !conditionOperators[c.operator] {
+			return st, malformedPolicy("Invalid Condition type : " + op)
+		}
+
+		for key, values := range keys {
+			c.key = strings.ToLower(key)
+			if c.values, err = stringList(values); err != nil {
+				return st, malformedPolicy("Invalid Condition value for " + key)
+			}
+			if c.operator == "ipaddress" || c.operator == "notipaddress" {
+				for _, v := range c.values {
+					if _, _, err := parseCIDR(v); err != nil {
+						return st, malformedPolicy("Invalid IP address or CIDR: " + v)
+					}
+				}
+			}
+			st.conditions = append(st.conditions, c)
+		}
+	}
+
+	return st, nil
+}
+
+// parsePrincipal reads the Principal of a statement: "*", or an object of
+// AWS or CanonicalUser principals, which must name known users.
+func (s *Server) parsePrincipal(raw json.RawMessage) ([]string, error) {
+
+	if len(raw) == 0 {
+		return nil, malformedPolicy("Missing required field Principal")
+	}
+
+	var star string
+	if err := json.Unmarshal(raw, &star); err == nil {
+		if star != "*" {
+			return nil, malformedPolicy("Invalid principal in policy")
+		}
+		return []string{"*"}, nil
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil || len(doc) == 0 {
+		return nil, malformedPolicy("Invalid principal in policy")
+	}
+
+	var principals []string
+
+	for kind, value := range doc {
+		if kind != "AWS" && kind != "CanonicalUser" {
+			return nil, malformedPolicy("Invalid principal in policy")
+		}
+
+		ids, err := stringList(value)
+		if err != nil || len(ids) == 0 {
+			return nil, malformedPolicy("Invalid principal in policy")
+		}
+
+		for _, id := range ids {
+			if id != "*" && s.userByPrincipal(id) == nil {
+				return nil, malformedPolicy("Invalid principal in policy")
+			}
+			principals = append(principals, id)
+		}
+	}
+
+	return principals, nil
+}
+
+// userByPrincipal resolves a canonical user ID or an IAM user ARN such as
+// arn:aws:iam:::user/<id> to a user.
+func (s *Server) userByPrincipal(principal string) *User {
+
+	if u := s.userByID(principal); u != nil {
+		return u
+	}
+
+	if strings.HasPrefix(principal, "arn:aws:iam:") {
+		if i := strings.Index(principal, ":user/"); i >= 0 {
+			return s.userByID(principal[i+len(":user/"):])
+		}
+	}
+
+	return nil
+}
+
+// stringList reads a JSON string or array of strings. Numbers and booleans
+// are accepted as their text, as condition values may be either.
+func stringList(raw json.RawMessage) ([]string, error) {
+
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var items []interface{}
+	if raw[0] == '[' {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+	} else {
+		var item interface{}
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, err
+		}
+		items = []interface{}{item}
+	}
+
+	var list []string
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			list = append(list, v)
+		case bool:
+			list = append(list, strconv.FormatBool(v))
+		case float64:
+			list = append(list, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return nil, malformedPolicy("Invalid value")
+		}
+	}
+
+	return list, nil
+}
+
+// resourceInBucket reports whether a resource ARN refers to the bucket or
+// to objects in it.
+func resourceInBucket(resource, bucket string) bool {
+
+	const prefix = "arn:aws:s3:::"
+
+	if !strings.HasPrefix(resource, prefix) {
+		return false
+	}
+
+	name := strings.TrimPrefix(resource, prefix)
+	if i := strings.Index(name, "/"); i >= 0 {
+		name = name[:i]
+	}
+
+	return matchWildcard(name, bucket)
+}
+
+func resourceARN(bucket, key string) string {
+
+	if key == "" {
+		return "arn:aws:s3:::" + bucket
+	}
+	return "arn:aws:s3:::" + bucket + "/" + key
+}
+
+// matchWildcard matches s against a pattern in which * matches any run of
+// characters and ? matches any single character.
+func matchWildcard(pattern, s string) bool {
+
+	px, sx := 0, 0
+	starP, starS := -1, 0
+
+	for sx < len(s) {
+		switch {
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == s[sx]):
+			px++
+			sx++
+		case px < len(pattern) && pattern[px] == '*':
+			starP, starS = px, sx
+			px++
+		case starP >= 0:
+			starS++
+			px, sx = starP+1, starS
+		default:
+			return false
+		}
+	}
+
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+
+	return px == len(pattern)
+}
+
+func parseCIDR(v string) (net.IP, *net.IPNet, error) {
+
+	if !strings.Contains(v, "/") {
+		if ip := net.ParseIP(v); ip != nil {
+			if ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+	}
+
+	return net.ParseCIDR(v)
+}
+
+// policyRequest is what a policy is evaluated against.
+type policyRequest struct {
+	user     *User
+	action   string
+	resource string
+	context  map[string]string
+}
+
+// evaluate returns the effect the policy has on r.
+func (p *bucketPolicy) evaluate(r *policyRequest) string {
+
+	effect := effectNone
+
+	for _, st := range p.statements {
+		if !st.applies(r) {
+			continue
+		}
+		if st.effect == effectDeny {
+			return effectDeny
+		}
+		effect = effectAllow
+	}
+
+	return effect
+}
+
+func (st *statement) applies(r *policyRequest) bool {
+
+	if !st.matchesPrincipal(r.user) {
+		return false
+	}
+
+	if len(st.actions) > 0 && !matchAny(st.actions, r.action, true) {
+		return false
+	}
+	if len(st.notActions) > 0 && matchAny(st.notActions, r.action, true) {
+		return false
+	}
+
+	if len(st.resources) > 0 && !matchAny(st.resources, r.resource, false) {
+		return false
+	}
+	if len(st.notResources) > 0 && matchAny(st.notResources, r.resource, false) {
+		return false
+	}
+
+	for _, c := range st.conditions {
+		if !c.holds(r.context) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (st *statement) matchesPrincipal(u *User) bool {
+
+	for _, p := range st.principals {
+		if p == "*" {
+			return true
+		}
+		if u != nil && (p == u.ID || strings.HasSuffix(p, ":user/"+u.ID)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAny matches s against wildcard patterns. Actions are matched
+// without regard to case, resources are not.
+func matchAny(patterns []string, s string, foldCase bool) bool {
+
+	for _, p := range patterns {
+		if foldCase {
+			p, s = strings.ToLower(p), strings.ToLower(s)
+		}
+		if matchWildcard(p, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// holds evaluates the condition against the request context. A missing
+// key satisfies the negated operators and IfExists conditions only.
+func (c *condition) holds(context map[string]string) bool {
+
+	value, ok := context[c.key]
+
+	if c.operator == "null" {
+		return len(c.values) > 0 && strconv.FormatBool(!ok) == strings.ToLower(c.values[0])
+	}
+
+	negated := strings.Contains(c.operator, "not")
+
+	if !ok {
+		return c.ifExists || negated
+	}
+
+	match := false
+
+	for _, want := range c.values {
+		switch c.operator {
+		case "stringequals", "stringnotequals":
+			match = value == want
+		case "stringequalsignorecase", "stringnotequalsignorecase":
+			match = strings.EqualFold(value, want)
+		case "stringlike", "stringnotlike":
+			match = matchWildcard(want, value)
+		case "bool":
+			match = strings.EqualFold(value, want)
+		case "ipaddress", "notipaddress":
+			_, network, err := parseCIDR(want)
+			match = err == nil && network.Contains(net.ParseIP(value))
+		default:
+			match = compareNumbers(c.operator, value, want)
+		}
+
+		if match {
+			break
+		}
+	}
+
+	return match != negated
+}
+
+func compareNumbers(operator, value, want string) bool {
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	w, err := strconv.ParseFloat(want, 64)
+	if err != nil {
+		return false
+	}
+
+	switch operator {
+	case "numericequals", "numericnotequals":
+		return v == w
+	case "numericlessthan":
+		return v < w
+	case "numericlessthanequals":
+		return v <= w
+	case "numericgreaterthan":
+		return v > w
+	case "numericgreaterthanequals":
+		return v >= w
+	}
+
+	return false
+}
+
+// policyContext collects the condition keys of a request. Keys whose value
+// the request does not carry are left out, which Null conditions test for.
+func policyContext(req *request) map[string]string {
+
+	context := make(map[string]string)
+
+	if host, _, err := net.SplitHostPort(req.r.RemoteAddr); err == nil {
+		context["aws:sourceip"] = host
+	}
+	context["aws:securetransport"] = strconv.FormatBool(req.r.TLS != nil)
+
+	for key, header := range map[string]string{
+		"aws:useragent": "User-Agent",
+		"aws:referer":   "Referer",
+
+		"s3:x-amz-acl":                                       "x-amz-acl",
+		"s3:x-amz-copy-source":                               "x-amz-copy-source",
+		"s3:x-amz-metadata-directive":                        "x-amz-metadata-directive",
+		"s3:x-amz-server-side-encryption":                    "x-amz-server-side-encryption",
+		"s3:x-amz-server-side-encryption-aws-kms-key-id":     "x-amz-server-side-encryption-aws-kms-key-id",
+		"s3:x-amz-storage-class":                             "x-amz-storage-class",
+		"s3:x-amz-grant-read":                                "x-amz-grant-read",
+		"s3:x-amz-grant-write":                               "x-amz-grant-write",
+		"s3:x-amz-grant-read-acp":                            "x-amz-grant-read-acp",
+		"s3:x-amz-grant-write-acp":                           "x-amz-grant-write-acp",
+		"s3:x-amz-grant-full-control":                        "x-amz-grant-full-control",
+		"s3:x-amz-server-side-encryption-customer-algorithm": "x-amz-server-side-encryption-customer-algorithm",
+	} {
+		if v := req.r.Header.Get(header); v != "" {
+			context[key] = v
+		}
+	}
+
+	for key, param := range map[string]string{
+		"s3:prefix":    "prefix",
+		"s3:delimiter": "delimiter",
+		"s3:max-keys":  "max-keys",
+		"s3:versionid": "versionId",
+	} {
+		if req.has(param) {
+			context[key] = req.param(param)
+		}
+	}
+
+	return context
+}
+
+// permitted decides whether the requester may perform action on key, or on
+// the bucket itself when key is empty. An explicit deny in the bucket
+// policy wins, an explicit allow grants access, and otherwise aclAllows,
+// the outcome of the ACL check, decides.
+func (s *Server) permitted(req *request, b *bucket, action, key string, aclAllows bool) bool {
+
+	if b.policy == nil {
+		return aclAllows
+	}
+
+	switch b.policy.evaluate(&policyRequest{
+		user:     req.user,
+		action:   action,
+		resource: resourceARN(b.name, key),
+		context:  policyContext(req),
+	}) {
+	case effectDeny:
+		return false
+	case effectAllow:
+		return true
+	}
+
+	return aclAllows
+}
+
+// actionOf returns the policy action a request performs.
+func actionOf(req *request) string {
+
+	method := req.r.Method
+	versioned := req.has("versionId")
+
+	if req.key == "" {
+		switch {
+		case method == "PUT" && req.has("acl"):
+			return "s3:PutBucketAcl"
+		case method == "PUT" && req.has("versioning"):
+			return "s3:PutBucketVersioning"
+		case method == "PUT" && req.has("policy"):
+			return "s3:PutBucketPolicy"
+		case method == "PUT" && req.has("tagging"), method == "DELETE" && req.has("tagging"):
+			return "s3:PutBucketTagging"
+		case method == "PUT":
+			return "s3:CreateBucket"
+		case method == "GET" && req.has("acl"):
+			return "s3:GetBucketAcl"
+		case method == "GET" && req.has("location"):
+			return "s3:GetBucketLocation"
+		case method == "GET" && req.has("versioning"):
+			return "s3:GetBucketVersioning"
+		case method == "GET" && req.has("versions"):
+			return "s3:ListBucketVersions"
+		case method == "GET" && req.has("uploads"):
+			return "s3:ListBucketMultipartUploads"
+		case method == "GET" && req.has("policy"):
+			return "s3:GetBucketPolicy"
+		case method == "GET" && req.has("tagging"):
+			return "s3:GetBucketTagging"
+		case method == "PUT" && req.has("lifecycle"), method == "DELETE" && req.has("lifecycle"):
+			return "s3:PutLifecycleConfiguration"
+		case method == "GET" && req.has("lifecycle"):
+			return "s3:GetLifecycleConfiguration"
+		case method == "PUT" && req.has("cors"), method == "DELETE" && req.has("cors"):
+			return "s3:PutBucketCORS"
+		case method == "GET" && req.has("cors"):
+			return "s3:GetBucketCORS"
+		case method == "PUT" && req.has("website"):
+			return "s3:PutBucketWebsite"
+		case method == "GET" && req.has("website"):
+			return "s3:GetBucketWebsite"
+		case method == "DELETE" && req.has("website"):
+			return "s3:DeleteBucketWebsite"
+		case method == "PUT" && req.has("object-lock"):
+			return "s3:PutBucketObjectLockConfiguration"
+		case method == "GET" && req.has("object-lock"):
+			return "s3:GetBucketObjectLockConfiguration"
+		case method == "DELETE" && req.has("policy"):
+			return "s3:DeleteBucketPolicy"
+		case method == "DELETE":
+			return "s3:DeleteBucket"
+		case method == "POST" && req.has("delete"):
+			return "s3:DeleteObject"
+		case method == "POST":
+			return "s3:PutObject"
+		}
+		return "s3:ListBucket"
+	}
+
+	switch {
+	case method == "PUT" && req.has("acl") && versioned:
+		return "s3:PutObjectVersionAcl"
+	case method == "PUT" && req.has("acl"):
+		return "s3:PutObjectAcl"
+	case method == "GET" && req.has("uploadId"):
+		return "s3:ListMultipartUploadParts"
+	case method == "GET" && req.has("tagging") && versioned:
+		return "s3:GetObjectVersionTagging"
+	case method == "GET" && req.has("tagging"):
+		return "s3:GetObjectTagging"
+	case method == "PUT" && req.has("tagging") && versioned:
+		return "s3:PutObjectVersionTagging"
+	case method == "PUT" && req.has("tagging"):
+		return "s3:PutObjectTagging"
+	case method == "DELETE" && req.has("tagging") && versioned:
+		return "s3:DeleteObjectVersionTagging"
+	case method == "DELETE" && req.has("tagging"):
+		return "s3:DeleteObjectTagging"
+	case method == "PUT" && req.has("retention"):
+		return "s3:PutObjectRetention"
+	case method == "GET" && req.has("retention"):
+		return "s3:GetObjectRetention"
+	case method == "PUT" && req.has("legal-hold"):
+		return "s3:PutObjectLegalHold"
+	case method == "GET" && req.has("legal-hold"):
+		return "s3:GetObjectLegalHold"
+	case method == "GET" && req.has("acl") && versioned:
+		return "s3:GetObjectVersionAcl"
+	case method == "GET" && req.has("acl"):
+		return "s3:GetObjectAcl"
+	case (method == "GET" || method == "HEAD") && versioned:
+		return "s3:GetObjectVersion"
+	case method == "GET" || method == "HEAD":
+		return "s3:GetObject"
+	case method == "DELETE" && req.has("uploadId"):
+		return "s3:AbortMultipartUpload"
+	case method == "DELETE" && versioned:
+		return "s3:DeleteObjectVersion"
+	case method == "DELETE":
+		return "s3:DeleteObject"
+	}
+
+	return "s3:PutObject"
+}
+
+// policyBucket returns the requested bucket for the bucket policy calls.
+// The bucket owner may always make them, so that no policy can lock the
+// owner out of its own bucket.
+func (s *Server) policyBucket(req *request) (*bucket, error) {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := req.user != nil && req.user.ID == b.owner.ID
+	if !owner && !s.permitted(req, b, req.action, "", false) {
+		return nil, errAccessDenied
+	}
+
+	return b, nil
+}
+
+func (s *Server) putBucketPolicy(req *request) error {
+
+	b, err := s.policyBucket(req)
+	if err != nil {
+		return err
+	}
+
+	p, err := s.parseBucketPolicy(req.body, b)
+	if err != nil {
+		return err
+	}
+
+	b.policy = p
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (s *Server) getBucketPolicy(req *request) error {
+
+	b, err := s.policyBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if b.policy == nil {
+		return errNoSuchBucketPolicy
+	}
+
+	req.w.Header().Set("Content-Type", "application/json")
+	req.w.WriteHeader(http.StatusOK)
+	req.w.Write(b.policy.document)
+
+	return nil
+}
+
+func (s *Server) deleteBucketPolicy(req *request) error {
+
+	b, err := s.policyBucket(req)
+	if err != nil {
+		return err
+	}
+
+	b.policy = nil
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
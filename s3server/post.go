@@ -0,0 +1,321 @@
+package s3server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postSignatureFields are the form fields a POST upload with a policy is
+// signed with.
+var postSignatureFields = []string{"x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-signature"}
+
+// postUnchecked are the form fields policy conditions need not cover.
+var postUnchecked = map[string]bool{"policy": true, "x-amz-signature": true, "file": true}
+
+type postResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// postPolicy is a decoded POST policy document. Conditions are kept as
+// decoded from JSON: objects for exact matches and arrays for the others.
+type postPolicy struct {
+	Expiration string        `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+func policyConditionFailed(condition interface{}) error {
+
+	text, _ := json.Marshal(condition)
+
+	return errAccessDenied.withMessage("Invalid according to Policy: Policy Condition failed: " + string(text))
+}
+
+// parsePostForm reads the fields of a multipart/form-data POST upload,
+// keyed by their lowercased names, and the file, which must be the last
+// field. Fields after it are ignored, like S3 does.
+func parsePostForm(req *request) (map[string]string, []byte, string, error) {
+
+	_, params, err := mime.ParseMediaType(req.r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, nil, "", errMalformedPOSTRequest
+	}
+
+	fields := make(map[string]string)
+	reader := multipart.NewReader(bytes.NewReader(req.body), params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, nil, "", errMalformedPOSTRequest
+		}
+
+		name := strings.ToLower(part.FormName())
+		if name == "file" {
+			return fields, data, part.FileName(), nil
+		}
+		fields[name] = string(data)
+	}
+
+	return nil, nil, "", errInvalidArgument.withMessage("POST requires exactly one file upload per request.")
+}
+
+// checkPostPolicy checks that a POST upload of size bytes with fields meets
+// the base64 encoded policy it carries: that the policy has not expired,
+// that every condition holds, and that every field is covered by one.
+func (s *Server) checkPostPolicy(req *request, fields map[string]string, size int) error {
+
+	doc, err := base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid Base64 Encoding.")
+	}
+
+	var policy postPolicy
+	if err := json.Unmarshal(doc, &policy); err != nil {
+		return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid JSON.")
+	}
+
+	expiration, err := time.Parse(time.RFC3339, policy.Expiration)
+	if err != nil {
+		return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid 'expiration' value: '" + policy.Expiration + "'")
+	}
+
+	if !expiration.After(s.now()) {
+		return errAccessDenied.withMessage("Invalid according to Policy: Policy expired.")
+	}
+
+	value := func(name string) string {
+		if name == "bucket" {
+			return req.bucket
+		}
+		return fields[name]
+	}
+
+	covered := make(map[string]bool)
+
+	for _, c := range policy.Conditions {
+		switch c := c.(type) {
+		case map[string]interface{}:
+			for name, want := range c {
+				name = strings.ToLower(name)
+				covered[name] = true
+				if w, ok := want.(string); !ok || value(name) != w {
+					return policyConditionFailed(c)
+				}
+			}
+
+		case []interface{}:
+			if len(c) != 3 {
+				return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid Simple-Condition: wrong number of arguments.")
+			}
+
+			op, _ := c[0].(string)
+			if strings.ToLower(op) == "content-length-range" {
+				min, minOK := c[1].(float64)
+				max, maxOK := c[2].(float64)
+				if !minOK || !maxOK {
+					return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid content-length-range.")
+				}
+				if float64(size) < min {
+					return errEntityTooSmall.withMessage("Your proposed upload is smaller than the minimum allowed size")
+				}
+				if float64(size) > max {
+					return errEntityTooLarge.withMessage("Your proposed upload exceeds the maximum allowed size")
+				}
+				continue
+			}
+
+			field, _ := c[1].(string)
+			want, ok := c[2].(string)
+			if !strings.HasPrefix(field, "$") || !ok {
+				return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid Simple-Condition.")
+			}
+
+			name := strings.ToLower(field[1:])
+			covered[name] = true
+
+			switch strings.ToLower(op) {
+			case "eq":
+				if value(name) != want {
+					return policyConditionFailed(c)
+				}
+			case "starts-with":
+				if !strings.HasPrefix(value(name), want) {
+					return policyConditionFailed(c)
+				}
+			default:
+				return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid Simple-Condition: unknown operation " + op + ".")
+			}
+
+		default:
+			return errInvalidPolicyDocument.withMessage("Invalid Policy: Invalid Simple-Condition.")
+		}
+	}
+
+	for name := range fields {
+		if !postUnchecked[name] && !strings.HasPrefix(name, "x-ignore-") && !covered[name] {
+			return errAccessDenied.withMessage("Invalid according to Policy: Extra input fields: " + name)
+		}
+	}
+
+	return nil
+}
+
+// postUser resolves the user a POST upload is signed by, once the signature
+// of its policy is verified, or nil for an anonymous upload without a
+// policy.
+func (s *Server) postUser(req *request, fields map[string]string, size int) (*User, error) {
+
+	if fields["policy"] == "" {
+		for _, name := range postSignatureFields {
+			if fields[name] != "" {
+				return nil, errInvalidArgument.withMessage("Bucket POST must contain a field named 'policy'.  If it is specified, please check the order of the fields.")
+			}
+		}
+		return nil, nil
+	}
+
+	for _, name := range postSignatureFields {
+		if fields[name] == "" {
+			return nil, errInvalidArgument.withMessage(fmt.Sprintf("Bucket POST must contain a field named '%s'.  If it is specified, please check the order of the fields.", name))
+		}
+	}
+
+	if fields["x-amz-algorithm"] != "AWS4-HMAC-SHA256" {
+		return nil, errInvalidArgument.withMessage("Only AWS4-HMAC-SHA256 is supported for POST uploads.")
+	}
+
+	credential := strings.Split(fields["x-amz-credential"], "/")
+	user, ok := s.users[credential[0]]
+	if !ok {
+		return nil, errInvalidAccessKeyID
+	}
+
+	// The policy is signed as it was sent, in base64, with the key of the
+	// credential's scope.
+	if len(credential) != 5 {
+		return nil, errSignatureDoesNotMatch
+	}
+	key := SigningKeyV4(user.SecretKey, credential[1], credential[2], credential[3])
+	if !hmac.Equal([]byte(hex.EncodeToString(hmacSHA256(key, fields["policy"]))), []byte(fields["x-amz-signature"])) {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	if err := s.checkPostPolicy(req, fields, size); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// postObject stores the file of an HTML form upload. The other form fields
+// stand for the headers of a PUT: the object's metadata, ACL and so on.
+func (s *Server) postObject(req *request) error {
+
+	fields, data, filename, err := parsePostForm(req)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := fields["key"]; !ok {
+		return errInvalidArgument.withMessage("Bucket POST must contain a field named 'key'.  If it is specified, please check the order of the fields.")
+	}
+
+	user, err := s.postUser(req, fields, len(data))
+	if err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	for name, v := range fields {
+		header.Set(name, v)
+	}
+	if acl, ok := fields["acl"]; ok {
+		header.Set("x-amz-acl", acl)
+	}
+
+	r := *req.r
+	r.Header = header
+
+	post := &request{
+		r:      &r,
+		w:      req.w,
+		user:   user,
+		bucket: req.bucket,
+		key:    strings.Replace(fields["key"], "${filename}", filename, -1),
+		query:  req.query,
+		body:   data,
+		action: "s3:PutObject",
+	}
+
+	b, obj, err := s.writeObject(post)
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if req.r.TLS != nil {
+		scheme = "https"
+	}
+	location := (&url.URL{Scheme: scheme, Host: req.r.Host, Path: strings.TrimSuffix(req.r.URL.Path, "/") + "/" + post.key}).String()
+
+	h := req.w.Header()
+	h.Set("ETag", obj.etag)
+	h.Set("Location", location)
+	writeEncryptionHeaders(h, obj)
+	writeVersionHeaders(h, b, obj)
+
+	redirect := fields["success_action_redirect"]
+	if redirect == "" {
+		redirect = fields["redirect"]
+	}
+
+	if u, err := url.Parse(redirect); redirect != "" && err == nil && u.IsAbs() {
+		q := u.Query()
+		q.Set("bucket", b.name)
+		q.Set("key", post.key)
+		q.Set("etag", obj.etag)
+		u.RawQuery = q.Encode()
+
+		h.Set("Location", u.String())
+		req.w.WriteHeader(http.StatusSeeOther)
+		return nil
+	}
+
+	switch status, _ := strconv.Atoi(fields["success_action_status"]); status {
+	case http.StatusOK:
+		req.w.WriteHeader(http.StatusOK)
+	case http.StatusCreated:
+		writeXML(req.w, http.StatusCreated, postResponse{
+			Location: location,
+			Bucket:   b.name,
+			Key:      post.key,
+			ETag:     obj.etag,
+		})
+	default:
+		req.w.WriteHeader(http.StatusNoContent)
+	}
+
+	return nil
+}
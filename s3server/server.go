@@ -0,0 +1,433 @@
+// Package s3server is a small in-process reference implementation of the
+// S3 REST API. It keeps everything in memory and is meant to be started by
+// the test suite on an ephemeral port when no real gateway is available.
+package s3server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User is a principal known to the server.
+type User struct {
+	AccessKey   string
+	SecretKey   string
+	ID          string
+	DisplayName string
+	Email       string
+}
+
+// Config controls the behaviour of a Server.
+type Config struct {
+	// Users are the principals allowed to sign requests. Users without an
+	// ID get a canonical ID derived from their access key.
+	Users []User
+
+	// Region is reported by GetBucketLocation and expected in credential scopes.
+	Region string
+
+	// MinPartSize is the smallest size allowed for every part of a
+	// multipart upload but the last one. Defaults to 5MiB.
+	MinPartSize int64
+
+	// Domain enables virtual-hosted-style requests: a request whose Host is
+	// a subdomain of Domain addresses the bucket named by the subdomain.
+	Domain string
+
+	// WebsiteDomain enables the website endpoint: a request whose Host is a
+	// subdomain of WebsiteDomain is served as a static website from the
+	// bucket named by the subdomain.
+	WebsiteDomain string
+
+	// TLSConfig makes Start serve HTTPS instead of plain HTTP.
+	TLSConfig *tls.Config
+
+	// LifecycleDebugInterval is how long a lifecycle day lasts, like RGW's
+	// rgw_lc_debug_interval. When set, Start also applies the lifecycle
+	// rules of every bucket in the background; otherwise they are only
+	// stored.
+	LifecycleDebugInterval time.Duration
+}
+
+// Server is an in-memory S3 endpoint.
+type Server struct {
+	config Config
+
+	mu      sync.Mutex
+	users   map[string]*User
+	buckets map[string]*bucket
+
+	http *http.Server
+
+	// done stops the lifecycle worker.
+	done chan struct{}
+
+	now func() time.Time
+}
+
+// New returns a server for the given configuration. It does not listen
+// until Start is called, but it can be used directly as an http.Handler.
+func New(config Config) *Server {
+
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	if config.MinPartSize == 0 {
+		config.MinPartSize = 5 * 1024 * 1024
+	}
+
+	s := &Server{
+		config:  config,
+		users:   make(map[string]*User),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+
+	for i := range config.Users {
+		u := config.Users[i]
+		if u.ID == "" {
+			sum := sha256.Sum256([]byte(u.AccessKey))
+			u.ID = hex.EncodeToString(sum[:])
+		}
+		s.users[u.AccessKey] = &u
+	}
+
+	return s
+}
+
+// Start listens on an ephemeral loopback port and serves requests in the
+// background, over TLS when the config has a TLSConfig. It returns the
+// host:port the server can be reached at.
+func (s *Server) Start() (string, error) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+
+	if s.config.TLSConfig != nil {
+		l = tls.NewListener(l, s.config.TLSConfig)
+	}
+
+	s.http = &http.Server{Handler: s}
+
+	go s.http.Serve(l)
+
+	if interval := s.config.LifecycleDebugInterval; interval > 0 {
+		s.done = make(chan struct{})
+		go s.runLifecycle(interval, s.done)
+	}
+
+	return l.Addr().String(), nil
+}
+
+// Close stops a server started with Start.
+func (s *Server) Close() error {
+
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+
+	if s.http == nil {
+		return nil
+	}
+
+	return s.http.Close()
+}
+
+// ServeHTTP routes a single S3 request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("x-amz-request-id", newID(8))
+	w.Header().Set("x-amz-id-2", newID(24))
+	w.Header().Set("Server", "s3server")
+
+	// Bodies are read before taking the lock so a slow client cannot
+	// stall the others, and before authenticating since SigV4 signs them.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, errIncompleteBody)
+		return
+	}
+
+	user, signature, err := s.authenticate(r, body)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if isChunked(r) {
+		if body, err = decodeChunked(r, body, signature); err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	bucket, key := s.splitRequest(r)
+
+	req := &request{r: r, w: w, user: user, bucket: bucket, key: key, query: r.URL.Query(), body: body, website: s.isWebsite(r)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.route(req); err != nil {
+		writeError(w, r, err)
+	}
+}
+
+// request carries the state of a request through the handlers.
+type request struct {
+	r      *http.Request
+	w      http.ResponseWriter
+	user   *User
+	bucket string
+	key    string
+	query  map[string][]string
+	body   []byte
+
+	// action is the policy action the request performs, like s3:GetObject.
+	action string
+
+	// website is set for requests to the website endpoint of a bucket.
+	website bool
+}
+
+func (req *request) has(name string) bool {
+
+	_, ok := req.query[name]
+	return ok
+}
+
+func (req *request) param(name string) string {
+
+	if v, ok := req.query[name]; ok && len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// unsupported lists the subresources the server knows about but does not
+// implement, so they fail with NotImplemented instead of being mistaken
+// for plain object or bucket requests.
+var unsupported = []string{
+	"accelerate", "analytics", "encryption", "inventory", "logging",
+	"metrics", "notification", "ownershipControls", "publicAccessBlock",
+	"replication", "requestPayment", "restore", "select", "torrent",
+}
+
+func (s *Server) route(req *request) error {
+
+	if req.website {
+		return s.serveWebsite(req)
+	}
+
+	for _, name := range unsupported {
+		if req.has(name) {
+			return errNotImplemented
+		}
+	}
+
+	if req.r.TLS == nil && hasCustomerKey(req.r.Header) {
+		return errInvalidRequest.withMessage("Requests specifying Server Side Encryption with Customer provided keys must be made over a secure connection.")
+	}
+
+	method := req.r.Method
+	req.action = actionOf(req)
+
+	if req.bucket == "" {
+		if method == "GET" {
+			return s.listBuckets(req)
+		}
+		return errMethodNotAllowed
+	}
+
+	if method == "OPTIONS" {
+		return s.preflight(req)
+	}
+
+	s.corsHeaders(req)
+
+	if req.key == "" {
+		switch {
+		case method == "PUT" && req.has("acl"):
+			return s.putBucketACL(req)
+		case method == "PUT" && req.has("versioning"):
+			return s.putBucketVersioning(req)
+		case method == "PUT" && req.has("policy"):
+			return s.putBucketPolicy(req)
+		case method == "PUT" && req.has("tagging"):
+			return s.putBucketTagging(req)
+		case method == "PUT" && req.has("lifecycle"):
+			return s.putBucketLifecycle(req)
+		case method == "PUT" && req.has("cors"):
+			return s.putBucketCors(req)
+		case method == "PUT" && req.has("website"):
+			return s.putBucketWebsite(req)
+		case method == "PUT" && req.has("object-lock"):
+			return s.putObjectLockConfiguration(req)
+		case method == "PUT":
+			return s.createBucket(req)
+		case method == "GET" && req.has("acl"):
+			return s.getBucketACL(req)
+		case method == "GET" && req.has("location"):
+			return s.getBucketLocation(req)
+		case method == "GET" && req.has("versioning"):
+			return s.getBucketVersioning(req)
+		case method == "GET" && req.has("versions"):
+			return s.listObjectVersions(req)
+		case method == "GET" && req.has("uploads"):
+			return s.listMultipartUploads(req)
+		case method == "GET" && req.has("policy"):
+			return s.getBucketPolicy(req)
+		case method == "GET" && req.has("tagging"):
+			return s.getBucketTagging(req)
+		case method == "GET" && req.has("lifecycle"):
+			return s.getBucketLifecycle(req)
+		case method == "GET" && req.has("cors"):
+			return s.getBucketCors(req)
+		case method == "GET" && req.has("website"):
+			return s.getBucketWebsite(req)
+		case method == "GET" && req.has("object-lock"):
+			return s.getObjectLockConfiguration(req)
+		case method == "GET" && req.param("list-type") == "2":
+			return s.listObjectsV2(req)
+		case method == "GET":
+			return s.listObjects(req)
+		case method == "HEAD":
+			return s.headBucket(req)
+		case method == "DELETE" && req.has("policy"):
+			return s.deleteBucketPolicy(req)
+		case method == "DELETE" && req.has("tagging"):
+			return s.deleteBucketTagging(req)
+		case method == "DELETE" && req.has("lifecycle"):
+			return s.deleteBucketLifecycle(req)
+		case method == "DELETE" && req.has("cors"):
+			return s.deleteBucketCors(req)
+		case method == "DELETE" && req.has("website"):
+			return s.deleteBucketWebsite(req)
+		case method == "DELETE":
+			return s.deleteBucket(req)
+		case method == "POST" && req.has("delete"):
+			return s.deleteObjects(req)
+		case method == "POST" && strings.HasPrefix(req.r.Header.Get("Content-Type"), "multipart/form-data"):
+			return s.postObject(req)
+		}
+		return errMethodNotAllowed
+	}
+
+	switch {
+	case method == "PUT" && req.has("uploadId"):
+		return s.uploadPart(req)
+	case method == "PUT" && req.has("acl"):
+		return s.putObjectACL(req)
+	case method == "PUT" && req.has("tagging"):
+		return s.putObjectTagging(req)
+	case method == "PUT" && req.has("retention"):
+		return s.putObjectRetention(req)
+	case method == "PUT" && req.has("legal-hold"):
+		return s.putObjectLegalHold(req)
+	case method == "PUT" && req.r.Header.Get("x-amz-copy-source") != "":
+		return s.copyObject(req)
+	case method == "PUT":
+		return s.putObject(req)
+	case method == "GET" && req.has("uploadId"):
+		return s.listParts(req)
+	case method == "GET" && req.has("acl"):
+		return s.getObjectACL(req)
+	case method == "GET" && req.has("tagging"):
+		return s.getObjectTagging(req)
+	case method == "GET" && req.has("retention"):
+		return s.getObjectRetention(req)
+	case method == "GET" && req.has("legal-hold"):
+		return s.getObjectLegalHold(req)
+	case method == "GET", method == "HEAD":
+		return s.getObject(req)
+	case method == "DELETE" && req.has("uploadId"):
+		return s.abortMultipartUpload(req)
+	case method == "DELETE" && req.has("tagging"):
+		return s.deleteObjectTagging(req)
+	case method == "DELETE":
+		return s.deleteObject(req)
+	case method == "POST" && req.has("uploads"):
+		return s.createMultipartUpload(req)
+	case method == "POST" && req.has("uploadId"):
+		return s.completeMultipartUpload(req)
+	}
+
+	return errMethodNotAllowed
+}
+
+// hostOf returns the host name a request is addressed to, without a port.
+func hostOf(r *http.Request) string {
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
+
+// isWebsite reports whether a request is addressed to the website endpoint
+// of a bucket.
+func (s *Server) isWebsite(r *http.Request) bool {
+
+	return s.config.WebsiteDomain != "" && strings.HasSuffix(hostOf(r), "."+s.config.WebsiteDomain)
+}
+
+// hostBucket returns the bucket the Host header of a virtual-hosted-style
+// or website request names, or "" for a path-style request.
+func (s *Server) hostBucket(r *http.Request) string {
+
+	host := hostOf(r)
+
+	for _, domain := range []string{s.config.WebsiteDomain, s.config.Domain} {
+		if domain != "" && strings.HasSuffix(host, "."+domain) {
+			return strings.TrimSuffix(host, "."+domain)
+		}
+	}
+
+	return ""
+}
+
+// splitRequest returns the bucket and key a request addresses, taking the
+// bucket from the Host header for virtual-hosted-style and website
+// requests.
+func (s *Server) splitRequest(r *http.Request) (string, string) {
+
+	if bucket := s.hostBucket(r); bucket != "" {
+		return bucket, strings.TrimPrefix(r.URL.Path, "/")
+	}
+
+	return splitPath(r.URL.Path)
+}
+
+// splitPath splits a path-style request path into bucket and key.
+func splitPath(path string) (string, string) {
+
+	path = strings.TrimPrefix(path, "/")
+
+	i := strings.Index(path, "/")
+	if i < 0 {
+		return path, ""
+	}
+
+	return path[:i], path[i+1:]
+}
+
+func newID(n int) string {
+
+	b := make([]byte, n)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
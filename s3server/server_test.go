@@ -0,0 +1,1320 @@
+package s3server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+var testUser = User{AccessKey: "AKIDMAIN", SecretKey: "secret", DisplayName: "main"}
+
+func newTestClient(t *testing.T) (*s3.S3, *httptest.Server) {
+
+	ts := httptest.NewServer(New(Config{Users: []User{testUser}, MinPartSize: 5}))
+
+	return clientFor(ts), ts
+}
+
+// clientFor returns a client signing as testUser for a test server.
+func clientFor(ts *httptest.Server) *s3.S3 {
+
+	cfg := aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(ts.URL).
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials(testUser.AccessKey, testUser.SecretKey, ""))
+
+	return s3.New(session.Must(session.NewSession()), cfg)
+}
+
+func errorCode(err error) string {
+
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+func TestBucketLifecycle(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+	assert.Nil(err)
+
+	_, err = svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Body: strings.NewReader("data")})
+	assert.Nil(err)
+
+	_, err = svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("bucket1")})
+	assert.Equal("BucketNotEmpty", errorCode(err))
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key")})
+	assert.Nil(err)
+
+	_, err = svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("bucket1")})
+	assert.Nil(err)
+
+	_, err = svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("bucket1")})
+	assert.Equal("NoSuchBucket", errorCode(err))
+}
+
+func TestListObjectsDelimiterAndMarker(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+	for _, key := range []string{"a/1", "a/2", "b", "c/1", "d"} {
+		svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String(key), Body: strings.NewReader(key)})
+	}
+
+	resp, err := svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String("bucket1"), Delimiter: aws.String("/"), MaxKeys: aws.Int64(2)})
+	assert.Nil(err)
+	assert.Equal(true, *resp.IsTruncated)
+	assert.Equal("b", *resp.NextMarker)
+	assert.Equal("a/", *resp.CommonPrefixes[0].Prefix)
+	assert.Equal("b", *resp.Contents[0].Key)
+
+	resp, err = svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String("bucket1"), Delimiter: aws.String("/"), Marker: resp.NextMarker})
+	assert.Nil(err)
+	assert.Equal(false, *resp.IsTruncated)
+	assert.Equal("c/", *resp.CommonPrefixes[0].Prefix)
+	assert.Equal("d", *resp.Contents[0].Key)
+}
+
+func TestMultipartUpload(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	mp, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("bucket1"), Key: aws.String("key")})
+	assert.Nil(err)
+
+	var parts []*s3.CompletedPart
+	for i, data := range []string{"hello", " world"} {
+		resp, err := svc.UploadPart(&s3.UploadPartInput{
+			Bucket:     aws.String("bucket1"),
+			Key:        aws.String("key"),
+			UploadId:   mp.UploadId,
+			PartNumber: aws.Int64(int64(i + 1)),
+			Body:       strings.NewReader(data),
+		})
+		assert.Nil(err)
+		parts = append(parts, &s3.CompletedPart{ETag: resp.ETag, PartNumber: aws.Int64(int64(i + 1))})
+	}
+
+	_, err = svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("bucket1"),
+		Key:             aws.String("key"),
+		UploadId:        mp.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{parts[1], parts[0]}},
+	})
+	assert.Equal("InvalidPartOrder", errorCode(err))
+
+	resp, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("bucket1"),
+		Key:             aws.String("key"),
+		UploadId:        mp.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	assert.Nil(err)
+	assert.True(strings.HasSuffix(*resp.ETag, `-2"`))
+
+	obj, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key")})
+	assert.Nil(err)
+	data, _ := ioutil.ReadAll(obj.Body)
+	assert.Equal("hello world", string(data))
+}
+
+func TestConditionalAndRangedGet(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+	put, _ := svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Body: strings.NewReader("testcontent")})
+
+	_, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), IfNoneMatch: put.ETag})
+	assert.Equal("NotModified", errorCode(err))
+
+	_, err = svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), IfMatch: aws.String("bogus")})
+	assert.Equal("PreconditionFailed", errorCode(err))
+
+	resp, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Range: aws.String("bytes=-7")})
+	assert.Nil(err)
+	data, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal("content", string(data))
+	assert.Equal("bytes 4-10/11", *resp.ContentRange)
+
+	_, err = svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Range: aws.String("bytes=40-50")})
+	assert.Equal("InvalidRange", errorCode(err))
+
+	resp, err = svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Range: aws.String("bytes=--1")})
+	if assert.Nil(err) {
+		data, _ = ioutil.ReadAll(resp.Body)
+		assert.Equal("testcontent", string(data))
+	}
+}
+
+func TestParseRange(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for _, c := range []struct {
+		header     string
+		start, end int64
+		ok         bool
+		err        error
+	}{
+		{"bytes=4-7", 4, 7, true, nil},
+		{"bytes=4-", 4, 10, true, nil},
+		{"bytes=4-50", 4, 10, true, nil},
+		{"bytes=-7", 4, 10, true, nil},
+		{"bytes=-50", 0, 10, true, nil},
+		{"bytes=-0", 0, 0, false, errInvalidRange},
+		{"bytes=40-50", 0, 0, false, errInvalidRange},
+		{"bytes=--1", 0, 0, false, nil},
+		{"bytes=7-4", 0, 0, false, nil},
+		{"bytes=0-1,3-4", 0, 0, false, nil},
+		{"items=0-1", 0, 0, false, nil},
+	} {
+		start, end, ok, err := parseRange(c.header, 11)
+		assert.Equal(c.err, err, c.header)
+		assert.Equal(c.ok, ok, c.header)
+		if c.ok {
+			assert.Equal([]int64{c.start, c.end}, []int64{start, end}, c.header)
+		}
+	}
+}
+
+func TestSSECustomerKey(t *testing.T) {
+
+	assert := assert.New(t)
+
+	ts := httptest.NewTLSServer(New(Config{Users: []User{testUser}}))
+	defer ts.Close()
+
+	plain := httptest.NewServer(ts.Config.Handler)
+	defer plain.Close()
+
+	cfg := aws.NewConfig().
+		WithRegion("us-east-1").
+		WithEndpoint(ts.URL).
+		WithS3ForcePathStyle(true).
+		WithHTTPClient(ts.Client()).
+		WithCredentials(credentials.NewStaticCredentials(testUser.AccessKey, testUser.SecretKey, ""))
+	svc := s3.New(session.Must(session.NewSession()), cfg)
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	// The bucket and object are public so the raw requests need no signature.
+	svc.PutBucketAcl(&s3.PutBucketAclInput{Bucket: aws.String("bucket1"), ACL: aws.String("public-read-write")})
+
+	send := func(base, method, key, md5 string) int {
+		req, _ := http.NewRequest(method, base+"/bucket1/obj", strings.NewReader("secret"))
+		req.Header.Set("x-amz-acl", "public-read")
+		if key != "" {
+			req.Header.Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+			req.Header.Set("x-amz-server-side-encryption-customer-key", key)
+			req.Header.Set("x-amz-server-side-encryption-customer-key-MD5", md5)
+		}
+		resp, err := ts.Client().Do(req)
+		assert.Nil(err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	key := "pO3upElrwuEXSoFwCfnZPdSsmt/xWeFa0N9KgDijwVs="
+	md5 := "DWygnHRtgiJ77HCm+1rvHw=="
+
+	assert.Equal(http.StatusBadRequest, send(plain.URL, "PUT", key, md5))
+	assert.Equal(http.StatusBadRequest, send(ts.URL, "PUT", key, "AAAAAAAAAAAAAAAAAAAAAA=="))
+	assert.Equal(http.StatusOK, send(ts.URL, "PUT", key, md5))
+	assert.Equal(http.StatusBadRequest, send(ts.URL, "GET", "", ""))
+	assert.Equal(http.StatusForbidden, send(ts.URL, "GET", "6b+WOZ1T3cqZMxgThRcXAQBrS5mXKdDUphvpxptl9/4=", "arxBvwY2V4SiOne6yppVPQ=="))
+	assert.Equal(http.StatusOK, send(ts.URL, "GET", key, md5))
+}
+
+func TestCannedACL(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+	svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Body: strings.NewReader("data")})
+
+	get := func() int {
+		resp, err := http.Get(ts.URL + "/bucket1/key")
+		assert.Nil(err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(http.StatusForbidden, get())
+
+	_, err := svc.PutObjectAcl(&s3.PutObjectAclInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), ACL: aws.String("public-read")})
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, get())
+
+	acl, err := svc.GetObjectAcl(&s3.GetObjectAclInput{Bucket: aws.String("bucket1"), Key: aws.String("key")})
+	assert.Nil(err)
+	assert.Equal(2, len(acl.Grants))
+	assert.Equal(groupAllUsers, *acl.Grants[1].Grantee.URI)
+	assert.Equal("main", *acl.Owner.DisplayName)
+
+	_, err = svc.PutObjectAcl(&s3.PutObjectAclInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), ACL: aws.String("public-ready")})
+	assert.Equal("InvalidArgument", errorCode(err))
+}
+
+func TestVirtualHostedStyle(t *testing.T) {
+
+	assert := assert.New(t)
+
+	s := New(Config{Users: []User{testUser}, Domain: "s3.example.com"})
+
+	r := httptest.NewRequest("GET", "http://bucket1.s3.example.com:8000/dir/key", nil)
+	bucket, key := s.splitRequest(r)
+	assert.Equal("bucket1", bucket)
+	assert.Equal("dir/key", key)
+
+	r = httptest.NewRequest("GET", "http://my.dotted.bucket.s3.example.com/key", nil)
+	bucket, key = s.splitRequest(r)
+	assert.Equal("my.dotted.bucket", bucket)
+	assert.Equal("key", key)
+
+	r = httptest.NewRequest("GET", "http://s3.example.com/bucket1/key", nil)
+	bucket, key = s.splitRequest(r)
+	assert.Equal("bucket1", bucket)
+	assert.Equal("key", key)
+}
+
+func TestMatchWildcard(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for _, c := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"public/*", "public/a/b", true},
+		{"public/*", "private/a", false},
+		{"doc?.txt", "doc1.txt", true},
+		{"doc?.txt", "doc12.txt", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+	} {
+		assert.Equal(c.match, matchWildcard(c.pattern, c.s), "%q against %q", c.s, c.pattern)
+	}
+}
+
+func TestBucketPolicyDeleteObjects(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+	for _, key := range []string{"keep/a", "drop/b"} {
+		svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String(key), Body: strings.NewReader(key)})
+	}
+
+	_, err := svc.PutBucketPolicy(&s3.PutBucketPolicyInput{
+		Bucket: aws.String("bucket1"),
+		Policy: aws.String(`{"Statement": {"Effect": "Deny", "Principal": "*", "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::bucket1/keep/*"}}`),
+	})
+	assert.Nil(err)
+
+	resp, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String("bucket1"),
+		Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{{Key: aws.String("keep/a")}, {Key: aws.String("drop/b")}}},
+	})
+	assert.Nil(err)
+	assert.Len(resp.Deleted, 1)
+	assert.Equal("drop/b", *resp.Deleted[0].Key)
+	assert.Len(resp.Errors, 1)
+	assert.Equal("keep/a", *resp.Errors[0].Key)
+	assert.Equal("AccessDenied", *resp.Errors[0].Code)
+
+	_, err = svc.PutBucketPolicy(&s3.PutBucketPolicyInput{
+		Bucket: aws.String("bucket1"),
+		Policy: aws.String(`{"Statement": {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket2/*"}}`),
+	})
+	assert.Equal("MalformedPolicy", errorCode(err))
+}
+
+func TestListMultipartUploads(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	var ids []string
+	for _, key := range []string{"a", "b/1", "c", "c"} {
+		resp, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("bucket1"), Key: aws.String(key)})
+		assert.Nil(err)
+		ids = append(ids, *resp.UploadId)
+	}
+
+	resp, err := svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String("bucket1"), Delimiter: aws.String("/"), MaxUploads: aws.Int64(2)})
+	assert.Nil(err)
+	assert.Equal(true, *resp.IsTruncated)
+	assert.Len(resp.Uploads, 1)
+	assert.Len(resp.CommonPrefixes, 1)
+	assert.Equal("b/", *resp.NextKeyMarker)
+
+	resp, err = svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String("bucket1"), Prefix: aws.String("c")})
+	assert.Nil(err)
+	assert.Len(resp.Uploads, 2)
+	first, second := resp.Uploads[0].UploadId, resp.Uploads[1].UploadId
+
+	resp, err = svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String("bucket1"), KeyMarker: aws.String("c"), UploadIdMarker: first})
+	assert.Nil(err)
+	assert.Len(resp.Uploads, 1)
+	assert.Equal(*second, *resp.Uploads[0].UploadId)
+
+	_, err = svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("bucket1")})
+	assert.Equal("BucketNotEmpty", errorCode(err))
+
+	for i, key := range []string{"a", "b/1", "c", "c"} {
+		_, err = svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{Bucket: aws.String("bucket1"), Key: aws.String(key), UploadId: aws.String(ids[i])})
+		assert.Nil(err)
+	}
+
+	resp, err = svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String("bucket1")})
+	assert.Nil(err)
+	assert.Len(resp.Uploads, 0)
+}
+
+func TestObjectTagging(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	_, err := svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Body: strings.NewReader("data"), Tagging: aws.String("b=2&a=1")})
+	assert.Nil(err)
+
+	resp, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{Bucket: aws.String("bucket1"), Key: aws.String("key")})
+	assert.Nil(err)
+	if assert.Len(resp.TagSet, 2) {
+		assert.Equal("a", *resp.TagSet[0].Key)
+		assert.Equal("2", *resp.TagSet[1].Value)
+	}
+
+	_, err = svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Body: strings.NewReader("data"), Tagging: aws.String("a=1&a=2")})
+	assert.Equal("InvalidTag", errorCode(err))
+
+	_, err = svc.CopyObject(&s3.CopyObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("copy"), CopySource: aws.String("bucket1/key"), TaggingDirective: aws.String("MERGE")})
+	assert.Equal("InvalidArgument", errorCode(err))
+
+	get, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key")})
+	assert.Nil(err)
+	assert.Equal(int64(2), *get.TagCount)
+}
+
+func TestLifecycleConfiguration(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	rule := func(id, prefix string) *s3.LifecycleRule {
+		return &s3.LifecycleRule{ID: aws.String(id), Prefix: aws.String(prefix), Status: aws.String("Enabled"), Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)}}
+	}
+
+	put := func(rules ...*s3.LifecycleRule) error {
+		_, err := svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 aws.String("bucket1"),
+			LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+		})
+		return err
+	}
+
+	assert.Equal("InvalidRequest", errorCode(put(rule("a", "logs/"), rule("b", "logs/old/"))))
+	assert.Equal("InvalidRequest", errorCode(put(rule("a", ""), rule("b", "logs/"))))
+	assert.Nil(put(rule("a", "logs/"), rule("b", "tmp/")))
+
+	resp, err := svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String("bucket1")})
+	assert.Nil(err)
+	if assert.Len(resp.Rules, 2) {
+		assert.Equal("tmp/", *resp.Rules[1].Prefix)
+		assert.Nil(resp.Rules[1].Filter)
+	}
+}
+
+func TestLifecycleExpiration(t *testing.T) {
+
+	assert := assert.New(t)
+
+	s := New(Config{Users: []User{testUser}, LifecycleDebugInterval: time.Minute})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	svc := clientFor(ts)
+
+	clock := time.Now()
+	s.now = func() time.Time { return clock }
+
+	// advance moves the clock on by days lifecycle days and applies the
+	// rules, as the background worker would.
+	advance := func(days int) {
+		s.mu.Lock()
+		clock = clock.Add(time.Duration(days) * time.Minute)
+		s.applyLifecycle()
+		s.mu.Unlock()
+	}
+
+	keys := func(bucket string) []string {
+		var keys []string
+		resp, err := svc.ListObjectVersions(&s3.ListObjectVersionsInput{Bucket: aws.String(bucket)})
+		assert.Nil(err)
+		for _, v := range resp.Versions {
+			keys = append(keys, *v.Key+"@"+*v.VersionId)
+		}
+		for _, m := range resp.DeleteMarkers {
+			keys = append(keys, *m.Key+"@marker")
+		}
+		return keys
+	}
+
+	uploads := func(bucket string) []string {
+		var keys []string
+		resp, err := svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)})
+		assert.Nil(err)
+		for _, u := range resp.Uploads {
+			keys = append(keys, *u.Key)
+		}
+		return keys
+	}
+
+	put := func(bucket, key, tagging string) string {
+		resp, err := svc.PutObject(&s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Body: strings.NewReader("data"), Tagging: aws.String(tagging)})
+		assert.Nil(err)
+		return aws.StringValue(resp.VersionId)
+	}
+
+	lifecycle := func(bucket string, rules ...*s3.LifecycleRule) {
+		_, err := svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 aws.String(bucket),
+			LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+		})
+		assert.Nil(err)
+	}
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	put("bucket1", "expire/a", "")
+	put("bucket1", "keep/a", "")
+	put("bucket1", "tagged", "expire=yes")
+	svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("bucket1"), Key: aws.String("uploads/a")})
+	svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("bucket1"), Key: aws.String("other/a")})
+
+	lifecycle("bucket1",
+		&s3.LifecycleRule{ID: aws.String("prefix"), Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("expire/")}, Status: aws.String("Enabled"), Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)}},
+		&s3.LifecycleRule{ID: aws.String("disabled"), Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("keep/")}, Status: aws.String("Disabled"), Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)}},
+		&s3.LifecycleRule{ID: aws.String("tag"), Filter: &s3.LifecycleRuleFilter{Tag: &s3.Tag{Key: aws.String("expire"), Value: aws.String("yes")}}, Status: aws.String("Enabled"), Expiration: &s3.LifecycleExpiration{Days: aws.Int64(2)}},
+		&s3.LifecycleRule{ID: aws.String("abort"), Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("uploads/")}, Status: aws.String("Enabled"), AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(1)}})
+
+	advance(0)
+	assert.Equal([]string{"expire/a@null", "keep/a@null", "tagged@null"}, keys("bucket1"))
+	assert.Equal([]string{"other/a", "uploads/a"}, uploads("bucket1"))
+
+	advance(1)
+	assert.Equal([]string{"keep/a@null", "tagged@null"}, keys("bucket1"))
+	assert.Equal([]string{"other/a"}, uploads("bucket1"))
+
+	advance(1)
+	assert.Equal([]string{"keep/a@null"}, keys("bucket1"))
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket2")})
+	svc.PutBucketVersioning(&s3.PutBucketVersioningInput{Bucket: aws.String("bucket2"), VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String("Enabled")}})
+
+	put("bucket2", "key", "")
+	advance(1)
+	current := put("bucket2", "key", "")
+
+	lifecycle("bucket2",
+		&s3.LifecycleRule{ID: aws.String("noncurrent"), Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("")}, Status: aws.String("Enabled"), NoncurrentVersionExpiration: &s3.NoncurrentVersionExpiration{NoncurrentDays: aws.Int64(2)}},
+		&s3.LifecycleRule{ID: aws.String("expire"), Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("")}, Status: aws.String("Enabled"), Expiration: &s3.LifecycleExpiration{Days: aws.Int64(3)}})
+
+	advance(1)
+	assert.Len(keys("bucket2"), 2)
+
+	advance(1)
+	assert.Equal([]string{"key@" + current}, keys("bucket2"))
+
+	advance(1)
+	assert.Equal([]string{"key@" + current, "key@marker"}, keys("bucket2"))
+}
+
+func TestBucketCors(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	preflight := func(origin, method, headers string) *http.Response {
+		req, _ := http.NewRequest("OPTIONS", ts.URL+"/bucket1/key", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(err)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(http.StatusForbidden, preflight("https://a.example.com", "GET", "").StatusCode)
+
+	_, err := svc.PutBucketCors(&s3.PutBucketCorsInput{
+		Bucket: aws.String("bucket1"),
+		CORSConfiguration: &s3.CORSConfiguration{CORSRules: []*s3.CORSRule{{
+			AllowedOrigins: aws.StringSlice([]string{"https://*.example.com"}),
+			AllowedMethods: aws.StringSlice([]string{"GET", "PUT"}),
+			AllowedHeaders: aws.StringSlice([]string{"X-Amz-*"}),
+			MaxAgeSeconds:  aws.Int64(60),
+		}}},
+	})
+	assert.Nil(err)
+
+	resp := preflight("https://a.example.com", "PUT", "x-amz-date, X-Amz-Meta-Foo")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("https://a.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal("true", resp.Header.Get("Access-Control-Allow-Credentials"))
+	assert.Equal("GET, PUT", resp.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal("x-amz-date, x-amz-meta-foo", resp.Header.Get("Access-Control-Allow-Headers"))
+	assert.Equal("60", resp.Header.Get("Access-Control-Max-Age"))
+
+	assert.Equal(http.StatusForbidden, preflight("https://example.com", "PUT", "").StatusCode)
+	assert.Equal(http.StatusForbidden, preflight("https://a.example.com", "DELETE", "").StatusCode)
+	assert.Equal(http.StatusForbidden, preflight("https://a.example.com", "PUT", "content-type").StatusCode)
+	assert.Equal(http.StatusBadRequest, preflight("", "PUT", "").StatusCode)
+
+	_, err = svc.PutBucketCors(&s3.PutBucketCorsInput{
+		Bucket:            aws.String("bucket1"),
+		CORSConfiguration: &s3.CORSConfiguration{CORSRules: []*s3.CORSRule{{AllowedOrigins: aws.StringSlice([]string{"*"}), AllowedMethods: aws.StringSlice([]string{"PATCH"})}}},
+	})
+	assert.Equal("InvalidRequest", errorCode(err))
+
+	_, err = svc.DeleteBucketCors(&s3.DeleteBucketCorsInput{Bucket: aws.String("bucket1")})
+	assert.Nil(err)
+
+	_, err = svc.GetBucketCors(&s3.GetBucketCorsInput{Bucket: aws.String("bucket1")})
+	assert.Equal("NoSuchCORSConfiguration", errorCode(err))
+}
+
+func TestBucketWebsite(t *testing.T) {
+
+	assert := assert.New(t)
+	ts := httptest.NewServer(New(Config{Users: []User{testUser}, WebsiteDomain: "website.test"}))
+	defer ts.Close()
+	svc := clientFor(ts)
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1"), ACL: aws.String("public-read")})
+	for key, acl := range map[string]string{"index.html": "public-read", "docs/index.html": "public-read", "error.html": "public-read", "private.html": "private"} {
+		svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String(key), ACL: aws.String(acl), Body: strings.NewReader(key)})
+	}
+
+	get := func(path string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", ts.URL+"/"+path, nil)
+		req.Host = "bucket1.website.test"
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, _ := get("")
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	_, err := svc.PutBucketWebsite(&s3.PutBucketWebsiteInput{
+		Bucket: aws.String("bucket1"),
+		WebsiteConfiguration: &s3.WebsiteConfiguration{
+			IndexDocument: &s3.IndexDocument{Suffix: aws.String("index.html")},
+			ErrorDocument: &s3.ErrorDocument{Key: aws.String("error.html")},
+			RoutingRules: []*s3.RoutingRule{{
+				Condition: &s3.Condition{KeyPrefixEquals: aws.String("old/")},
+				Redirect:  &s3.Redirect{ReplaceKeyPrefixWith: aws.String("docs/")},
+			}},
+		},
+	})
+	assert.Nil(err)
+
+	resp, body := get("")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("index.html", body)
+
+	resp, body = get("docs/")
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("docs/index.html", body)
+
+	resp, _ = get("docs")
+	assert.Equal(http.StatusFound, resp.StatusCode)
+	assert.Equal("http://bucket1.website.test/docs/", resp.Header.Get("Location"))
+
+	resp, _ = get("old/index.html")
+	assert.Equal(http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal("http://bucket1.website.test/docs/index.html", resp.Header.Get("Location"))
+
+	resp, body = get("missing.html")
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	assert.Equal("error.html", body)
+
+	resp, body = get("private.html")
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+	assert.Equal("error.html", body)
+
+	_, err = svc.PutBucketWebsite(&s3.PutBucketWebsiteInput{
+		Bucket:               aws.String("bucket1"),
+		WebsiteConfiguration: &s3.WebsiteConfiguration{ErrorDocument: &s3.ErrorDocument{Key: aws.String("error.html")}},
+	})
+	assert.Equal("InvalidArgument", errorCode(err))
+
+	_, err = svc.DeleteBucketWebsite(&s3.DeleteBucketWebsiteInput{Bucket: aws.String("bucket1")})
+	assert.Nil(err)
+
+	_, err = svc.GetBucketWebsite(&s3.GetBucketWebsiteInput{Bucket: aws.String("bucket1")})
+	assert.Equal("NoSuchWebsiteConfiguration", errorCode(err))
+}
+
+func TestObjectLock(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1"), ObjectLockEnabledForBucket: aws.Bool(true)})
+	assert.Nil(err)
+
+	_, err = svc.PutObjectLockConfiguration(&s3.PutObjectLockConfigurationInput{
+		Bucket: aws.String("bucket1"),
+		ObjectLockConfiguration: &s3.ObjectLockConfiguration{
+			ObjectLockEnabled: aws.String("Enabled"),
+			Rule:              &s3.ObjectLockRule{DefaultRetention: &s3.DefaultRetention{Mode: aws.String("GOVERNANCE"), Days: aws.Int64(1)}},
+		},
+	})
+	assert.Nil(err)
+
+	put, err := svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), Body: strings.NewReader("data")})
+	assert.Nil(err)
+	id := put.VersionId
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key")})
+	assert.Nil(err)
+	assert.Equal("GOVERNANCE", aws.StringValue(head.ObjectLockMode))
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), VersionId: id})
+	assert.Equal("AccessDenied", errorCode(err))
+
+	_, err = svc.PutObjectLegalHold(&s3.PutObjectLegalHoldInput{
+		Bucket: aws.String("bucket1"), Key: aws.String("key"), VersionId: id,
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String("ON")},
+	})
+	assert.Nil(err)
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), VersionId: id, BypassGovernanceRetention: aws.Bool(true)})
+	assert.Equal("AccessDenied", errorCode(err))
+
+	_, err = svc.PutObjectLegalHold(&s3.PutObjectLegalHoldInput{
+		Bucket: aws.String("bucket1"), Key: aws.String("key"), VersionId: id,
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String("OFF")},
+	})
+	assert.Nil(err)
+
+	_, err = svc.PutObjectRetention(&s3.PutObjectRetentionInput{
+		Bucket: aws.String("bucket1"), Key: aws.String("key"), VersionId: id,
+		Retention: &s3.ObjectLockRetention{Mode: aws.String("COMPLIANCE"), RetainUntilDate: aws.Time(time.Now().Add(time.Hour))},
+	})
+	assert.Equal("AccessDenied", errorCode(err))
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("key"), VersionId: id, BypassGovernanceRetention: aws.Bool(true)})
+	assert.Nil(err)
+
+	_, err = svc.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String("bucket1"),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String("Suspended")},
+	})
+	assert.Equal("InvalidBucketState", errorCode(err))
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket2")})
+
+	_, err = svc.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{Bucket: aws.String("bucket2")})
+	assert.Equal("ObjectLockConfigurationNotFoundError", errorCode(err))
+}
+
+func TestPostObject(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	policy := base64.StdEncoding.EncodeToString([]byte(`{"expiration": "2100-01-01T00:00:00.000Z", "conditions": [` +
+		`{"bucket": "bucket1"}, ["starts-with", "$key", "uploads/"], ["content-length-range", 0, 10], ` +
+		`["starts-with", "$success_action_status", ""], ["starts-with", "$x-amz-algorithm", ""], ` +
+		`["starts-with", "$x-amz-credential", ""], ["starts-with", "$x-amz-date", ""]]}`))
+
+	post := func(fields map[string]string, content string) (int, string) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		for name, v := range fields {
+			form.WriteField(name, v)
+		}
+		file, _ := form.CreateFormFile("file", "a.txt")
+		file.Write([]byte(content))
+		form.Close()
+
+		resp, err := http.Post(ts.URL+"/bucket1", form.FormDataContentType(), &body)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	signed := func(credential string) map[string]string {
+		return map[string]string{
+			"key":                   "uploads/${filename}",
+			"success_action_status": "201",
+			"policy":                policy,
+			"x-amz-algorithm":       "AWS4-HMAC-SHA256",
+			"x-amz-credential":      credential + "/20200101/us-east-1/s3/aws4_request",
+			"x-amz-date":            "20200101T000000Z",
+			"x-amz-signature":       hex.EncodeToString(hmacSHA256(SigningKeyV4(testUser.SecretKey, "20200101", "us-east-1", "s3"), policy)),
+		}
+	}
+
+	status, body := post(signed(testUser.AccessKey), "foo")
+	assert.Equal(http.StatusCreated, status)
+	assert.Contains(body, "<Key>uploads/a.txt</Key>")
+
+	obj, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("uploads/a.txt")})
+	if assert.Nil(err) {
+		data, _ := ioutil.ReadAll(obj.Body)
+		obj.Body.Close()
+		assert.Equal("foo", string(data))
+	}
+
+	status, body = post(signed(testUser.AccessKey), "longer than ten bytes")
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>EntityTooLarge</Code>")
+
+	fields := signed(testUser.AccessKey)
+	fields["Content-Type"] = "text/plain"
+	status, body = post(fields, "foo")
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "Extra input fields: content-type")
+
+	status, body = post(signed("AKIDUNKNOWN"), "foo")
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>InvalidAccessKeyId</Code>")
+
+	fields = signed(testUser.AccessKey)
+	fields["x-amz-signature"] = "0123456789abcdef"
+	status, body = post(fields, "foo")
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+
+	// Without a policy the upload is anonymous, which a private bucket refuses.
+	status, body = post(map[string]string{"key": "anonymous"}, "foo")
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>AccessDenied</Code>")
+}
+
+func TestPresignExpiry(t *testing.T) {
+
+	assert := assert.New(t)
+	s := New(Config{Users: []User{testUser}})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	svc := clientFor(ts)
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+	svc.PutObject(&s3.PutObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("foo"), Body: strings.NewReader("bar")})
+
+	get := func(expires time.Duration) int {
+		req, _ := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("foo")})
+		u, err := req.Presign(expires)
+		assert.Nil(err)
+		resp, err := http.Get(u)
+		assert.Nil(err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(http.StatusOK, get(time.Hour))
+	assert.Equal(http.StatusOK, get(7*24*time.Hour))
+	assert.Equal(http.StatusBadRequest, get(7*24*time.Hour+time.Second))
+
+	s.mu.Lock()
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	s.mu.Unlock()
+
+	assert.Equal(http.StatusForbidden, get(time.Hour))
+}
+
+// signV4 signs req in its headers as testUser, the way the SDK does.
+func signV4(req *http.Request, body string) {
+
+	signV4At(req, body, time.Now())
+}
+
+// signV4At is signV4 dating the signature at signedAt.
+func signV4At(req *http.Request, body string, signedAt time.Time) {
+
+	signer := v4.NewSigner(credentials.NewStaticCredentials(testUser.AccessKey, testUser.SecretKey, ""), func(s *v4.Signer) {
+		s.DisableURIPathEscaping = true
+	})
+	signer.Sign(req, strings.NewReader(body), "s3", "us-east-1", signedAt)
+}
+
+// TestForgedSignatures checks that the server accepts no request signed
+// with the wrong secret key, whichever way it is signed.
+func TestForgedSignatures(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	const secret = "wrong"
+	creds := credentials.NewStaticCredentials(testUser.AccessKey, secret, "")
+	now := time.Now().UTC()
+	date := now.Format(http.TimeFormat)
+	expires := fmt.Sprint(now.Add(time.Minute).Unix())
+
+	policy := base64.StdEncoding.EncodeToString([]byte(`{"expiration": "2100-01-01T00:00:00.000Z", "conditions": [{"bucket": "bucket1"}]}`))
+
+	cases := map[string]func() *http.Request{
+		"SigV4 header": func() *http.Request {
+			req, _ := http.NewRequest("PUT", ts.URL+"/bucket1/foo", strings.NewReader("foo"))
+			v4.NewSigner(creds).Sign(req, strings.NewReader("foo"), "s3", "us-east-1", now)
+			return req
+		},
+		"SigV4 query": func() *http.Request {
+			req, _ := http.NewRequest("GET", ts.URL+"/bucket1/foo", nil)
+			v4.NewSigner(creds).Presign(req, nil, "s3", "us-east-1", time.Minute, now)
+			return req
+		},
+		"SigV2 header": func() *http.Request {
+			req, _ := http.NewRequest("GET", ts.URL+"/bucket1/foo", nil)
+			req.Header.Set("Date", date)
+			req.Header.Set("Authorization", "AWS "+testUser.AccessKey+":"+SignatureV2(secret, "GET\n\n\n"+date+"\n/bucket1/foo"))
+			return req
+		},
+		"SigV2 query": func() *http.Request {
+			signature := SignatureV2(secret, "GET\n\n\n"+expires+"\n/bucket1/foo")
+			req, _ := http.NewRequest("GET", ts.URL+"/bucket1/foo?AWSAccessKeyId="+testUser.AccessKey+"&Expires="+expires+"&Signature="+url.QueryEscape(signature), nil)
+			return req
+		},
+		"POST policy": func() *http.Request {
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			for name, v := range map[string]string{
+				"key":              "foo",
+				"policy":           policy,
+				"x-amz-algorithm":  AlgorithmV4,
+				"x-amz-credential": testUser.AccessKey + "/" + now.Format("20060102") + "/us-east-1/s3/aws4_request",
+				"x-amz-date":       now.Format("20060102T150405Z"),
+				"x-amz-signature":  hex.EncodeToString(hmacSHA256(SigningKeyV4(secret, now.Format("20060102"), "us-east-1", "s3"), policy)),
+			} {
+				form.WriteField(name, v)
+			}
+			file, _ := form.CreateFormFile("file", "a.txt")
+			file.Write([]byte("foo"))
+			form.Close()
+
+			req, _ := http.NewRequest("POST", ts.URL+"/bucket1", &body)
+			req.Header.Set("Content-Type", form.FormDataContentType())
+			return req
+		},
+	}
+
+	for name, request := range cases {
+		resp, err := http.DefaultClient.Do(request())
+		if !assert.Nil(err, name) {
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(http.StatusForbidden, resp.StatusCode, name)
+		assert.Contains(string(body), "<Code>SignatureDoesNotMatch</Code>", name)
+	}
+
+	_, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("foo")})
+	assert.Equal("NotFound", errorCode(err))
+}
+
+// TestSignatureV4 checks the signature of the GET Object example in the
+// AWS documentation of SigV4 for S3.
+func TestSignatureV4(t *testing.T) {
+
+	assert := assert.New(t)
+
+	emptySHA256 := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	req, _ := http.NewRequest("GET", "https://examplebucket.s3.amazonaws.com/test.txt", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	req.Header.Set("X-Amz-Date", "20130524T000000Z")
+
+	canonical := CanonicalRequestV4(req, []string{"host", "range", "x-amz-content-sha256", "x-amz-date"}, emptySHA256, false)
+	assert.Equal("GET\n/test.txt\n\nhost:examplebucket.s3.amazonaws.com\nrange:bytes=0-9\n"+
+		"x-amz-content-sha256:"+emptySHA256+"\nx-amz-date:20130524T000000Z\n\n"+
+		"host;range;x-amz-content-sha256;x-amz-date\n"+emptySHA256, canonical)
+
+	scope := CredentialScope("20130524", "us-east-1", "s3")
+	assert.Equal("20130524/us-east-1/s3/aws4_request", scope)
+
+	stringToSign := StringToSignV4(time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC), scope, canonical)
+	assert.Equal("AWS4-HMAC-SHA256\n20130524T000000Z\n20130524/us-east-1/s3/aws4_request\n"+
+		"7344ae5b7ee6c3e7e6b0fe0640412a37625d1fbfff95c48bbb2dc43964946972", stringToSign)
+
+	assert.Equal("f0e8bdb87c964420e857bd35b5d6ed310bd44f0170aba48dd91039c6036bdb41",
+		SignatureV4("wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", scope, stringToSign))
+}
+
+func TestSigV4Auth(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	send := func(req *http.Request) (int, string) {
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	put := func(path string, body string) *http.Request {
+		req, _ := http.NewRequest("PUT", ts.URL+path, strings.NewReader(body))
+		signV4(req, body)
+		return req
+	}
+
+	status, _ := send(put("/bucket1/a%20key", "foo"))
+	assert.Equal(http.StatusOK, status)
+
+	req, _ := http.NewRequest("PUT", ts.URL+"/bucket1/foo", strings.NewReader("foo"))
+	req.Header.Set("X-Amz-Meta-Foo", "bar")
+	signV4(req, "foo")
+	req.Header.Set("X-Amz-Meta-Foo", "baz")
+	status, body := send(req)
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+
+	req = put("/bucket1/foo", "foo")
+	req.URL.Path = "/bucket1/bar"
+	status, body = send(req)
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+
+	req = put("/bucket1/foo", "foo")
+	req.Body = ioutil.NopCloser(strings.NewReader("bar"))
+	status, body = send(req)
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>XAmzContentSHA256Mismatch</Code>")
+
+	req = put("/bucket1/foo", "foo")
+	req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), "us-east-1", "us-west-2", 1))
+	status, body = send(req)
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>AuthorizationHeaderMalformed</Code>")
+
+	get, _ := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("a key")})
+	u, err := get.Presign(time.Hour)
+	assert.Nil(err)
+
+	req, _ = http.NewRequest("GET", u, nil)
+	status, _ = send(req)
+	assert.Equal(http.StatusOK, status)
+
+	req, _ = http.NewRequest("GET", strings.Replace(u, "a%20key", "foo", 1), nil)
+	status, body = send(req)
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+
+	// Other services escape the path once more in the canonical request.
+	assert.Equal("/a%20b/c%252F", escapePathV4("/a b/c%2F"))
+}
+
+// TestSignatureV2 checks the server's SigV2 strings to sign and signatures
+// against the GET and PUT examples in the AWS documentation, so the suite's
+// signer and the server cannot drift apart together.
+func TestSignatureV2(t *testing.T) {
+
+	assert := assert.New(t)
+	secret := "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+
+	req, _ := http.NewRequest("GET", "http://johnsmith.s3.amazonaws.com/photos/puppy.jpg", nil)
+	date := "Tue, 27 Mar 2007 19:36:42 +0000"
+	stringToSign := StringToSignV2(req, date, CanonicalResourceV2("/johnsmith/photos/puppy.jpg", req.URL.Query()))
+	assert.Equal("GET\n\n\n"+date+"\n/johnsmith/photos/puppy.jpg", stringToSign)
+	assert.Equal("bWq2s1WEIj+Ydj0vQ697zp+IXMU=", SignatureV2(secret, stringToSign))
+
+	req, _ = http.NewRequest("PUT", "http://johnsmith.s3.amazonaws.com/photos/puppy.jpg", nil)
+	req.Header.Set("Content-Type", "image/jpeg")
+	date = "Tue, 27 Mar 2007 21:15:45 +0000"
+	stringToSign = StringToSignV2(req, date, CanonicalResourceV2("/johnsmith/photos/puppy.jpg", req.URL.Query()))
+	assert.Equal("MyyxeRY7whkBe+bq8fHCL/2kKUg=", SignatureV2(secret, stringToSign))
+
+	assert.Equal("/johnsmith/puppy.jpg?acl&versionId=abc",
+		CanonicalResourceV2("/johnsmith/puppy.jpg", url.Values{"versionId": {"abc"}, "acl": {""}, "max-keys": {"10"}}))
+}
+
+func TestSigV2Auth(t *testing.T) {
+
+	assert := assert.New(t)
+	_, ts := newTestClient(t)
+	defer ts.Close()
+
+	send := func(path string, header map[string]string) (int, string) {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		for name, v := range header {
+			req.Header.Set(name, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// sign returns the SigV2 signature of an unsigned-body GET of resource
+	// dated date.
+	sign := func(date string, resource string) string {
+		return SignatureV2(testUser.SecretKey, "GET\n\n\n"+date+"\n"+resource)
+	}
+
+	now := time.Now().UTC()
+	date := now.Format(http.TimeFormat)
+	auth := "AWS " + testUser.AccessKey + ":" + sign(date, "/")
+
+	status, body := send("/", map[string]string{"Authorization": auth, "Date": date})
+	assert.Equal(http.StatusOK, status)
+	assert.Contains(body, "<DisplayName>main</DisplayName>")
+
+	skewed := now.Add(-time.Hour).Format(http.TimeFormat)
+	status, body = send("/", map[string]string{"Authorization": "AWS " + testUser.AccessKey + ":" + sign(skewed, "/"), "Date": skewed})
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>RequestTimeTooSkewed</Code>")
+
+	status, body = send("/", map[string]string{"Authorization": auth})
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>AccessDenied</Code>")
+
+	status, body = send("/", map[string]string{"Authorization": "AWS " + testUser.AccessKey, "Date": date})
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>InvalidArgument</Code>")
+
+	status, body = send("/", map[string]string{"Authorization": "AWS " + testUser.AccessKey + ":c2lnbmF0dXJl", "Date": date})
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+
+	// Subresources are part of the signed resource.
+	status, body = send("/?acl", map[string]string{"Authorization": auth, "Date": date})
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+
+	presigned := func(expires int64, signature string) string {
+		return fmt.Sprintf("/?AWSAccessKeyId=%s&Expires=%d&Signature=%s", testUser.AccessKey, expires, url.QueryEscape(signature))
+	}
+
+	expires := now.Add(time.Minute).Unix()
+	status, _ = send(presigned(expires, sign(fmt.Sprint(expires), "/")), nil)
+	assert.Equal(http.StatusOK, status)
+
+	status, body = send(presigned(expires, "x"), nil)
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+
+	expired := now.Add(-time.Minute).Unix()
+	status, body = send(presigned(expired, sign(fmt.Sprint(expired), "/")), nil)
+	assert.Equal(http.StatusForbidden, status)
+	assert.Contains(body, "Request has expired")
+}
+
+func TestChunkedUpload(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	put := func(key string, body string, header map[string]string) (int, string) {
+		req, _ := http.NewRequest("PUT", ts.URL+"/bucket1/"+key, strings.NewReader(body))
+		req.Header.Set("X-Amz-Content-Sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+		req.Header.Set("Content-Encoding", "aws-chunked,deflate")
+		for name, v := range header {
+			req.Header.Set(name, v)
+		}
+		signV4(req, body)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	trailer := map[string]string{"X-Amz-Decoded-Content-Length": "6", "X-Amz-Trailer": "x-amz-checksum-crc32"}
+
+	status, _ := put("foo", "3\r\nfoo\r\n3\r\nbar\r\n0\r\nx-amz-checksum-crc32:nvYflQ==\r\n\r\n", trailer)
+	assert.Equal(http.StatusOK, status)
+
+	obj, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("foo")})
+	if assert.Nil(err) {
+		data, _ := ioutil.ReadAll(obj.Body)
+		obj.Body.Close()
+		assert.Equal("foobar", string(data))
+		assert.Equal("deflate", aws.StringValue(obj.ContentEncoding))
+	}
+
+	status, body := put("bad", "3\r\nfoo\r\n3\r\nbar\r\n0\r\nx-amz-checksum-crc32:jHNlIQ==\r\n\r\n", trailer)
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>BadDigest</Code>")
+
+	status, body = put("bad", "3\r\nfoo\r\n3\r\nbar\r\n", trailer)
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>IncompleteBody</Code>")
+
+	status, body = put("bad", "3\r\nfoo\r\n0\r\n\r\n", map[string]string{"X-Amz-Decoded-Content-Length": "6"})
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>IncompleteBody</Code>")
+
+	// A chunk size far beyond the body must not be allocated.
+	status, body = put("bad", "ffffffffff\r\nfoo\r\n0\r\n\r\n", trailer)
+	assert.Equal(http.StatusBadRequest, status)
+	assert.Contains(body, "<Code>IncompleteBody</Code>")
+}
+
+// TestChunkSignatureV4 checks the server's chunk signatures against the
+// example upload in the AWS documentation of
+// STREAMING-AWS4-HMAC-SHA256-PAYLOAD.
+func TestChunkSignatureV4(t *testing.T) {
+
+	assert := assert.New(t)
+
+	date := time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+	key := SigningKeyV4("wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", "20130524", "us-east-1", "s3")
+	scope := CredentialScope("20130524", "us-east-1", "s3")
+
+	previous := "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+	for _, c := range []struct {
+		chunk     string
+		signature string
+	}{
+		{strings.Repeat("a", 65536), "ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648"},
+		{strings.Repeat("a", 1024), "0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497"},
+		{"", "b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9"},
+	} {
+		previous = ChunkSignatureV4(key, date, scope, previous, []byte(c.chunk))
+		assert.Equal(c.signature, previous)
+	}
+}
+
+func TestSignedChunkedUpload(t *testing.T) {
+
+	assert := assert.New(t)
+	svc, ts := newTestClient(t)
+	defer ts.Close()
+
+	svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("bucket1")})
+
+	// put uploads chunks signed one by one, sending the signatures of
+	// corrupt, by chunk index, in place of the right ones.
+	put := func(key string, chunks []string, corrupt map[int]string) (int, string) {
+		req, _ := http.NewRequest("PUT", ts.URL+"/bucket1/"+key, nil)
+		req.Header.Set("X-Amz-Content-Sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD")
+		req.Header.Set("Content-Encoding", "aws-chunked")
+		req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(len(strings.Join(chunks, ""))))
+
+		now := time.Now().UTC()
+		signV4At(req, "", now)
+
+		auth := req.Header.Get("Authorization")
+		previous := auth[strings.Index(auth, "Signature=")+len("Signature="):]
+		signingKey := SigningKeyV4(testUser.SecretKey, now.Format("20060102"), "us-east-1", "s3")
+		scope := CredentialScope(now.Format("20060102"), "us-east-1", "s3")
+
+		var body strings.Builder
+		for i, chunk := range append(chunks, "") {
+			previous = ChunkSignatureV4(signingKey, now, scope, previous, []byte(chunk))
+			signature := previous
+			if v, ok := corrupt[i]; ok {
+				signature = v
+			}
+			fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(chunk), signature, chunk)
+		}
+		req.Body = ioutil.NopCloser(strings.NewReader(body.String()))
+		req.ContentLength = int64(body.Len())
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, _ := put("foo", []string{"foo", "bar"}, nil)
+	assert.Equal(http.StatusOK, status)
+
+	obj, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("foo")})
+	if assert.Nil(err) {
+		data, _ := ioutil.ReadAll(obj.Body)
+		obj.Body.Close()
+		assert.Equal("foobar", string(data))
+	}
+
+	for _, corrupt := range []map[int]string{{1: strings.Repeat("0", 64)}, {2: strings.Repeat("0", 64)}, {0: ""}} {
+		status, body := put("bad", []string{"foo", "bar"}, corrupt)
+		assert.Equal(http.StatusForbidden, status)
+		assert.Contains(body, "<Code>SignatureDoesNotMatch</Code>")
+	}
+
+	_, err = svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("bucket1"), Key: aws.String("bad")})
+	assert.NotNil(err)
+}
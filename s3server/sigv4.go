@@ -0,0 +1,421 @@
+package s3server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlgorithmV4 names SigV4 in Authorization headers, presigned queries and
+// strings to sign.
+const AlgorithmV4 = "AWS4-HMAC-SHA256"
+
+// UnsignedPayload is the payload hash of requests that do not sign their
+// body, which presigned URLs default to.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// VerifierV4 checks SigV4 signed and presigned requests the way S3 does,
+// and fails them with the errors S3 answers with. The server verifies
+// its requests with one.
+type VerifierV4 struct {
+	// Region and Service are expected in the credential scope.
+	Region  string
+	Service string
+
+	// Secret returns the secret key of an access key, and false when the
+	// access key is unknown.
+	Secret func(accessKey string) (string, bool)
+
+	// Now is the clock requests are checked against. Defaults to time.Now.
+	Now func() time.Time
+
+	// MaxSkew is how far the date of a request signed in its headers may
+	// be from Now. Defaults to 15 minutes.
+	MaxSkew time.Duration
+
+	// DisableURIPathEscaping leaves the path as sent in the canonical
+	// request instead of escaping it once more, like the signer option of
+	// the same name. S3 does not escape it again; other services do.
+	DisableURIPathEscaping bool
+}
+
+// CredentialScope returns the scope a SigV4 signature is valid for: a day,
+// formatted yyyymmdd, a region and a service.
+func CredentialScope(day string, region string, service string) string {
+
+	return strings.Join([]string{day, region, service, "aws4_request"}, "/")
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data keyed with key.
+func hmacSHA256(key []byte, data string) []byte {
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// SigningKeyV4 derives the SigV4 signing key of secret for a day, formatted
+// yyyymmdd, region and service.
+func SigningKeyV4(secret string, day string, region string, service string) []byte {
+
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{day, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+// escapePathV4 escapes every byte of path but the unreserved characters
+// and slashes, as the canonical request of services other than S3 does.
+func escapePathV4(path string) string {
+
+	var escaped strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			escaped.WriteByte(c)
+		} else {
+			escaped.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+
+	return escaped.String()
+}
+
+// CanonicalRequestV4 returns the canonical request of r covering
+// signedHeaders, lowercase, and a body hashing to payloadHash. The query
+// string leaves out X-Amz-Signature, so it serves presigned URLs as well.
+func CanonicalRequestV4(r *http.Request, signedHeaders []string, payloadHash string, escapePath bool) string {
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if escapePath {
+		path = escapePathV4(path)
+	}
+
+	query := r.URL.Query()
+	query.Del("X-Amz-Signature")
+	for _, values := range query {
+		sort.Strings(values)
+	}
+
+	headers := make([]string, len(signedHeaders))
+	for i, name := range signedHeaders {
+		var values []string
+		if name == "host" {
+			values = []string{r.Host}
+			if r.Host == "" {
+				values = []string{r.URL.Host}
+			}
+		} else {
+			for _, v := range r.Header[http.CanonicalHeaderKey(name)] {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+		}
+		headers[i] = name + ":" + strings.Join(values, ",") + "\n"
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		path,
+		strings.Replace(query.Encode(), "+", "%20", -1),
+		strings.Join(headers, ""),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// StringToSignV4 returns the string a SigV4 signature of a canonical
+// request made at date for scope is computed over.
+func StringToSignV4(date time.Time, scope string, canonicalRequest string) string {
+
+	sum := sha256.Sum256([]byte(canonicalRequest))
+
+	return strings.Join([]string{
+		AlgorithmV4,
+		date.UTC().Format("20060102T150405Z"),
+		scope,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+// SignatureV4 returns the hex signature of stringToSign by secret for
+// scope.
+func SignatureV4(secret string, scope string, stringToSign string) string {
+
+	parts := strings.Split(scope, "/")
+	if len(parts) != 4 {
+		return ""
+	}
+
+	return hex.EncodeToString(hmacSHA256(SigningKeyV4(secret, parts[0], parts[1], parts[2]), stringToSign))
+}
+
+// ChunkSignatureV4 returns the signature of a chunk of an aws-chunked body
+// signed with key at date for scope. It chains from previous, the signature
+// of the chunk before, or of the request itself for the first chunk.
+func ChunkSignatureV4(key []byte, date time.Time, scope string, previous string, chunk []byte) string {
+
+	empty := sha256.Sum256(nil)
+	sum := sha256.Sum256(chunk)
+
+	stringToSign := strings.Join([]string{
+		AlgorithmV4 + "-PAYLOAD",
+		date.UTC().Format("20060102T150405Z"),
+		scope,
+		previous,
+		hex.EncodeToString(empty[:]),
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signatureV4 is a verified SigV4 signature, which the chunk signatures of
+// an aws-chunked body chain from.
+type signatureV4 struct {
+	key       []byte
+	date      time.Time
+	scope     string
+	signature string
+}
+
+// authV4 is what a SigV4 request says about its own signature, whether it
+// came in the Authorization header or the query string.
+type authV4 struct {
+	presigned     bool
+	credential    string
+	signedHeaders string
+	signature     string
+	date          string
+	expires       string
+}
+
+// malformed returns the error of a request whose SigV4 fields are missing
+// or inconsistent, which depends on where they came from.
+func (a *authV4) malformed(message string) *Error {
+
+	if a.presigned {
+		return errAuthorizationQueryParameters.withMessage(message)
+	}
+	return errAuthorizationHeaderMalformed.withMessage("The authorization header is malformed; " + message)
+}
+
+// isSignedV4 reports whether r is signed with SigV4, in its presigned
+// query string or its Authorization header.
+func isSignedV4(r *http.Request) bool {
+
+	query := r.URL.Query()
+
+	return query.Get("X-Amz-Algorithm") != "" || query.Get("X-Amz-Credential") != "" ||
+		strings.HasPrefix(r.Header.Get("Authorization"), AlgorithmV4+" ")
+}
+
+// parseAuthV4 reads the SigV4 fields of r from its presigned query string
+// or else its Authorization header.
+func parseAuthV4(r *http.Request) (*authV4, *Error) {
+
+	query := r.URL.Query()
+
+	if query.Get("X-Amz-Algorithm") != "" || query.Get("X-Amz-Credential") != "" {
+		a := &authV4{
+			presigned:     true,
+			credential:    query.Get("X-Amz-Credential"),
+			signedHeaders: query.Get("X-Amz-SignedHeaders"),
+			signature:     query.Get("X-Amz-Signature"),
+			date:          query.Get("X-Amz-Date"),
+			expires:       query.Get("X-Amz-Expires"),
+		}
+		if query.Get("X-Amz-Algorithm") != AlgorithmV4 {
+			return nil, a.malformed("X-Amz-Algorithm only supports \"" + AlgorithmV4 + "\"")
+		}
+		if a.credential == "" || a.signedHeaders == "" || a.signature == "" || a.date == "" || a.expires == "" {
+			return nil, a.malformed("Query-string authentication version 4 requires the X-Amz-Algorithm, X-Amz-Credential, X-Amz-Signature, X-Amz-Date, X-Amz-SignedHeaders, and X-Amz-Expires parameters.")
+		}
+		return a, nil
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil, errAccessDenied.withMessage("Request is missing an Authorization header or X-Amz-Credential.")
+	}
+	if !strings.HasPrefix(auth, AlgorithmV4+" ") {
+		return nil, errAccessDenied.withMessage("Only " + AlgorithmV4 + " is supported.")
+	}
+
+	a := &authV4{date: r.Header.Get("X-Amz-Date")}
+	if a.date == "" {
+		a.date = r.Header.Get("Date")
+	}
+
+	for _, field := range strings.Split(strings.TrimPrefix(auth, AlgorithmV4+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			return nil, a.malformed("it must carry Credential, SignedHeaders and Signature.")
+		}
+		switch kv[0] {
+		case "Credential":
+			a.credential = kv[1]
+		case "SignedHeaders":
+			a.signedHeaders = kv[1]
+		case "Signature":
+			a.signature = kv[1]
+		}
+	}
+	if a.credential == "" || a.signedHeaders == "" || a.signature == "" {
+		return nil, a.malformed("it must carry Credential, SignedHeaders and Signature.")
+	}
+
+	return a, nil
+}
+
+// checkDate checks the date of a request against the verifier's clock: a
+// presigned URL must be valid for at most a week and not have expired, and
+// a request signed in its headers must be dated within MaxSkew.
+func (v *VerifierV4) checkDate(a *authV4) (time.Time, *Error) {
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+
+	date, err := time.Parse("20060102T150405Z", a.date)
+
+	if a.presigned {
+		if err != nil {
+			return date, a.malformed("X-Amz-Date must be in the ISO8601 Long Format \"yyyyMMdd'T'HHmmss'Z'\"")
+		}
+
+		expires, err := strconv.Atoi(a.expires)
+		if err != nil || expires < 0 {
+			return date, a.malformed("X-Amz-Expires should be a number")
+		}
+		if expires > maxPresignExpires {
+			return date, a.malformed("X-Amz-Expires must be less than a week (in seconds) that is; 604800")
+		}
+
+		if now().After(date.Add(time.Duration(expires) * time.Second)) {
+			return date, errAccessDenied.withMessage("Request has expired")
+		}
+		return date, nil
+	}
+
+	if err != nil {
+		if date, err = http.ParseTime(a.date); err != nil {
+			return date, errAccessDenied.withMessage("AWS authentication requires a valid Date or x-amz-date header")
+		}
+	}
+
+	skew := v.MaxSkew
+	if skew == 0 {
+		skew = maxClockSkew
+	}
+	if d := now().Sub(date); d > skew || d < -skew {
+		return date, errRequestTimeTooSkewed
+	}
+
+	return date, nil
+}
+
+// payloadHash returns the hash of the body r was signed with: the one
+// x-amz-content-sha256 declares, which must match body unless it is
+// UNSIGNED-PAYLOAD or a streaming one, or else the hash of body itself.
+// Presigned URLs do not sign their body unless they say so.
+func payloadHash(r *http.Request, a *authV4, body []byte) (string, *Error) {
+
+	declared := r.Header.Get("X-Amz-Content-Sha256")
+	if a.presigned && declared == "" {
+		declared = r.URL.Query().Get("X-Amz-Content-Sha256")
+	}
+
+	sum := sha256.Sum256(body)
+	actual := hex.EncodeToString(sum[:])
+
+	switch {
+	case declared == "" && a.presigned:
+		return UnsignedPayload, nil
+	case declared == "":
+		return actual, nil
+	case declared == UnsignedPayload || strings.HasPrefix(declared, "STREAMING-"):
+		return declared, nil
+	case declared != actual:
+		return "", errXAmzContentSHA256Mismatch
+	}
+
+	return declared, nil
+}
+
+// Verify checks the SigV4 signature of r, whose body has already been read
+// into body, in its Authorization header or presigned query string. It
+// returns nil for a valid request, or else the *Error S3 would fail it
+// with.
+//
+// Checks run in the order S3 runs them, so a request with several faults
+// fails with the same error: the form of the signature fields, the access
+// key, the credential scope, the date, the payload hash and finally the
+// signature itself.
+func (v *VerifierV4) Verify(r *http.Request, body []byte) error {
+
+	_, err := v.verify(r, body)
+	return err
+}
+
+// verify is Verify returning the verified signature of r.
+func (v *VerifierV4) verify(r *http.Request, body []byte) (*signatureV4, error) {
+
+	a, err := parseAuthV4(r)
+	if err != nil {
+		return nil, err
+	}
+
+	credential := strings.Split(a.credential, "/")
+	if len(credential) != 5 || credential[4] != "aws4_request" {
+		return nil, a.malformed("the Credential is mal-formed; expecting \"<YOUR-AKID>/YYYYMMDD/REGION/SERVICE/aws4_request\".")
+	}
+	accessKey, day, region, service := credential[0], credential[1], credential[2], credential[3]
+
+	secret, ok := v.Secret(accessKey)
+	if !ok {
+		return nil, errInvalidAccessKeyID
+	}
+
+	if region != v.Region {
+		return nil, a.malformed("the region '" + region + "' is wrong; expecting '" + v.Region + "'")
+	}
+	if service != v.Service {
+		return nil, a.malformed("incorrect service '" + service + "'. This endpoint belongs to '" + v.Service + "'.")
+	}
+
+	date, err := v.checkDate(a)
+	if err != nil {
+		return nil, err
+	}
+	if date.UTC().Format("20060102") != day {
+		return nil, a.malformed("Invalid credential date \"" + day + "\". This date is not the same as X-Amz-Date: \"" + date.UTC().Format("20060102") + "\".")
+	}
+
+	hash, err := payloadHash(r, a, body)
+	if err != nil {
+		return nil, err
+	}
+
+	signedHeaders := strings.Split(a.signedHeaders, ";")
+	canonicalRequest := CanonicalRequestV4(r, signedHeaders, hash, !v.DisableURIPathEscaping)
+	scope := CredentialScope(day, region, service)
+	stringToSign := StringToSignV4(date, scope, canonicalRequest)
+
+	key := SigningKeyV4(secret, day, region, service)
+	if !hmac.Equal([]byte(hex.EncodeToString(hmacSHA256(key, stringToSign))), []byte(a.signature)) {
+		return nil, errSignatureDoesNotMatch
+	}
+
+	return &signatureV4{key: key, date: date, scope: scope, signature: a.signature}, nil
+}
@@ -0,0 +1,262 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits S3 puts on tag sets.
+const (
+	maxObjectTags  = 10
+	maxBucketTags  = 50
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+)
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+// validTagText reports whether s only holds the characters S3 allows in
+// tag keys and values: letters, digits, spaces and + - = . _ : / @.
+func validTagText(s string) bool {
+
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != ' ' && !strings.ContainsRune("+-=._:/@", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTags validates the keys and values of a tag set. The number of
+// tags allowed depends on what is tagged and is left to the caller.
+func checkTags(tags []tag) error {
+
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		switch {
+		case t.Key == "" || !validTagText(t.Key):
+			return errInvalidTag.withMessage("The TagKey you have provided is invalid")
+		case !validTagText(t.Value):
+			return errInvalidTag.withMessage("The TagValue you have provided is invalid")
+		case utf8.RuneCountInString(t.Key) > maxTagKeyLen:
+			return errInvalidTag.withMessage("The TagKey you have provided is too long, max " + strconv.Itoa(maxTagKeyLen))
+		case utf8.RuneCountInString(t.Value) > maxTagValueLen:
+			return errInvalidTag.withMessage("The TagValue you have provided is too long, max " + strconv.Itoa(maxTagValueLen))
+		case strings.HasPrefix(strings.ToLower(t.Key), "aws:"):
+			return errInvalidTag.withMessage("Your TagKey cannot be prefixed with aws:")
+		case seen[t.Key]:
+			return errInvalidTag.withMessage("Cannot provide multiple Tags with the same key")
+		}
+		seen[t.Key] = true
+	}
+
+	return nil
+}
+
+// checkObjectTagCount fails tag sets too large for an object.
+func checkObjectTagCount(tags []tag) error {
+
+	if len(tags) > maxObjectTags {
+		return errBadRequest.withMessage("Object tags cannot be greater than " + strconv.Itoa(maxObjectTags))
+	}
+	return nil
+}
+
+// parseTagging parses and validates a Tagging document.
+func parseTagging(body []byte) ([]tag, error) {
+
+	var doc tagging
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, errMalformedXML
+	}
+
+	if err := checkTags(doc.TagSet); err != nil {
+		return nil, err
+	}
+
+	return doc.TagSet, nil
+}
+
+// taggingHeader parses and validates the x-amz-tagging header of r, which
+// carries the tags URL encoded like a query string.
+func taggingHeader(r *http.Request) ([]tag, error) {
+
+	v := r.Header.Get("x-amz-tagging")
+	if v == "" {
+		return nil, nil
+	}
+
+	query, err := url.ParseQuery(v)
+	if err != nil {
+		return nil, errInvalidArgument.withMessage("The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+
+	var tags []tag
+	for key, values := range query {
+		if len(values) > 1 {
+			return nil, errInvalidTag.withMessage("Cannot provide multiple Tags with the same key")
+		}
+		tags = append(tags, tag{key, values[0]})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+
+	if err := checkObjectTagCount(tags); err != nil {
+		return nil, err
+	}
+
+	if err := checkTags(tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// writeTaggingCount reports the number of tags of obj on a GET or HEAD.
+func writeTaggingCount(h http.Header, obj *object) {
+
+	if len(obj.tags) > 0 {
+		h.Set("x-amz-tagging-count", strconv.Itoa(len(obj.tags)))
+	}
+}
+
+// taggedObject looks up the object, or the version of it, whose tags a
+// request reads or writes, and checks that the requester may do so.
+func (s *Server) taggedObject(req *request, perm string) (*bucket, *object, error) {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := b.lookup(req.key, req.param("versionId"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allowed := obj.acl.allows(req.user, perm)
+	if perm == permWrite {
+		allowed = b.acl.allows(req.user, permWrite)
+	}
+
+	if !s.permitted(req, b, req.action, req.key, allowed) {
+		return nil, nil, errAccessDenied
+	}
+
+	writeVersionHeaders(req.w.Header(), b, obj)
+
+	return b, obj, nil
+}
+
+func (s *Server) getObjectTagging(req *request) error {
+
+	_, obj, err := s.taggedObject(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	writeXML(req.w, http.StatusOK, tagging{TagSet: obj.tags})
+
+	return nil
+}
+
+func (s *Server) putObjectTagging(req *request) error {
+
+	_, obj, err := s.taggedObject(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	tags, err := parseTagging(req.body)
+	if err != nil {
+		return err
+	}
+
+	if err := checkObjectTagCount(tags); err != nil {
+		return err
+	}
+
+	obj.tags = tags
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) deleteObjectTagging(req *request) error {
+
+	_, obj, err := s.taggedObject(req, permWrite)
+	if err != nil {
+		return err
+	}
+
+	obj.tags = nil
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (s *Server) getBucketTagging(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if len(b.tags) == 0 {
+		return errNoSuchTagSet
+	}
+
+	writeXML(req.w, http.StatusOK, tagging{TagSet: b.tags})
+
+	return nil
+}
+
+// putBucketTagging replaces the tags of a bucket. Like S3, an empty tag
+// set leaves the bucket untagged.
+func (s *Server) putBucketTagging(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	tags, err := parseTagging(req.body)
+	if err != nil {
+		return err
+	}
+
+	if len(tags) > maxBucketTags {
+		return errInvalidTag.withMessage("Bucket tag count cannot be greater than " + strconv.Itoa(maxBucketTags))
+	}
+
+	b.tags = tags
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func (s *Server) deleteBucketTagging(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	b.tags = nil
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
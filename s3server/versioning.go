@@ -0,0 +1,334 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bucket versioning states. A bucket that never had versioning configured
+// has an empty state.
+const (
+	versioningEnabled   = "Enabled"
+	versioningSuspended = "Suspended"
+)
+
+// nullVersion is the version ID of objects written while versioning is off
+// or suspended.
+const nullVersion = "null"
+
+// put stores obj as the current version of its key, assigning it a
+// version ID according to the bucket's versioning state.
+func (b *bucket) put(obj *object) {
+
+	if b.versioning == versioningEnabled {
+		obj.versionID = newID(16)
+	} else {
+		obj.versionID = nullVersion
+		b.dropVersion(obj.key, nullVersion)
+	}
+
+	b.versions[obj.key] = append(b.versions[obj.key], obj)
+
+	if obj.deleteMarker {
+		delete(b.objects, obj.key)
+	} else {
+		b.objects[obj.key] = obj
+	}
+}
+
+// remove deletes the current version of key. In a bucket with versioning
+// configured the data is kept and a delete marker is returned instead.
+func (b *bucket) remove(key string, owner *User, now time.Time) *object {
+
+	if b.versioning == "" {
+		delete(b.objects, key)
+		delete(b.versions, key)
+		return nil
+	}
+
+	marker := &object{key: key, owner: owner, lastModified: now, deleteMarker: true}
+	b.put(marker)
+
+	return marker
+}
+
+// dropVersion permanently removes one version of key and returns it, or
+// nil if there is no such version. The previous version becomes current.
+func (b *bucket) dropVersion(key, id string) *object {
+
+	versions := b.versions[key]
+
+	for i, v := range versions {
+		if v.versionID != id {
+			continue
+		}
+
+		versions = append(versions[:i:i], versions[i+1:]...)
+		if len(versions) == 0 {
+			delete(b.versions, key)
+			delete(b.objects, key)
+			return v
+		}
+
+		b.versions[key] = versions
+		if latest := versions[len(versions)-1]; latest.deleteMarker {
+			delete(b.objects, key)
+		} else {
+			b.objects[key] = latest
+		}
+		return v
+	}
+
+	return nil
+}
+
+// lookup returns the current version of key, or the version id when one
+// is given. A delete marker is returned together with the error reading
+// it causes, so callers can report it.
+func (b *bucket) lookup(key, id string) (*object, error) {
+
+	versions := b.versions[key]
+
+	if id == "" {
+		if len(versions) == 0 {
+			return nil, errNoSuchKey
+		}
+		latest := versions[len(versions)-1]
+		if latest.deleteMarker {
+			return latest, errNoSuchKey
+		}
+		return latest, nil
+	}
+
+	for _, v := range versions {
+		if v.versionID == id {
+			if v.deleteMarker {
+				return v, errMethodNotAllowed
+			}
+			return v, nil
+		}
+	}
+
+	return nil, errNoSuchVersion
+}
+
+// writeVersionHeaders reports the version of obj on responses for buckets
+// with versioning configured.
+func writeVersionHeaders(h http.Header, b *bucket, obj *object) {
+
+	if b.versioning == "" {
+		return
+	}
+
+	h.Set("x-amz-version-id", obj.versionID)
+	if obj.deleteMarker {
+		h.Set("x-amz-delete-marker", "true")
+	}
+}
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+func (s *Server) getBucketVersioning(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	writeXML(req.w, http.StatusOK, versioningConfiguration{Status: b.versioning})
+
+	return nil
+}
+
+func (s *Server) putBucketVersioning(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	var conf struct {
+		Status string `xml:"Status"`
+	}
+
+	if err := xml.Unmarshal(req.body, &conf); err != nil {
+		return errMalformedXML
+	}
+
+	if b.objectLock != nil && conf.Status != versioningEnabled {
+		return errInvalidBucketState.withMessage("An Object Lock configuration is present on this bucket, so the versioning state cannot be changed.")
+	}
+
+	switch conf.Status {
+	case versioningEnabled, versioningSuspended:
+		b.versioning = conf.Status
+	default:
+		return errMalformedXML
+	}
+
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+type versionEntry struct {
+	XMLName      xml.Name `xml:"Version"`
+	Key          string   `xml:"Key"`
+	VersionID    string   `xml:"VersionId"`
+	IsLatest     bool     `xml:"IsLatest"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+	Size         int      `xml:"Size"`
+	StorageClass string   `xml:"StorageClass"`
+	Owner        *owner   `xml:"Owner,omitempty"`
+}
+
+type deleteMarkerEntry struct {
+	XMLName      xml.Name `xml:"DeleteMarker"`
+	Key          string   `xml:"Key"`
+	VersionID    string   `xml:"VersionId"`
+	IsLatest     bool     `xml:"IsLatest"`
+	LastModified string   `xml:"LastModified"`
+	Owner        *owner   `xml:"Owner,omitempty"`
+}
+
+type listVersionsResult struct {
+	XMLName             xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+	Name                string   `xml:"Name"`
+	Prefix              string   `xml:"Prefix"`
+	KeyMarker           string   `xml:"KeyMarker"`
+	VersionIDMarker     string   `xml:"VersionIdMarker"`
+	NextKeyMarker       string   `xml:"NextKeyMarker,omitempty"`
+	NextVersionIDMarker string   `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int      `xml:"MaxKeys"`
+	Delimiter           string   `xml:"Delimiter,omitempty"`
+	IsTruncated         bool     `xml:"IsTruncated"`
+
+	// Entries holds versions and delete markers in listing order.
+	Entries        []interface{}
+	CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+}
+
+func (s *Server) listObjectVersions(req *request) error {
+
+	b, err := s.bucketFor(req, permRead)
+	if err != nil {
+		return err
+	}
+
+	maxKeys, err := maxKeysParam(req, "max-keys")
+	if err != nil {
+		return err
+	}
+
+	prefix, delimiter := req.param("prefix"), req.param("delimiter")
+	keyMarker, versionMarker := req.param("key-marker"), req.param("version-id-marker")
+
+	result := listVersionsResult{
+		Name:            b.name,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIDMarker: versionMarker,
+		MaxKeys:         maxKeys,
+		Delimiter:       delimiter,
+	}
+
+	var keys []string
+	for key := range b.versions {
+		if strings.HasPrefix(key, prefix) && key >= keyMarker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	count := 0
+	seen := make(map[string]bool)
+
+	for _, key := range keys {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				p := key[:len(prefix)+i+len(delimiter)]
+				if seen[p] || p <= keyMarker {
+					continue
+				}
+				if count == maxKeys {
+					result.IsTruncated = true
+					break
+				}
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{p})
+				result.NextKeyMarker, result.NextVersionIDMarker = p, ""
+				count++
+				continue
+			}
+		}
+
+		versions := b.versions[key]
+
+		// Versions are listed newest first; a version ID marker resumes
+		// after that version of the marker key.
+		start := len(versions) - 1
+		if key == keyMarker {
+			if versionMarker == "" {
+				continue
+			}
+			for i, v := range versions {
+				if v.versionID == versionMarker {
+					start = i - 1
+				}
+			}
+		}
+
+		for i := start; i >= 0; i-- {
+			if count == maxKeys {
+				result.IsTruncated = true
+				break
+			}
+
+			v := versions[i]
+			latest := i == len(versions)-1
+
+			if v.deleteMarker {
+				result.Entries = append(result.Entries, deleteMarkerEntry{
+					Key:          key,
+					VersionID:    v.versionID,
+					IsLatest:     latest,
+					LastModified: formatTime(v.lastModified),
+					Owner:        ownerOf(v.owner),
+				})
+			} else {
+				result.Entries = append(result.Entries, versionEntry{
+					Key:          key,
+					VersionID:    v.versionID,
+					IsLatest:     latest,
+					LastModified: formatTime(v.lastModified),
+					ETag:         v.etag,
+					Size:         len(v.data),
+					StorageClass: "STANDARD",
+					Owner:        ownerOf(v.owner),
+				})
+			}
+
+			result.NextKeyMarker, result.NextVersionIDMarker = key, v.versionID
+			count++
+		}
+
+		if result.IsTruncated {
+			break
+		}
+	}
+
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
+
+	writeXML(req.w, http.StatusOK, result)
+
+	return nil
+}
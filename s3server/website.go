@@ -0,0 +1,330 @@
+package s3server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type websiteConfiguration struct {
+	XMLName               xml.Name             `xml:"http://s3.amazonaws.com/doc/2006-03-01/ WebsiteConfiguration"`
+	RedirectAllRequestsTo *redirectAll         `xml:"RedirectAllRequestsTo"`
+	IndexDocument         *indexDocument       `xml:"IndexDocument"`
+	ErrorDocument         *errorDocument       `xml:"ErrorDocument"`
+	RoutingRules          []websiteRoutingRule `xml:"RoutingRules>RoutingRule"`
+}
+
+type redirectAll struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type indexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type errorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type websiteRoutingRule struct {
+	Condition *struct {
+		KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+		HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+	} `xml:"Condition"`
+	Redirect *websiteRedirect `xml:"Redirect"`
+}
+
+type websiteRedirect struct {
+	HostName             string  `xml:"HostName,omitempty"`
+	HttpRedirectCode     string  `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string  `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith"`
+	ReplaceKeyWith       *string `xml:"ReplaceKeyWith"`
+}
+
+// checkProtocol checks the protocol of a redirect, which may be left out.
+func checkProtocol(protocol string) error {
+
+	if protocol != "" && protocol != "http" && protocol != "https" {
+		return errInvalidRequest.withMessage("Invalid protocol, protocol can be http or https. If not defined the protocol will be selected automatically.")
+	}
+	return nil
+}
+
+// check validates a website configuration.
+func (c *websiteConfiguration) check() error {
+
+	if r := c.RedirectAllRequestsTo; r != nil {
+		if c.IndexDocument != nil || c.ErrorDocument != nil || len(c.RoutingRules) > 0 {
+			return errInvalidRequest.withMessage("RedirectAllRequestsTo cannot be provided in conjunction with other Routing Rules.")
+		}
+		if r.HostName == "" {
+			return errMalformedXML
+		}
+		return checkProtocol(r.Protocol)
+	}
+
+	if c.IndexDocument == nil {
+		return errInvalidArgument.withMessage("A value for IndexDocument Suffix must be provided if RedirectAllRequestsTo is empty")
+	}
+
+	if suffix := c.IndexDocument.Suffix; suffix == "" || strings.Contains(suffix, "/") {
+		return errInvalidArgument.withMessage("The IndexDocument Suffix is not well formed")
+	}
+
+	for _, rule := range c.RoutingRules {
+		r := rule.Redirect
+		if r == nil {
+			return errMalformedXML
+		}
+
+		if r.ReplaceKeyPrefixWith != nil && r.ReplaceKeyWith != nil {
+			return errInvalidRequest.withMessage("You can only define ReplaceKeyPrefix or ReplaceKey but not both.")
+		}
+
+		if r.HttpRedirectCode != "" {
+			code, err := strconv.Atoi(r.HttpRedirectCode)
+			if err != nil || code <= 300 || code > 399 {
+				return errInvalidRequest.withMessage("The provided HTTP redirect code (" + r.HttpRedirectCode + ") is not valid. Valid codes are 3XX except 300.")
+			}
+		}
+
+		if err := checkProtocol(r.Protocol); err != nil {
+			return err
+		}
+
+		if rule.Condition != nil && rule.Condition.HttpErrorCodeReturnedEquals != "" {
+			code, err := strconv.Atoi(rule.Condition.HttpErrorCodeReturnedEquals)
+			if err != nil || code < 400 || code > 599 {
+				return errInvalidRequest.withMessage("The provided HTTP error code (" + rule.Condition.HttpErrorCodeReturnedEquals + ") is not valid. Valid codes are 4XX or 5XX.")
+			}
+		}
+	}
+
+	return nil
+}
+
+// applies reports whether a routing rule applies to a request for key that
+// failed with status, or that has not been served yet when status is 0.
+func (r *websiteRoutingRule) applies(key string, status int) bool {
+
+	if r.Condition == nil {
+		return status == 0
+	}
+
+	if !strings.HasPrefix(key, r.Condition.KeyPrefixEquals) {
+		return false
+	}
+
+	if r.Condition.HttpErrorCodeReturnedEquals == "" {
+		return status == 0
+	}
+
+	return r.Condition.HttpErrorCodeReturnedEquals == strconv.Itoa(status)
+}
+
+// redirect answers a website request with a redirect to key on host over
+// protocol, the request's own host and protocol when they are empty.
+func redirect(req *request, protocol, host, key string, code int) error {
+
+	if protocol == "" {
+		protocol = "http"
+		if req.r.TLS != nil {
+			protocol = "https"
+		}
+	}
+
+	if host == "" {
+		host = req.r.Host
+	}
+
+	location := url.URL{Scheme: protocol, Host: host, Path: "/" + key}
+
+	req.w.Header().Set("Location", location.String())
+	req.w.WriteHeader(code)
+
+	return nil
+}
+
+// followRule answers a request for key with the redirect of a rule.
+func followRule(req *request, rule *websiteRoutingRule, key string) error {
+
+	r := rule.Redirect
+
+	switch {
+	case r.ReplaceKeyWith != nil:
+		key = *r.ReplaceKeyWith
+	case r.ReplaceKeyPrefixWith != nil:
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = *r.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+
+	code := http.StatusMovedPermanently
+	if r.HttpRedirectCode != "" {
+		code, _ = strconv.Atoi(r.HttpRedirectCode)
+	}
+
+	return redirect(req, r.Protocol, r.HostName, key, code)
+}
+
+// websiteObject returns key if anonymous users may read it.
+func (s *Server) websiteObject(req *request, key string) (*object, error) {
+
+	_, obj, err := s.readableObject(&request{r: req.r, bucket: req.bucket, key: key})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// writeWebsiteObject serves the content of obj with status.
+func writeWebsiteObject(req *request, obj *object, status int) {
+
+	h := req.w.Header()
+	for name, values := range obj.header {
+		h[name] = values
+	}
+
+	h.Set("ETag", obj.etag)
+	h.Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	h.Set("Content-Length", strconv.Itoa(len(obj.data)))
+	req.w.WriteHeader(status)
+
+	if req.r.Method != "HEAD" {
+		req.w.Write(obj.data)
+	}
+}
+
+// serveWebsite answers a request to the website endpoint of a bucket. It
+// is served anonymously, whatever credentials it carries: keys ending in a
+// slash get the index document, routing rules redirect, and errors are
+// answered with the error document when there is one.
+func (s *Server) serveWebsite(req *request) error {
+
+	b, err := s.bucket(req.bucket)
+	if err != nil {
+		return err
+	}
+
+	c := b.website
+	if c == nil {
+		return errNoSuchWebsiteConfiguration
+	}
+
+	if req.r.Method != "GET" && req.r.Method != "HEAD" {
+		return errMethodNotAllowed
+	}
+
+	if r := c.RedirectAllRequestsTo; r != nil {
+		return redirect(req, r.Protocol, r.HostName, req.key, http.StatusMovedPermanently)
+	}
+
+	for i := range c.RoutingRules {
+		if rule := &c.RoutingRules[i]; rule.applies(req.key, 0) {
+			return followRule(req, rule, req.key)
+		}
+	}
+
+	key := req.key
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += c.IndexDocument.Suffix
+	}
+
+	obj, err := s.websiteObject(req, key)
+	if err == errNoSuchKey && key == req.key {
+		// A key naming a directory is redirected to it when it has an
+		// index document.
+		if _, err := s.websiteObject(req, key+"/"+c.IndexDocument.Suffix); err == nil {
+			return redirect(req, "", "", key+"/", http.StatusFound)
+		}
+	}
+
+	if err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			return err
+		}
+
+		for i := range c.RoutingRules {
+			if rule := &c.RoutingRules[i]; rule.applies(req.key, e.Status) {
+				return followRule(req, rule, req.key)
+			}
+		}
+
+		if c.ErrorDocument != nil {
+			if doc, docErr := s.websiteObject(req, c.ErrorDocument.Key); docErr == nil {
+				writeWebsiteObject(req, doc, e.Status)
+				return nil
+			}
+		}
+
+		return err
+	}
+
+	if location := obj.header.Get("X-Amz-Website-Redirect-Location"); location != "" {
+		req.w.Header().Set("Location", location)
+		req.w.WriteHeader(http.StatusMovedPermanently)
+		return nil
+	}
+
+	writeWebsiteObject(req, obj, http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) putBucketWebsite(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	var c websiteConfiguration
+	if err := xml.Unmarshal(req.body, &c); err != nil {
+		return errMalformedXML
+	}
+
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	b.website = &c
+	req.w.WriteHeader(http.StatusOK)
+
+	return nil
+}
+
+func (s *Server) getBucketWebsite(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	if b.website == nil {
+		return errNoSuchWebsiteConfiguration
+	}
+
+	writeXML(req.w, http.StatusOK, b.website)
+
+	return nil
+}
+
+func (s *Server) deleteBucketWebsite(req *request) error {
+
+	b, err := s.ownedBucket(req)
+	if err != nil {
+		return err
+	}
+
+	b.website = nil
+	req.w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
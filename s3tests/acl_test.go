@@ -0,0 +1,351 @@
+package s3test
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+// access is what a principal turned out to be allowed to do with a bucket
+// or an object.
+type access struct {
+	read    bool
+	write   bool
+	readACP bool
+}
+
+// bucketAccess tries listing, writing to and reading the ACL of a bucket.
+func bucketAccess(client *s3.S3, bucket string) access {
+
+	_, listErr := ListObjects(client, bucket)
+	writeErr := PutObjectToBucket(client, bucket, "probe", "probe")
+	_, aclErr := GetBucketACL(client, bucket)
+
+	return access{read: listErr == nil, write: writeErr == nil, readACP: aclErr == nil}
+}
+
+// objectAccess tries reading an object and its ACL.
+func objectAccess(client *s3.S3, bucket string, key string) access {
+
+	_, readErr := GetObject(client, bucket, key)
+	_, aclErr := GetObjectACL(client, bucket, key)
+
+	return access{read: readErr == nil, readACP: aclErr == nil}
+}
+
+func (suite *S3Suite) TestBucketCannedACLs() {
+
+	/*
+		Resource : bucket, method: create/get acl/list/put object
+		Scenario : create a bucket with each canned ACL.
+		Assertion: the ACL holds the grants the canned ACL stands for, and the
+		           owner, alt and anonymous users get exactly that access.
+	*/
+
+	assert := suite
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	owner := "FULL_CONTROL id=" + mainID
+	full := access{true, true, true}
+
+	for _, c := range []struct {
+		canned    string
+		grants    []string
+		alt, anon access
+	}{
+		{"private", []string{owner}, access{}, access{}},
+		{"public-read", []string{owner, "READ uri=" + AllUsersGroup}, access{read: true}, access{read: true}},
+		{"public-read-write", []string{owner, "READ uri=" + AllUsersGroup, "WRITE uri=" + AllUsersGroup}, access{read: true, write: true}, access{read: true, write: true}},
+		{"authenticated-read", []string{owner, "READ uri=" + AuthenticatedUsersGroup}, access{read: true}, access{}},
+	} {
+		bucket := GetBucketName()
+
+		err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": c.canned})
+		assert.Nil(err, c.canned)
+
+		acl, err := GetBucketACL(svc, bucket)
+		assert.Nil(err, c.canned)
+		assert.Equal(mainID, *acl.Owner.ID, c.canned)
+		assert.Equal(c.grants, GrantList(acl.Grants), c.canned)
+
+		assert.Equal(full, bucketAccess(svc, bucket), c.canned)
+		assert.Equal(c.alt, bucketAccess(altSvc, bucket), c.canned)
+		assert.Equal(c.anon, bucketAccess(anonSvc, bucket), c.canned)
+	}
+}
+
+func (suite *S3Suite) TestObjectCannedACLs() {
+
+	/*
+		Resource : object, method: put/get acl/get
+		Scenario : write an object with each canned ACL into a private bucket.
+		Assertion: the ACL holds the grants the canned ACL stands for, and the
+		           owner, alt and anonymous users get exactly that access.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	owner := "FULL_CONTROL id=" + mainID
+	full := access{read: true, readACP: true}
+
+	for _, c := range []struct {
+		canned    string
+		grants    []string
+		alt, anon access
+	}{
+		{"private", []string{owner}, access{}, access{}},
+		{"public-read", []string{owner, "READ uri=" + AllUsersGroup}, access{read: true}, access{read: true}},
+		{"public-read-write", []string{owner, "READ uri=" + AllUsersGroup, "WRITE uri=" + AllUsersGroup}, access{read: true}, access{read: true}},
+		{"authenticated-read", []string{owner, "READ uri=" + AuthenticatedUsersGroup}, access{read: true}, access{}},
+		{"bucket-owner-full-control", []string{owner}, access{}, access{}},
+	} {
+		key := c.canned
+
+		err := SetupObjectWithHeader(svc, bucket, key, "bar", map[string]string{"x-amz-acl": c.canned})
+		assert.Nil(err, c.canned)
+
+		acl, err := GetObjectACL(svc, bucket, key)
+		assert.Nil(err, c.canned)
+		assert.Equal(mainID, *acl.Owner.ID, c.canned)
+		assert.Equal(c.grants, GrantList(acl.Grants), c.canned)
+
+		assert.Equal(full, objectAccess(svc, bucket, key), c.canned)
+		assert.Equal(c.alt, objectAccess(altSvc, bucket, key), c.canned)
+		assert.Equal(c.anon, objectAccess(anonSvc, bucket, key), c.canned)
+	}
+}
+
+func (suite *S3Suite) TestObjectCannedACLBucketOwnerFullControl() {
+
+	/*
+		Resource : object, method: put/get acl/get
+		Scenario : alt user writes objects into the main user's bucket, one with
+		           bucket-owner-full-control and one private.
+		Assertion: bucket-owner-full-control gives the bucket owner full control
+		           of the object; private leaves it to the alt user alone.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read-write"})
+	assert.Nil(err)
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	err = SetupObjectWithHeader(altSvc, bucket, "shared", "bar", map[string]string{"x-amz-acl": "bucket-owner-full-control"})
+	assert.Nil(err)
+
+	err = SetupObjectWithHeader(altSvc, bucket, "private", "bar", map[string]string{"x-amz-acl": "private"})
+	assert.Nil(err)
+
+	acl, err := GetObjectACL(altSvc, bucket, "shared")
+	assert.Nil(err)
+	assert.Equal(altID, *acl.Owner.ID)
+	assert.Equal(GrantList([]*s3.Grant{GrantToID(altID, "FULL_CONTROL"), GrantToID(mainID, "FULL_CONTROL")}), GrantList(acl.Grants))
+
+	assert.Equal(access{read: true, readACP: true}, objectAccess(svc, bucket, "shared"))
+	assert.Equal(access{}, objectAccess(svc, bucket, "private"))
+	assert.Equal(access{}, objectAccess(anonSvc, bucket, "shared"))
+}
+
+func (suite *S3Suite) TestBucketACLGrants() {
+
+	/*
+		Resource : bucket, method: put/get acl
+		Scenario : grant READ to the alt user by canonical ID and WRITE to
+		           authenticated users, then replace the grants with the owner's.
+		Assertion: the ACL reads back as set, alt user may list and write,
+		           anonymous may do neither, and replacing revokes the grants.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	grants := []*s3.Grant{
+		GrantToID(mainID, "FULL_CONTROL"),
+		GrantToID(altID, "READ"),
+		GrantToGroup(AuthenticatedUsersGroup, "WRITE"),
+	}
+
+	_, err = PutBucketACLGrants(svc, bucket, grants...)
+	assert.Nil(err)
+
+	acl, err := GetBucketACL(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(mainID, *acl.Owner.ID)
+	assert.Equal(GrantList(grants), GrantList(acl.Grants))
+
+	assert.Equal(access{read: true, write: true}, bucketAccess(altSvc, bucket))
+	assert.Equal(access{}, bucketAccess(anonSvc, bucket))
+
+	_, err = PutBucketACLGrants(svc, bucket, GrantToID(mainID, "FULL_CONTROL"))
+	assert.Nil(err)
+
+	assert.Equal(access{}, bucketAccess(altSvc, bucket))
+}
+
+func (suite *S3Suite) TestBucketACLGrantByEmail() {
+
+	/*
+		Resource : bucket, method: put/get acl
+		Scenario : grant WRITE to the alt user by email address.
+		Assertion: the grant reads back by the alt user's canonical ID, and alt
+		           may write but not list.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	_, err = PutBucketACLGrants(svc, bucket,
+		GrantToID(mainID, "FULL_CONTROL"),
+		GrantToEmail(GetConfig().Alt.Email, "WRITE"))
+	assert.Nil(err)
+
+	acl, err := GetBucketACL(svc, bucket)
+	assert.Nil(err)
+	assert.Equal([]string{"FULL_CONTROL id=" + mainID, "WRITE id=" + altID}, GrantList(acl.Grants))
+
+	assert.Equal(access{write: true}, bucketAccess(altSvc, bucket))
+}
+
+func (suite *S3Suite) TestBucketACLGrantAllUsers() {
+
+	/*
+		Resource : bucket, method: put/get acl
+		Scenario : grant READ and READ_ACP to all users.
+		Assertion: anonymous may list and read the ACL but not write.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	_, err = PutBucketACLGrants(svc, bucket,
+		GrantToID(mainID, "FULL_CONTROL"),
+		GrantToGroup(AllUsersGroup, "READ"),
+		GrantToGroup(AllUsersGroup, "READ_ACP"))
+	assert.Nil(err)
+
+	assert.Equal(access{read: true, readACP: true}, bucketAccess(anonSvc, bucket))
+	assert.Equal(access{read: true, readACP: true}, bucketAccess(altSvc, bucket))
+}
+
+func (suite *S3Suite) TestObjectACLGrants() {
+
+	/*
+		Resource : object, method: put/get acl
+		Scenario : grant READ_ACP to the alt user and READ to all users on an object.
+		Assertion: the ACL reads back as set; alt may read the object and its ACL
+		           but not change it; anonymous may only read the object.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	grants := []*s3.Grant{
+		GrantToID(mainID, "FULL_CONTROL"),
+		GrantToID(altID, "READ_ACP"),
+		GrantToGroup(AllUsersGroup, "READ"),
+	}
+
+	_, err = PutObjectACLGrants(svc, bucket, "foo", grants...)
+	assert.Nil(err)
+
+	acl, err := GetObjectACL(svc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal(mainID, *acl.Owner.ID)
+	assert.Equal(GrantList(grants), GrantList(acl.Grants))
+
+	assert.Equal(access{read: true, readACP: true}, objectAccess(altSvc, bucket, "foo"))
+	assert.Equal(access{read: true}, objectAccess(anonSvc, bucket, "foo"))
+
+	_, err = PutObjectACLGrants(altSvc, bucket, "foo", GrantToID(altID, "FULL_CONTROL"))
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestACLGrantInvalidID() {
+
+	/*
+		Resource : bucket, method: put acl
+		Scenario : grant to a canonical ID no user has.
+		Assertion: fails InvalidArgument.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = PutBucketACLGrants(svc, bucket, GrantToID("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "READ"))
+	suite.expectError(err, "InvalidArgument", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestACLGrantUnresolvableEmail() {
+
+	/*
+		Resource : bucket, method: put acl
+		Scenario : grant to an email address no user has.
+		Assertion: fails UnresolvableGrantByEmailAddress.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = PutBucketACLGrants(svc, bucket, GrantToEmail("nobody@example.invalid", "READ"))
+	suite.expectError(err, "UnresolvableGrantByEmailAddress", http.StatusBadRequest)
+}
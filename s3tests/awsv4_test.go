@@ -4,7 +4,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/spf13/viper"
 
 	"bytes"
 	"io/ioutil"
@@ -21,7 +20,7 @@ import (
 func (suite *S3Suite) TestPresignRequest() {
 
 	assert := suite
-	region := viper.GetString("s3main.region")
+	region := GetConfig().Main.Region
 	req, body := SetupRequest("S3", region, "{}")
 
 	signer := SetupSigner(Creds)
@@ -37,7 +36,7 @@ func (suite *S3Suite) TestPresignRequest() {
 func (suite *S3Suite) TestSignRequest() {
 
 	assert := suite
-	region := viper.GetString("s3main.region")
+	region := GetConfig().Main.Region
 	req, body := SetupRequest("S3", region, "{}")
 	expectedauth := "AWS4-HMAC-SHA256 Credential=0555b35654ad1656d804/19700101/us-east-1/s3/aws4_request, SignedHeaders=content-length;content-type;host;x-amz-content-sha256;x-amz-date;x-amz-meta-other-header;x-amz-meta-other-header_with_underscore;x-amz-target, Signature=605bf71358a549b8aa9461aeb0944908a62395efdf4bb5fc8bdb47b48147a426"
 	signer := SetupSigner(Creds)
@@ -51,7 +50,7 @@ func (suite *S3Suite) TestSignRequest() {
 func (suite *S3Suite) TestSignBody() {
 
 	assert := suite
-	region := viper.GetString("s3main.region")
+	region := GetConfig().Main.Region
 	req, body := SetupRequest("S3", region, "yello")
 
 	signer := SetupSigner(Creds)
@@ -64,7 +63,7 @@ func (suite *S3Suite) TestSignBody() {
 func (suite *S3Suite) TestPresignEmptyBody() {
 
 	assert := suite
-	region := viper.GetString("s3main.region")
+	region := GetConfig().Main.Region
 	req, body := SetupRequest("S3", region, "yello")
 
 	signer := SetupSigner(Creds)
@@ -77,7 +76,7 @@ func (suite *S3Suite) TestPresignEmptyBody() {
 func (suite *S3Suite) TestSignUnsignedpayload() {
 
 	assert := suite
-	region := viper.GetString("s3main.region")
+	region := GetConfig().Main.Region
 	req, body := SetupRequest("S3", region, "yello")
 
 	signer := SetupSigner(Creds)
@@ -142,7 +141,7 @@ func (suite *S3Suite) TestSignWithRequestBodyOverwrite() {
 func (suite *S3Suite) TestSignWithBodyReplaceRequestBody() {
 
 	assert := suite
-	region := viper.GetString("s3main.region")
+	region := GetConfig().Main.Region
 
 	req, seekerBody := SetupRequest("S3", region, "{}")
 	req.Body = ioutil.NopCloser(bytes.NewReader([]byte{}))
@@ -159,7 +158,7 @@ func (suite *S3Suite) TestSignWithBodyReplaceRequestBody() {
 func (suite *S3Suite) TestSignWithBodyNoReplaceRequestBody() {
 
 	assert := suite
-	region := viper.GetString("s3main.region")
+	region := GetConfig().Main.Region
 
 	req, seekerBody := SetupRequest("S3", region, "{}")
 	req.Body = ioutil.NopCloser(bytes.NewReader([]byte{}))
@@ -190,7 +189,7 @@ func (suite *S3Suite) TestPresignHandler() {
 
 	assert.Nil(err)
 
-	expectedHost := viper.GetString("s3main.endpoint")
+	expectedHost := GetConfig().Main.Endpoint
 	expectedDate := "19700101T000000Z"
 	expectedHeaders := "content-disposition;host;x-amz-acl"
 	expectedSig := "74dc17e5958f1304eaf6397f1d3078d55b533a076475024622935aa613666ec2"
@@ -1,11 +1,13 @@
 package s3test
 
 import (
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 
 	"crypto/md5"
 	"encoding/base64"
+	"net/http"
 	"strings"
 
 	. "../Utilities"
@@ -171,28 +173,26 @@ func (suite *S3Suite) TestObjectAclCreateContentlengthNone() {
 func (suite *S3Suite) TestBucketPutCanned_acl() {
 
 	/*
-		Resource : bucket, method: put
-		Scenario :set w/invalid permission.
-		Assertion: fails
+		Resource : bucket, method: put acl
+		Scenario : set an invalid canned ACL, then a valid one.
+		Assertion: the invalid one fails InvalidArgument, the valid one is applied.
 	*/
 
 	assert := suite
-	cannedAcl := map[string]string{"x-amz-acl": "public-ready"}
-	acl := map[string]string{"ACL": "public-read"}
-
 	bucket := GetBucketName()
+
 	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
 
-	err = CreateBucketWithHeader(svc, bucket, cannedAcl)
-	err = CreateBucketWithHeader(svc, bucket, acl)
+	_, err = SetACL(svc, bucket, "public-ready")
+	suite.expectError(err, "InvalidArgument", http.StatusBadRequest)
+
+	_, err = SetACL(svc, bucket, "public-read")
 	assert.Nil(err)
-	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
 
-			assert.Equal(awsErr.Code(), "AccessDenied")
-			assert.Equal(awsErr.Message(), "")
-		}
-	}
+	acl, err := GetBucketACL(svc, bucket)
+	assert.Nil(err)
+	assert.Contains(GrantList(acl.Grants), "READ uri="+AllUsersGroup)
 }
 
 func (suite *S3Suite) TestBucketCreateBadExpectMismatch() {
@@ -506,3 +506,42 @@ func (suite *S3Suite) TestLifecycleInvalidStatus() {
 		}
 	}
 }
+
+func (suite *S3Suite) TestLifecycleBadDigest() {
+
+	/*
+		Resource : bucket, method: put
+		Scenario : set lifecycle config with the MD5 of other content.
+		Assertion: fails BadDigest, the MD5 is not of the configuration
+	*/
+
+	bucket := GetBucketName()
+	err := CreateBucket(svc, bucket)
+	suite.Nil(err)
+
+	sum := md5.Sum([]byte("Enabled"))
+
+	_, err = SetLifecycle(svc, bucket, "rule1", "Enabled", base64.StdEncoding.EncodeToString(sum[:]))
+	suite.expectError(err, "BadDigest", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestLifecycleRuleInvalidStatus() {
+
+	/*
+		Resource : bucket, method: put
+		Scenario : invalid status in an otherwise valid lifecycle rule.
+		Assertion: fails MalformedXML, Status must be Enabled or Disabled
+	*/
+
+	bucket := GetBucketName()
+	err := CreateBucket(svc, bucket)
+	suite.Nil(err)
+
+	for _, status := range []string{"enabled", "disabled", "invalid"} {
+		rule := ExpirationRule("rule1", PrefixFilter("test/"), 1)
+		rule.Status = aws.String(status)
+
+		err = PutLifecycleRules(svc, bucket, rule)
+		suite.expectError(err, "MalformedXML", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,212 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+func (suite *S3Suite) TestChunkedUpload() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload objects with STREAMING-AWS4-HMAC-SHA256-PAYLOAD
+		           bodies: over several chunks, filling a whole number of
+		           chunks, and in a single small one.
+		Assertion: every upload succeeds, and the object holds the decoded
+		           content, with its MD5 as ETag and no aws-chunked
+		           Content-Encoding.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, size := range []int{150 * 1024, 128 * 1024, 10} {
+		key := "chunked" + strconv.Itoa(size)
+		content := String(size)
+
+		resp, body, err := PutObjectChunked(bucket, key, content, ChunkedOptions{})
+		assert.Nil(err)
+		assert.Nil(ResponseError(resp, body))
+
+		sum := md5.Sum([]byte(content))
+		assert.Equal(`"`+hex.EncodeToString(sum[:])+`"`, resp.Header.Get("ETag"))
+
+		got, err := GetObject(svc, bucket, key)
+		assert.Nil(err)
+		assert.Equal(content, got)
+
+		obj, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if assert.Nil(err) {
+			assert.Equal(int64(size), aws.Int64Value(obj.ContentLength))
+			assert.Equal("", aws.StringValue(obj.ContentEncoding))
+		}
+	}
+}
+
+func (suite *S3Suite) TestChunkedUploadEmpty() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload an empty object with a chunked body made of the
+		           zero-length final chunk alone.
+		Assertion: the upload succeeds and the object is empty.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	resp, body, err := PutObjectChunked(bucket, "empty", "", ChunkedOptions{})
+	assert.Nil(err)
+	assert.Nil(ResponseError(resp, body))
+
+	content, err := GetObject(svc, bucket, "empty")
+	assert.Nil(err)
+	assert.Equal("", content)
+}
+
+func (suite *S3Suite) TestChunkedUploadMissingFinalChunk() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload an object with a chunked body that ends without
+		           the zero-length final chunk.
+		Assertion: fails IncompleteBody and nothing is stored.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	resp, body, err := PutObjectChunked(bucket, "foo", String(100*1024), ChunkedOptions{OmitFinalChunk: true})
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "IncompleteBody", http.StatusBadRequest)
+
+	_, err = GetObject(svc, bucket, "foo")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestChunkedUploadWrongDecodedLength() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload objects with chunked bodies whose
+		           x-amz-decoded-content-length is a byte more and a byte less
+		           than the content.
+		Assertion: both fail IncompleteBody and nothing is stored.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	content := String(100 * 1024)
+
+	for _, length := range []int{len(content) + 1, len(content) - 1} {
+		resp, body, err := PutObjectChunked(bucket, "foo", content, ChunkedOptions{DecodedLength: strconv.Itoa(length)})
+		assert.Nil(err)
+		suite.expectError(ResponseError(resp, body), "IncompleteBody", http.StatusBadRequest)
+	}
+
+	_, err = GetObject(svc, bucket, "foo")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestChunkedUploadCorruptSignature() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload objects with chunked bodies where the signature of
+		           the second chunk, and of the final zero-length chunk, is
+		           wrong.
+		Assertion: both fail SignatureDoesNotMatch and nothing is stored.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, chunk := range []int{2, 3} {
+		resp, body, err := PutObjectChunked(bucket, "foo", String(100*1024), ChunkedOptions{CorruptChunk: chunk})
+		assert.Nil(err)
+		suite.expectError(ResponseError(resp, body), "SignatureDoesNotMatch", http.StatusForbidden)
+	}
+
+	_, err = GetObject(svc, bucket, "foo")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestChunkedUploadTrailingChecksum() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload objects with STREAMING-UNSIGNED-PAYLOAD-TRAILER
+		           bodies ending with a CRC32, CRC32C, SHA1 and SHA256
+		           checksum of the content.
+		Assertion: every upload succeeds and the object holds the content.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, algorithm := range []string{"CRC32", "CRC32C", "SHA1", "SHA256"} {
+		content := String(100 * 1024)
+
+		resp, body, err := PutObjectChunked(bucket, algorithm, content, ChunkedOptions{Checksum: algorithm})
+		assert.Nil(err)
+		assert.Nil(ResponseError(resp, body), algorithm)
+
+		got, err := GetObject(svc, bucket, algorithm)
+		assert.Nil(err)
+		assert.Equal(content, got)
+	}
+}
+
+func (suite *S3Suite) TestChunkedUploadBadTrailingChecksum() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload objects with STREAMING-UNSIGNED-PAYLOAD-TRAILER
+		           bodies whose trailing checksum is of other content.
+		Assertion: every upload fails BadDigest and nothing is stored.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, algorithm := range []string{"CRC32", "CRC32C", "SHA1", "SHA256"} {
+		wrong, err := ChecksumOf(algorithm, "other content")
+		assert.Nil(err)
+
+		resp, body, err := PutObjectChunked(bucket, algorithm, String(100*1024), ChunkedOptions{Checksum: algorithm, ChecksumValue: wrong})
+		assert.Nil(err)
+		suite.expectError(ResponseError(resp, body), "BadDigest", http.StatusBadRequest)
+
+		_, err = GetObject(svc, bucket, algorithm)
+		suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+	}
+}
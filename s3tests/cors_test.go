@@ -0,0 +1,289 @@
+package s3test
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+func (suite *S3Suite) TestCorsPutGetDelete() {
+
+	/*
+		Resource : bucket, method: cors
+		Scenario : set a CORS configuration with two rules, read it back,
+		           then delete it twice.
+		Assertion: the rules read back are the ones set; once deleted, reading
+		           the configuration fails NoSuchCORSConfiguration, and deleting
+		           it again succeeds.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketCors(svc, bucket)
+	suite.expectError(err, "NoSuchCORSConfiguration", http.StatusNotFound)
+
+	rules := []*s3.CORSRule{
+		{
+			ID:             aws.String("uploads"),
+			AllowedOrigins: aws.StringSlice([]string{"https://www.example.com"}),
+			AllowedMethods: aws.StringSlice([]string{"PUT", "POST"}),
+			AllowedHeaders: aws.StringSlice([]string{"x-amz-meta-*", "content-type"}),
+			ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
+			MaxAgeSeconds:  aws.Int64(3000),
+		},
+		CORSRule([]string{"*"}, []string{"GET"}),
+	}
+
+	err = PutBucketCors(svc, bucket, rules...)
+	assert.Nil(err)
+
+	got, err := GetBucketCors(svc, bucket)
+	assert.Nil(err)
+	if assert.Equal(len(rules), len(got)) {
+		for i := range rules {
+			assert.Equal(rules[i].String(), got[i].String())
+		}
+	}
+
+	err = DeleteBucketCors(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketCors(svc, bucket)
+	suite.expectError(err, "NoSuchCORSConfiguration", http.StatusNotFound)
+
+	err = DeleteBucketCors(svc, bucket)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestCorsInvalidRules() {
+
+	/*
+		Resource : bucket, method: cors
+		Scenario : set rules allowing a method CORS does not cover, and an
+		           origin with two wildcards.
+		Assertion: both fail InvalidRequest and set nothing.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutBucketCors(svc, bucket, CORSRule([]string{"*"}, []string{"PATCH"}))
+	suite.expectError(err, "InvalidRequest", http.StatusBadRequest)
+
+	err = PutBucketCors(svc, bucket, CORSRule([]string{"https://*.example.*"}, []string{"GET"}))
+	suite.expectError(err, "InvalidRequest", http.StatusBadRequest)
+
+	_, err = GetBucketCors(svc, bucket)
+	suite.expectError(err, "NoSuchCORSConfiguration", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestCorsPreflight() {
+
+	/*
+		Resource : object, method: options
+		Scenario : send a preflight for a PUT with headers the rule allows,
+		           from the origin it allows.
+		Assertion: the preflight succeeds and the Access-Control-Allow-*,
+		           Expose-Headers, Max-Age and Vary headers describe the rule,
+		           echoing the origin and the requested headers.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	origin := "https://www.example.com"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutBucketCors(svc, bucket, &s3.CORSRule{
+		AllowedOrigins: aws.StringSlice([]string{origin}),
+		AllowedMethods: aws.StringSlice([]string{"GET", "PUT"}),
+		AllowedHeaders: aws.StringSlice([]string{"x-amz-meta-*", "content-type"}),
+		ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
+		MaxAgeSeconds:  aws.Int64(3000),
+	})
+	assert.Nil(err)
+
+	resp, err := Preflight(bucket, "upload", origin, "PUT", "Content-Type", "x-amz-meta-foo")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(origin, resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal("GET, PUT", resp.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal("content-type, x-amz-meta-foo", resp.Header.Get("Access-Control-Allow-Headers"))
+	assert.Equal("ETag", resp.Header.Get("Access-Control-Expose-Headers"))
+	assert.Equal("3000", resp.Header.Get("Access-Control-Max-Age"))
+	assert.Contains(resp.Header.Get("Vary"), "Origin")
+}
+
+func (suite *S3Suite) TestCorsPreflightWildcardOrigin() {
+
+	/*
+		Resource : object, method: options
+		Scenario : send preflights against a rule allowing any origin and a
+		           rule allowing any subdomain of an origin.
+		Assertion: an origin allowed by * is answered with *, an origin
+		           allowed by a subdomain wildcard is echoed, and the origin
+		           the subdomain wildcard does not cover is refused.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutBucketCors(svc, bucket,
+		CORSRule([]string{"*"}, []string{"GET"}),
+		CORSRule([]string{"https://*.example.com"}, []string{"PUT"}))
+	assert.Nil(err)
+
+	resp, err := Preflight(bucket, "key", "https://anywhere.test", "GET")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("*", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal("GET", resp.Header.Get("Access-Control-Allow-Methods"))
+
+	resp, err = Preflight(bucket, "key", "https://app.example.com", "PUT")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal("PUT", resp.Header.Get("Access-Control-Allow-Methods"))
+
+	resp, err = Preflight(bucket, "key", "https://example.org", "PUT")
+	assert.Nil(err)
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+}
+
+func (suite *S3Suite) TestCorsPreflightNotAllowed() {
+
+	/*
+		Resource : object, method: options
+		Scenario : send preflights from an origin, with a method and with a
+		           header no rule allows, and one to a bucket without a CORS
+		           configuration.
+		Assertion: each is refused with 403 and no Access-Control-Allow-Origin.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	other := GetBucketName()
+	origin := "https://www.example.com"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = CreateBucket(svc, other)
+	assert.Nil(err)
+
+	err = PutBucketCors(svc, bucket, &s3.CORSRule{
+		AllowedOrigins: aws.StringSlice([]string{origin}),
+		AllowedMethods: aws.StringSlice([]string{"GET"}),
+		AllowedHeaders: aws.StringSlice([]string{"x-amz-meta-*"}),
+	})
+	assert.Nil(err)
+
+	for _, c := range []struct {
+		bucket  string
+		origin  string
+		method  string
+		headers []string
+	}{
+		{bucket, "https://www.example.org", "GET", nil},
+		{bucket, origin, "DELETE", nil},
+		{bucket, origin, "GET", []string{"x-amz-meta-foo", "authorization"}},
+		{other, origin, "GET", nil},
+	} {
+		resp, err := Preflight(c.bucket, "key", c.origin, c.method, c.headers...)
+		assert.Nil(err)
+		assert.Equal(http.StatusForbidden, resp.StatusCode)
+		assert.Equal("", resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func (suite *S3Suite) TestCorsPreflightMissingHeaders() {
+
+	/*
+		Resource : object, method: options
+		Scenario : send preflights without an Origin, and without an
+		           Access-Control-Request-Method.
+		Assertion: both fail with 400.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutBucketCors(svc, bucket, CORSRule([]string{"*"}, []string{"GET"}))
+	assert.Nil(err)
+
+	resp, err := Preflight(bucket, "key", "", "GET")
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = Preflight(bucket, "key", "https://www.example.com", "")
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func (suite *S3Suite) TestCorsSimpleRequest() {
+
+	/*
+		Resource : object, method: get
+		Scenario : read a public object with an Origin the CORS configuration
+		           allows, with one it does not, and with none.
+		Assertion: every read succeeds; only the allowed origin gets the
+		           Access-Control-Allow-Origin and Expose-Headers headers, and
+		           each response varies on Origin.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	origin := "https://www.example.com"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "public", "echo")
+	assert.Nil(err)
+
+	_, err = SetObjectACL(svc, bucket, "public", "public-read")
+	assert.Nil(err)
+
+	err = PutBucketCors(svc, bucket, &s3.CORSRule{
+		AllowedOrigins: aws.StringSlice([]string{origin}),
+		AllowedMethods: aws.StringSlice([]string{"GET", "HEAD"}),
+		ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
+	})
+	assert.Nil(err)
+
+	resp, err := SendCORSRequest("GET", bucket, "public", map[string]string{"Origin": origin})
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(origin, resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal("GET, HEAD", resp.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal("ETag", resp.Header.Get("Access-Control-Expose-Headers"))
+	assert.Contains(resp.Header.Get("Vary"), "Origin")
+
+	resp, err = SendCORSRequest("GET", bucket, "public", map[string]string{"Origin": "https://www.example.org"})
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(resp.Header.Get("Vary"), "Origin")
+
+	resp, err = SendCORSRequest("GET", bucket, "public", nil)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("", resp.Header.Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,159 @@
+package s3test
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+var hostSvc *s3.S3
+
+func (suite *S3Suite) TestHostStyleBucketCreateReadDelete() {
+
+	/*
+		Resource : bucket, method: create/list/delete
+		Scenario : DNS compatible bucket addressed virtual-hosted style.
+		Assertion: the bucket is resolved from the Host header for every operation.
+	*/
+
+	assert := suite
+	bucket := GetDNSBucketName()
+
+	assert.Equal(true, IsHostStyleRequest(hostSvc, bucket))
+
+	err := CreateBucket(hostSvc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(hostSvc, bucket, "foo/bar", "echo")
+	assert.Nil(err)
+
+	got, err := GetObject(hostSvc, bucket, "foo/bar")
+	assert.Nil(err)
+	assert.Equal("echo", got)
+
+	_, keys, err := GetKeys(hostSvc, bucket)
+	assert.Nil(err)
+	assert.Equal([]string{"foo/bar"}, keys)
+
+	// The same bucket is visible path style.
+	got, err = GetObject(svc, bucket, "foo/bar")
+	assert.Nil(err)
+	assert.Equal("echo", got)
+
+	err = DeleteObjects(hostSvc, bucket)
+	assert.Nil(err)
+
+	err = DeleteBucket(hostSvc, bucket)
+	assert.Nil(err)
+
+	bkts, err := ListBuckets(hostSvc)
+	assert.Nil(err)
+	assert.Equal(false, Contains(bkts, bucket))
+}
+
+func (suite *S3Suite) TestHostStyleBucketNotDNSCompatible() {
+
+	/*
+		Resource : bucket, method: create/get
+		Scenario : bucket name with upper case letters and an underscore.
+		Assertion: the client falls back to path style and the requests succeed.
+	*/
+
+	assert := suite
+	bucket := GetPrefix() + "-Not_DNS-" + String(6)
+
+	assert.Equal(false, IsHostStyleRequest(hostSvc, bucket))
+
+	err := CreateBucket(hostSvc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(hostSvc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	got, err := GetObject(hostSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", got)
+}
+
+func (suite *S3Suite) TestHostStyleBucketDotted() {
+
+	/*
+		Resource : bucket, method: create/get
+		Scenario : bucket name containing dots addressed virtual-hosted style.
+		Assertion: the whole dotted name is resolved as the bucket.
+	*/
+
+	assert := suite
+	bucket := GetDNSBucketName() + ".with.dots"
+
+	err := CreateBucket(hostSvc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(hostSvc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	got, err := GetObject(hostSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", got)
+
+	bkts, err := ListBuckets(svc)
+	assert.Nil(err)
+	assert.Equal(true, Contains(bkts, bucket))
+}
+
+func (suite *S3Suite) TestHostStyleBucketNotExist() {
+
+	/*
+		Resource : bucket, method: list/get
+		Scenario : virtual-hosted-style requests for a bucket that does not exist.
+		Assertion: fails NoSuchBucket.
+	*/
+
+	assert := suite
+	bucket := GetDNSBucketName()
+
+	assert.Equal(true, IsHostStyleRequest(hostSvc, bucket))
+
+	_, err := ListObjects(hostSvc, bucket)
+	suite.expectError(err, "NoSuchBucket", http.StatusNotFound)
+
+	_, err = GetObject(hostSvc, bucket, "foo")
+	suite.expectError(err, "NoSuchBucket", http.StatusNotFound)
+
+	err = PutObjectToBucket(hostSvc, bucket, "foo", "bar")
+	suite.expectError(err, "NoSuchBucket", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestHostStyleListBuckets() {
+
+	/*
+		Resource : service, method: list buckets
+		Scenario : list buckets through a client configured for host style.
+		Assertion: requests without a bucket go to the endpoint itself.
+	*/
+
+	assert := suite
+	bucket := GetDNSBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	bkts, err := ListBuckets(hostSvc)
+	assert.Nil(err)
+	assert.Equal(true, Contains(bkts, bucket))
+}
+
+func (suite *S3Suite) TestHostStyleSuiteSetting() {
+
+	/*
+		Resource : bucket, method: head
+		Scenario : fixture bucket names addressed by the suite-wide client.
+		Assertion: the addressing style follows the host_style setting.
+	*/
+
+	assert := suite
+
+	assert.Equal(UseHostStyle(), IsHostStyleRequest(svc, GetBucketName()))
+}
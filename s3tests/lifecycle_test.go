@@ -0,0 +1,537 @@
+package s3test
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+// lifecycleDate returns midnight UTC days from now, a valid lifecycle date.
+func lifecycleDate(days int) time.Time {
+
+	return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, days)
+}
+
+// sortedRules returns rules sorted by ID, for comparing configurations
+// read back with the ones written.
+func sortedRules(rules []*s3.LifecycleRule) []*s3.LifecycleRule {
+
+	sorted := append([]*s3.LifecycleRule{}, rules...)
+	sort.Slice(sorted, func(i, j int) bool { return aws.StringValue(sorted[i].ID) < aws.StringValue(sorted[j].ID) })
+	return sorted
+}
+
+func (suite *S3Suite) TestLifecycleRulesRoundTrip() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : set rules using every kind of filter and action: prefix, tag
+		           and And filters, expiration by days and by date, noncurrent
+		           version expiration, aborting incomplete multipart uploads
+		           and transitions.
+		Assertion: the rules read back are the ones set.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	rules := []*s3.LifecycleRule{
+		ExpirationRule("days", PrefixFilter("logs/"), 7),
+		ExpirationDateRule("date", TagFilter("retention", "short"), lifecycleDate(30)),
+		{
+			ID:                          aws.String("noncurrent"),
+			Filter:                      AndFilter("docs/", map[string]string{"class": "archive", "team": "storage"}),
+			Status:                      aws.String(s3.ExpirationStatusEnabled),
+			NoncurrentVersionExpiration: &s3.NoncurrentVersionExpiration{NoncurrentDays: aws.Int64(14)},
+		},
+		{
+			ID:                             aws.String("multipart"),
+			Filter:                         PrefixFilter("uploads/"),
+			Status:                         aws.String(s3.ExpirationStatusDisabled),
+			AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(3)},
+		},
+		{
+			ID:          aws.String("transition"),
+			Filter:      PrefixFilter("media/"),
+			Status:      aws.String(s3.ExpirationStatusEnabled),
+			Transitions: []*s3.Transition{{Days: aws.Int64(30), StorageClass: aws.String(s3.TransitionStorageClassGlacier)}},
+			Expiration:  &s3.LifecycleExpiration{Days: aws.Int64(365)},
+		},
+	}
+
+	err = PutLifecycleRules(svc, bucket, rules...)
+	assert.Nil(err)
+
+	got, err := GetLifecycleRules(svc, bucket)
+	assert.Nil(err)
+
+	want := sortedRules(rules)
+	got = sortedRules(got)
+
+	if assert.Equal(len(want), len(got)) {
+		for i := range want {
+			assert.Equal(want[i].String(), got[i].String(), aws.StringValue(want[i].ID))
+		}
+	}
+}
+
+func (suite *S3Suite) TestLifecycleReplace() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : set a configuration, then another with different rules.
+		Assertion: the second configuration replaces the first entirely.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutLifecycleRules(svc, bucket, ExpirationRule("first", PrefixFilter("a/"), 1), ExpirationRule("second", PrefixFilter("b/"), 2))
+	assert.Nil(err)
+
+	err = PutLifecycleRules(svc, bucket, ExpirationRule("third", PrefixFilter("c/"), 3))
+	assert.Nil(err)
+
+	rules, err := GetLifecycleRules(svc, bucket)
+	assert.Nil(err)
+	if assert.Equal(1, len(rules)) {
+		assert.Equal("third", aws.StringValue(rules[0].ID))
+		assert.Equal(int64(3), aws.Int64Value(rules[0].Expiration.Days))
+	}
+}
+
+func (suite *S3Suite) TestLifecycleDelete() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : delete the lifecycle configuration of a bucket, twice.
+		Assertion: reading it afterwards fails NoSuchLifecycleConfiguration, and
+		           deleting a configuration that is gone succeeds.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutLifecycleRules(svc, bucket, ExpirationRule("rule1", PrefixFilter("a/"), 1))
+	assert.Nil(err)
+
+	err = DeleteLifecycle(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetLifecycleRules(svc, bucket)
+	suite.expectError(err, "NoSuchLifecycleConfiguration", http.StatusNotFound)
+
+	err = DeleteLifecycle(svc, bucket)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestLifecycleContentMD5Required() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : set a configuration without Content-MD5, then through the
+		           helper that computes it.
+		Assertion: without the header the request fails InvalidRequest; with it
+		           the configuration is set.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	rule := ExpirationRule("rule1", PrefixFilter("a/"), 1)
+
+	req, _ := svc.PutBucketLifecycleConfigurationRequest(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{rule}},
+	})
+	req.Handlers.Build.RemoveByName("contentMd5Handler")
+
+	err = req.Send()
+	suite.expectError(err, "InvalidRequest", http.StatusBadRequest)
+
+	err = PutLifecycleRules(svc, bucket, rule)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestLifecycleDuplicateIDs() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : set two rules with the same ID.
+		Assertion: fails InvalidArgument.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutLifecycleRules(svc, bucket, ExpirationRule("rule1", PrefixFilter("a/"), 1), ExpirationRule("rule1", PrefixFilter("b/"), 2))
+	suite.expectError(err, "InvalidArgument", http.StatusBadRequest)
+
+	_, err = GetLifecycleRules(svc, bucket)
+	suite.expectError(err, "NoSuchLifecycleConfiguration", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestLifecycleOverlappingRules() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : set two expiration rules whose prefixes overlap.
+		Assertion: fails InvalidRequest. AWS resolves overlaps instead of
+		           refusing them.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutLifecycleRules(svc, bucket,
+		ExpirationRule("rule1", PrefixFilter("test1/"), 2),
+		ExpirationRule("rule2", PrefixFilter("test3/"), 3),
+		ExpirationRule("rule3", PrefixFilter("test1/abc"), 5))
+	suite.expectError(err, "InvalidRequest", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestLifecycleTooManyRules() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : set 1000 rules, then 1001.
+		Assertion: 1000 rules are accepted; 1001 fail MalformedXML.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	var rules []*s3.LifecycleRule
+	for i := 0; i <= MaxLifecycleRules; i++ {
+		id := fmt.Sprintf("rule-%04d", i)
+		rules = append(rules, ExpirationRule(id, PrefixFilter(id+"/"), 1))
+	}
+
+	err = PutLifecycleRules(svc, bucket, rules[:MaxLifecycleRules]...)
+	assert.Nil(err)
+
+	got, err := GetLifecycleRules(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(MaxLifecycleRules, len(got))
+
+	err = PutLifecycleRules(svc, bucket, rules...)
+	suite.expectError(err, "MalformedXML", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestLifecycleInvalidRules() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : set rules with days of 0 or less, a date that is not
+		           midnight, a transition to infrequent access too early, no
+		           action at all, and an abort of multipart uploads filtered by
+		           tag.
+		Assertion: each fails with the error S3 gives and sets nothing.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	rule := func(id string) *s3.LifecycleRule {
+		return &s3.LifecycleRule{ID: aws.String(id), Filter: PrefixFilter(id + "/"), Status: aws.String(s3.ExpirationStatusEnabled)}
+	}
+
+	daysZero := ExpirationRule("days-zero", PrefixFilter("a/"), 0)
+	daysNegative := ExpirationRule("days-negative", PrefixFilter("a/"), -1)
+	notMidnight := ExpirationDateRule("not-midnight", PrefixFilter("a/"), lifecycleDate(30).Add(time.Hour))
+
+	noncurrentZero := rule("noncurrent-zero")
+	noncurrentZero.NoncurrentVersionExpiration = &s3.NoncurrentVersionExpiration{NoncurrentDays: aws.Int64(0)}
+
+	abortZero := rule("abort-zero")
+	abortZero.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(0)}
+
+	abortTagged := rule("abort-tagged")
+	abortTagged.Filter = TagFilter("team", "storage")
+	abortTagged.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(1)}
+
+	earlyIA := rule("early-ia")
+	earlyIA.Transitions = []*s3.Transition{{Days: aws.Int64(10), StorageClass: aws.String(s3.TransitionStorageClassStandardIa)}}
+
+	noAction := rule("no-action")
+
+	for _, c := range []struct {
+		rule *s3.LifecycleRule
+		code string
+	}{
+		{daysZero, "InvalidArgument"},
+		{daysNegative, "InvalidArgument"},
+		{notMidnight, "InvalidArgument"},
+		{noncurrentZero, "InvalidArgument"},
+		{abortZero, "InvalidArgument"},
+		{abortTagged, "InvalidRequest"},
+		{earlyIA, "InvalidArgument"},
+		{noAction, "InvalidRequest"},
+	} {
+		err = PutLifecycleRules(svc, bucket, c.rule)
+		suite.expectError(err, c.code, http.StatusBadRequest)
+	}
+
+	_, err = GetLifecycleRules(svc, bucket)
+	suite.expectError(err, "NoSuchLifecycleConfiguration", http.StatusNotFound)
+}
+
+func (suite *S3Suite) skipUnlessLifecycleDebug() {
+
+	if LifecycleDay() == 0 {
+		skipTest(suite.T(), "requires lc_debug_interval")
+	}
+}
+
+// listedKeys returns a WaitForLifecycle condition holding once the keys
+// listed in bucket are want.
+func listedKeys(bucket string, want ...string) func() (bool, error) {
+
+	return func() (bool, error) {
+		_, keys, err := GetKeys(svc, bucket)
+		return strings.Join(keys, ",") == strings.Join(want, ","), err
+	}
+}
+
+func (suite *S3Suite) TestLifecycleExpirationPrefix() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : expire the objects under a prefix after a day, with a
+		           disabled rule for another prefix.
+		Assertion: the objects under the prefix disappear; the others are
+		           left alone.
+	*/
+
+	suite.skipUnlessLifecycleDebug()
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = CreateObjects(svc, bucket, map[string]string{"expire1/foo": "echo", "expire1/bar": "echo", "keep2/foo": "echo", "keep2/bar": "echo"})
+	assert.Nil(err)
+
+	disabled := ExpirationRule("rule2", PrefixFilter("keep2/"), 1)
+	disabled.Status = aws.String(s3.ExpirationStatusDisabled)
+
+	err = PutLifecycleRules(svc, bucket, ExpirationRule("rule1", PrefixFilter("expire1/"), 1), disabled)
+	assert.Nil(err)
+
+	err = WaitForLifecycle(1, listedKeys(bucket, "keep2/bar", "keep2/foo"))
+	assert.Nil(err)
+
+	_, keys, err := GetKeys(svc, bucket)
+	assert.Nil(err)
+	assert.Equal([]string{"keep2/bar", "keep2/foo"}, keys)
+}
+
+func (suite *S3Suite) TestLifecycleExpirationTags() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : expire the objects carrying a tag after a day.
+		Assertion: the tagged objects disappear; untagged objects and objects
+		           with another value for the tag are left alone.
+	*/
+
+	suite.skipUnlessLifecycleDebug()
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectWithTagging(svc, bucket, "tagged", "echo", map[string]string{"expire": "yes", "team": "storage"})
+	assert.Nil(err)
+
+	err = PutObjectWithTagging(svc, bucket, "other-value", "echo", map[string]string{"expire": "no"})
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "untagged", "echo")
+	assert.Nil(err)
+
+	err = PutLifecycleRules(svc, bucket, ExpirationRule("rule1", TagFilter("expire", "yes"), 1))
+	assert.Nil(err)
+
+	err = WaitForLifecycle(1, listedKeys(bucket, "other-value", "untagged"))
+	assert.Nil(err)
+
+	_, keys, err := GetKeys(svc, bucket)
+	assert.Nil(err)
+	assert.Equal([]string{"other-value", "untagged"}, keys)
+}
+
+func (suite *S3Suite) TestLifecycleExpirationVersioned() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : expire an object after a day in a bucket with versioning
+		           enabled.
+		Assertion: the object can no longer be read, and a delete marker is
+		           placed in front of the version, which is kept.
+	*/
+
+	suite.skipUnlessLifecycleDebug()
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	version, err := PutObjectVersion(svc, bucket, "expire1/foo", "echo")
+	assert.Nil(err)
+
+	err = PutLifecycleRules(svc, bucket, ExpirationRule("rule1", PrefixFilter("expire1/"), 1))
+	assert.Nil(err)
+
+	err = WaitForLifecycle(1, listedKeys(bucket))
+	assert.Nil(err)
+
+	_, err = GetObject(svc, bucket, "expire1/foo")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+
+	resp, err := ListObjectVersions(svc, bucket, "")
+	assert.Nil(err)
+	if assert.Equal(1, len(resp.Versions)) && assert.Equal(1, len(resp.DeleteMarkers)) {
+		assert.Equal(version, aws.StringValue(resp.Versions[0].VersionId))
+		assert.True(aws.BoolValue(resp.DeleteMarkers[0].IsLatest))
+	}
+}
+
+func (suite *S3Suite) TestLifecycleNoncurrentExpiration() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : expire the noncurrent versions under a prefix a day after
+		           they are replaced.
+		Assertion: only the current version of the keys under the prefix is
+		           kept; keys under another prefix keep every version.
+	*/
+
+	suite.skipUnlessLifecycleDebug()
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	var current string
+	for i := 0; i < 3; i++ {
+		current, err = PutObjectVersion(svc, bucket, "test1/a", fmt.Sprintf("data%d", i))
+		assert.Nil(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = PutObjectVersion(svc, bucket, "test2/a", fmt.Sprintf("data%d", i))
+		assert.Nil(err)
+	}
+
+	err = PutLifecycleRules(svc, bucket, &s3.LifecycleRule{
+		ID:                          aws.String("rule1"),
+		Filter:                      PrefixFilter("test1/"),
+		Status:                      aws.String(s3.ExpirationStatusEnabled),
+		NoncurrentVersionExpiration: &s3.NoncurrentVersionExpiration{NoncurrentDays: aws.Int64(1)},
+	})
+	assert.Nil(err)
+
+	err = WaitForLifecycle(1, func() (bool, error) {
+		resp, err := ListObjectVersions(svc, bucket, "test1/")
+		return err == nil && len(resp.Versions) == 1, err
+	})
+	assert.Nil(err)
+
+	resp, err := ListObjectVersions(svc, bucket, "test1/")
+	assert.Nil(err)
+	if assert.Equal(1, len(resp.Versions)) {
+		assert.Equal(current, aws.StringValue(resp.Versions[0].VersionId))
+	}
+
+	resp, err = ListObjectVersions(svc, bucket, "test2/")
+	assert.Nil(err)
+	assert.Equal(2, len(resp.Versions))
+}
+
+func (suite *S3Suite) TestLifecycleAbortIncompleteMultipart() {
+
+	/*
+		Resource : bucket, method: lifecycle
+		Scenario : abort the multipart uploads under a prefix a day after they
+		           are initiated.
+		Assertion: the uploads under the prefix are aborted; the others are
+		           still in progress.
+	*/
+
+	suite.skipUnlessLifecycleDebug()
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, key := range []string{"uploads/a", "uploads/b", "other/a"} {
+		_, err = InitiateMultipartUpload(svc, bucket, key)
+		assert.Nil(err)
+	}
+
+	err = PutLifecycleRules(svc, bucket, &s3.LifecycleRule{
+		ID:                             aws.String("rule1"),
+		Filter:                         PrefixFilter("uploads/"),
+		Status:                         aws.String(s3.ExpirationStatusEnabled),
+		AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: aws.Int64(1)},
+	})
+	assert.Nil(err)
+
+	err = WaitForLifecycle(1, func() (bool, error) {
+		uploads, _, _, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{})
+		return len(uploads) == 1, err
+	})
+	assert.Nil(err)
+
+	uploads, _, _, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{})
+	assert.Nil(err)
+	assert.Equal([]string{"other/a"}, uploadKeys(uploads))
+}
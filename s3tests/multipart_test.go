@@ -0,0 +1,511 @@
+package s3test
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+func (suite *S3Suite) TestMultipartUploadParts() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : upload an object in three parts, the last one small.
+		Assertion: the object holds the parts in order and its ETag is the MD5
+		           of the part MD5s followed by -3, on completion and on HEAD.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, resp, err := UploadMultipart(svc, bucket, key, []int{MinPartSize, MinPartSize, 1024}, 1)
+	assert.Nil(err)
+	assert.Equal(u.ETag(), aws.StringValue(resp.ETag))
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	assert.Nil(err)
+	assert.Equal(u.ETag(), aws.StringValue(head.ETag))
+	assert.Equal(int64(2*MinPartSize+1024), aws.Int64Value(head.ContentLength))
+
+	got, err := GetObject(svc, bucket, key)
+	assert.Nil(err)
+	assert.True(got == u.Content(), "object contents differ from the parts uploaded")
+}
+
+func (suite *S3Suite) TestMultipartUploadConcurrentParts() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : upload six parts four at a time.
+		Assertion: parts finishing out of order still make up the object in
+		           part number order.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	sizes := []int{MinPartSize, MinPartSize, MinPartSize, MinPartSize, MinPartSize, 1}
+
+	u, resp, err := UploadMultipart(svc, bucket, key, sizes, 4)
+	assert.Nil(err)
+	assert.Equal(u.ETag(), aws.StringValue(resp.ETag))
+
+	got, err := GetObject(svc, bucket, key)
+	assert.Nil(err)
+	assert.True(got == u.Content(), "object contents differ from the parts uploaded")
+}
+
+func (suite *S3Suite) TestMultipartUploadListParts() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : list the parts of an upload in progress.
+		Assertion: every part is listed in order with its ETag and size.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, err := UploadParts(svc, bucket, key, []int{MinPartSize, 10, 20}, 3)
+	assert.Nil(err)
+
+	resp, err := Listparts(svc, bucket, key, u.UploadID)
+	assert.Nil(err)
+
+	if assert.Equal(3, len(resp.Parts)) {
+		for i, p := range resp.Parts {
+			assert.Equal(int64(i+1), aws.Int64Value(p.PartNumber))
+			assert.Equal(aws.StringValue(u.Parts[i].ETag), aws.StringValue(p.ETag))
+			assert.Equal(int64(len(u.Data[i])), aws.Int64Value(p.Size))
+		}
+	}
+
+	_, err = AbortMultiPartUpload(svc, bucket, key, u.UploadID)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestMultipartUploadPartsOutOfOrder() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : complete an upload with its parts listed out of order.
+		Assertion: fails InvalidPartOrder, and the upload can still be completed
+		           in order.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, err := UploadParts(svc, bucket, key, []int{MinPartSize, MinPartSize, 10}, 3)
+	assert.Nil(err)
+
+	_, err = u.Complete(svc, u.Parts[1], u.Parts[0], u.Parts[2])
+	suite.expectError(err, "InvalidPartOrder", http.StatusBadRequest)
+
+	resp, err := u.Complete(svc, u.Parts...)
+	assert.Nil(err)
+	assert.Equal(u.ETag(), aws.StringValue(resp.ETag))
+}
+
+func (suite *S3Suite) TestMultipartUploadDuplicatePart() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : complete an upload listing the same part number twice.
+		Assertion: fails InvalidPartOrder.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, err := UploadParts(svc, bucket, key, []int{MinPartSize, 10}, 1)
+	assert.Nil(err)
+
+	_, err = u.Complete(svc, u.Parts[0], u.Parts[0], u.Parts[1])
+	suite.expectError(err, "InvalidPartOrder", http.StatusBadRequest)
+
+	_, err = AbortMultiPartUpload(svc, bucket, key, u.UploadID)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestMultipartUploadPartTooSmall() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : complete an upload whose first part is under the minimum
+		           part size.
+		Assertion: fails EntityTooSmall; a single small part is allowed.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, _, err = UploadMultipart(svc, bucket, "small", []int{1024, 1024}, 1)
+	suite.expectError(err, "EntityTooSmall", http.StatusBadRequest)
+
+	u, resp, err := UploadMultipart(svc, bucket, "single", []int{1024}, 1)
+	assert.Nil(err)
+	assert.Equal(u.ETag(), aws.StringValue(resp.ETag))
+}
+
+func (suite *S3Suite) TestMultipartUploadWrongETag() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : complete an upload giving part 2 the ETag of part 1, then
+		           naming a part that was never uploaded.
+		Assertion: both fail InvalidPart.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, err := UploadParts(svc, bucket, key, []int{MinPartSize, 10}, 2)
+	assert.Nil(err)
+
+	wrong := &s3.CompletedPart{ETag: u.Parts[0].ETag, PartNumber: aws.Int64(2)}
+
+	_, err = u.Complete(svc, u.Parts[0], wrong)
+	suite.expectError(err, "InvalidPart", http.StatusBadRequest)
+
+	missing := &s3.CompletedPart{ETag: u.Parts[1].ETag, PartNumber: aws.Int64(3)}
+
+	_, err = u.Complete(svc, u.Parts[0], missing)
+	suite.expectError(err, "InvalidPart", http.StatusBadRequest)
+
+	_, err = AbortMultiPartUpload(svc, bucket, key, u.UploadID)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestMultipartUploadReuploadPart() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : upload part 2 again with other contents before completing.
+		Assertion: the old ETag of the part is rejected with InvalidPart and the
+		           object holds the new contents.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, err := UploadParts(svc, bucket, key, []int{MinPartSize, 10}, 1)
+	assert.Nil(err)
+
+	old := u.Parts[1]
+
+	u.Data[1] = "replacement"
+	u.Parts[1], err = u.UploadPart(svc, 2, u.Data[1])
+	assert.Nil(err)
+	assert.NotEqual(aws.StringValue(old.ETag), aws.StringValue(u.Parts[1].ETag))
+
+	_, err = u.Complete(svc, u.Parts[0], old)
+	suite.expectError(err, "InvalidPart", http.StatusBadRequest)
+
+	resp, err := u.Complete(svc, u.Parts...)
+	assert.Nil(err)
+	assert.Equal(u.ETag(), aws.StringValue(resp.ETag))
+
+	got, err := GetObject(svc, bucket, key)
+	assert.Nil(err)
+	assert.True(got == u.Content(), "object contents differ from the parts uploaded")
+}
+
+// startUploads starts a multipart upload for each of keys and returns the
+// upload IDs in the same order.
+func (suite *S3Suite) startUploads(bucket string, keys ...string) []string {
+
+	var ids []string
+	for _, key := range keys {
+		resp, err := InitiateMultipartUpload(svc, bucket, key)
+		suite.Nil(err, key)
+		ids = append(ids, aws.StringValue(resp.UploadId))
+	}
+	return ids
+}
+
+// uploadKeys returns the keys of uploads, in listing order.
+func uploadKeys(uploads []*s3.MultipartUpload) []string {
+
+	keys := []string{}
+	for _, u := range uploads {
+		keys = append(keys, aws.StringValue(u.Key))
+	}
+	return keys
+}
+
+func (suite *S3Suite) TestListMultipartUploads() {
+
+	/*
+		Resource : bucket, method: list multipart uploads
+		Scenario : list a bucket with three uploads in progress, two of them
+		           for the same key.
+		Assertion: every upload is listed by key with its ID and owner.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	resp, err := ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{})
+	assert.Nil(err)
+	assert.Empty(resp.Uploads)
+	assert.Equal(false, aws.BoolValue(resp.IsTruncated))
+
+	ids := suite.startUploads(bucket, "foo", "bar", "foo")
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	resp, err = ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{})
+	assert.Nil(err)
+	assert.Equal(bucket, aws.StringValue(resp.Bucket))
+	assert.Equal([]string{"bar", "foo", "foo"}, uploadKeys(resp.Uploads))
+
+	listed := []string{}
+	for _, u := range resp.Uploads {
+		listed = append(listed, aws.StringValue(u.UploadId))
+		if assert.NotNil(u.Owner) {
+			assert.Equal(mainID, aws.StringValue(u.Owner.ID))
+		}
+		assert.NotNil(u.Initiated)
+	}
+	assert.ElementsMatch(ids, listed)
+}
+
+func (suite *S3Suite) TestListMultipartUploadsPrefixDelimiter() {
+
+	/*
+		Resource : bucket, method: list multipart uploads
+		Scenario : list uploads with a prefix and a delimiter.
+		Assertion: only uploads under the prefix are listed, and keys sharing a
+		           prefix up to the delimiter roll up into common prefixes.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	suite.startUploads(bucket, "asdf", "boo/bar", "boo/baz/xyzzy", "cquux/thud", "cquux/bla")
+
+	resp, err := ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{Prefix: "boo/"})
+	assert.Nil(err)
+	assert.Equal([]string{"boo/bar", "boo/baz/xyzzy"}, uploadKeys(resp.Uploads))
+	assert.Equal("boo/", aws.StringValue(resp.Prefix))
+
+	uploads, prefixes, _, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{Delimiter: "/"})
+	assert.Nil(err)
+	assert.Equal([]string{"asdf"}, uploadKeys(uploads))
+	assert.Equal([]string{"boo/", "cquux/"}, prefixes)
+
+	uploads, prefixes, _, err = ListAllMultipartUploads(svc, bucket, ListUploadsOptions{Prefix: "boo/", Delimiter: "/"})
+	assert.Nil(err)
+	assert.Equal([]string{"boo/bar"}, uploadKeys(uploads))
+	assert.Equal([]string{"boo/baz/"}, prefixes)
+}
+
+func (suite *S3Suite) TestListMultipartUploadsMaxUploads() {
+
+	/*
+		Resource : bucket, method: list multipart uploads
+		Scenario : page through uploads with max-uploads smaller than the
+		           number of uploads, with and without a delimiter.
+		Assertion: pages are truncated at max-uploads and together list every
+		           upload and common prefix exactly once, in order.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	keys := []string{"a", "b", "b", "b/1", "b/2", "c", "d/1", "d/2", "e"}
+	ids := suite.startUploads(bucket, keys...)
+
+	resp, err := ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{MaxUploads: 2})
+	assert.Nil(err)
+	assert.Equal(2, len(resp.Uploads))
+	assert.Equal(int64(2), aws.Int64Value(resp.MaxUploads))
+	assert.Equal(true, aws.BoolValue(resp.IsTruncated))
+	assert.Equal("b", aws.StringValue(resp.NextKeyMarker))
+	assert.Equal(aws.StringValue(resp.Uploads[1].UploadId), aws.StringValue(resp.NextUploadIdMarker))
+
+	for _, maxUploads := range []int64{1, 2, 3, 1000} {
+		uploads, _, pages, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{MaxUploads: maxUploads})
+		assert.Nil(err, maxUploads)
+		assert.Equal(keys, uploadKeys(uploads), maxUploads)
+		assert.True(pages >= (len(ids)+int(maxUploads)-1)/int(maxUploads), maxUploads)
+
+		uploads, prefixes, _, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{MaxUploads: maxUploads, Delimiter: "/"})
+		assert.Nil(err, maxUploads)
+		assert.Equal([]string{"a", "b", "b", "c", "e"}, uploadKeys(uploads), maxUploads)
+		assert.Equal([]string{"b/", "d/"}, prefixes, maxUploads)
+	}
+}
+
+func (suite *S3Suite) TestListMultipartUploadsKeyMarker() {
+
+	/*
+		Resource : bucket, method: list multipart uploads
+		Scenario : list uploads after a key-marker without an upload-id-marker.
+		Assertion: every upload of the marker key is skipped, along with keys
+		           sorting before it.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	suite.startUploads(bucket, "bar", "baz", "baz", "foo")
+
+	resp, err := ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{KeyMarker: "baz"})
+	assert.Nil(err)
+	assert.Equal([]string{"foo"}, uploadKeys(resp.Uploads))
+	assert.Equal("baz", aws.StringValue(resp.KeyMarker))
+
+	resp, err = ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{KeyMarker: "bat"})
+	assert.Nil(err)
+	assert.Equal([]string{"baz", "baz", "foo"}, uploadKeys(resp.Uploads))
+}
+
+func (suite *S3Suite) TestListMultipartUploadsUploadIDMarker() {
+
+	/*
+		Resource : bucket, method: list multipart uploads
+		Scenario : list uploads after a key-marker and one of the uploads of
+		           that key.
+		Assertion: the listing resumes after that upload, with the later
+		           uploads of the same key.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	suite.startUploads(bucket, "bar", "foo", "foo", "foo", "xyzzy")
+
+	all, err := ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{})
+	assert.Nil(err)
+	if !assert.Equal(5, len(all.Uploads)) {
+		return
+	}
+
+	marker := aws.StringValue(all.Uploads[1].UploadId)
+
+	resp, err := ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{KeyMarker: "foo", UploadIDMarker: marker})
+	assert.Nil(err)
+	assert.Equal(marker, aws.StringValue(resp.UploadIdMarker))
+	assert.Equal([]string{"foo", "foo", "xyzzy"}, uploadKeys(resp.Uploads))
+
+	for i, u := range resp.Uploads {
+		assert.Equal(aws.StringValue(all.Uploads[i+2].UploadId), aws.StringValue(u.UploadId))
+	}
+}
+
+func (suite *S3Suite) TestListMultipartUploadsFinished() {
+
+	/*
+		Resource : bucket, method: list multipart uploads
+		Scenario : complete one upload and abort another.
+		Assertion: only the upload still in progress is listed.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	completed, err := UploadParts(svc, bucket, "completed", []int{10}, 1)
+	assert.Nil(err)
+
+	ids := suite.startUploads(bucket, "aborted", "pending")
+
+	_, err = completed.Complete(svc, completed.Parts...)
+	assert.Nil(err)
+
+	_, err = AbortMultiPartUpload(svc, bucket, "aborted", ids[0])
+	assert.Nil(err)
+
+	resp, err := ListMultipartUploadsPage(svc, bucket, ListUploadsOptions{})
+	assert.Nil(err)
+	assert.Equal([]string{"pending"}, uploadKeys(resp.Uploads))
+	if len(resp.Uploads) == 1 {
+		assert.Equal(ids[1], aws.StringValue(resp.Uploads[0].UploadId))
+	}
+}
+
+func (suite *S3Suite) TestAbortMultipartUploads() {
+
+	/*
+		Resource : bucket, method: abort multipart upload
+		Scenario : abort every upload left in progress in a bucket, some with
+		           parts uploaded, then delete the bucket.
+		Assertion: no uploads are listed afterwards and the bucket can be
+		           deleted.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = UploadParts(svc, bucket, "withparts", []int{MinPartSize, 10}, 2)
+	assert.Nil(err)
+
+	suite.startUploads(bucket, "a", "a", "dir/b")
+
+	err = AbortMultipartUploads(svc, bucket)
+	assert.Nil(err)
+
+	uploads, _, _, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{})
+	assert.Nil(err)
+	assert.Empty(uploads)
+
+	err = DeleteBucket(svc, bucket)
+	assert.Nil(err)
+}
@@ -4,9 +4,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 
-	"github.com/spf13/viper"
-
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -871,7 +870,7 @@ func (suite *S3Suite) TestSSEKMSPresent() {
 
 	err := CreateBucket(svc, bucket)
 
-	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", viper.GetString("s3main.SSE"), viper.GetString("s3main.kmskeyid"))
+	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", GetConfig().Main.SSE, GetConfig().Main.KMSKeyID)
 
 	if awsErr, ok := err.(awserr.Error); ok {
 
@@ -901,7 +900,7 @@ func (suite *S3Suite) TestSSEKMSNoKey() {
 
 	err := CreateBucket(svc, bucket)
 
-	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", viper.GetString("s3main.SSE"), "")
+	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", GetConfig().Main.SSE, "")
 	if awsErr, ok := err.(awserr.Error); ok {
 
 		assert.NotNil(awsErr)
@@ -927,8 +926,8 @@ func (suite *S3Suite) TestSSEKMSNotDeclared() {
 
 	err := CreateBucket(svc, bucket)
 
-	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", "", viper.GetString("s3main.kmskeyid"))
-	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", viper.GetString("s3main.SSE"), "")
+	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", "", GetConfig().Main.KMSKeyID)
+	err = WriteSSEKMSkeyId(svc, bucket, "kay1", "test", GetConfig().Main.SSE, "")
 
 	if awsErr, ok := err.(awserr.Error); ok {
 
@@ -989,7 +988,7 @@ func (suite *S3Suite) TestGetObjectIfmatchFailed() {
 		if awsErr, ok := err.(awserr.Error); ok {
 
 			assert.Equal(awsErr.Code(), "PreconditionFailed")
-			assert.Equal(awsErr.Code(), "")
+			assert.Equal(awsErr.(awserr.RequestFailure).StatusCode(), http.StatusPreconditionFailed)
 		}
 	}
 
@@ -1054,13 +1053,13 @@ func (suite *S3Suite) TestGetObjectIfModifiedSinceGood() {
 	assert := suite
 	bucket := GetBucketName()
 	objects := map[string]string{"foo": "bar"}
-	now := time.Now()
+	before := time.Date(1994, 10, 29, 19, 43, 31, 0, time.UTC)
 
 	err := CreateBucket(svc, bucket)
 	err = CreateObjects(svc, bucket, objects)
 	_, err = GetObj(svc, bucket, "foo")
 
-	got, err := GetObjectWithIfModifiedSince(svc, bucket, "foo", now)
+	got, err := GetObjectWithIfModifiedSince(svc, bucket, "foo", before)
 	assert.Nil(err)
 	assert.Equal(got, "bar")
 }
@@ -1076,12 +1075,12 @@ func (suite *S3Suite) TestGetObjectIfUnModifiedSinceGood() {
 	assert := suite
 	bucket := GetBucketName()
 	objects := map[string]string{"foo": "bar"}
-	now := time.Now()
+	before := time.Date(1994, 10, 29, 19, 43, 31, 0, time.UTC)
 
 	err := CreateBucket(svc, bucket)
 	err = CreateObjects(svc, bucket, objects)
 
-	_, err = GetObjectWithIfUnModifiedSince(svc, bucket, "foo", now)
+	_, err = GetObjectWithIfUnModifiedSince(svc, bucket, "foo", before)
 	assert.NotNil(err)
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
@@ -1148,7 +1147,7 @@ func (suite *S3Suite) TestPutObjectIfMatchFailed() {
 	/*
 		Resource : object, method: get
 		Scenario : data re-write w/ If-Match: outdated ETag
-		Assertion: replaces previous data.
+		Assertion: fails, keeping previous data.
 	*/
 
 	assert := suite
@@ -1162,10 +1161,18 @@ func (suite *S3Suite) TestPutObjectIfMatchFailed() {
 	assert.Equal(gotData, "bar")
 
 	err = PutObjectWithIfMatch(svc, bucket, "key1", "zar", "ABCORZmmmm")
+	assert.NotNil(err)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+
+			assert.Equal(awsErr.Code(), "PreconditionFailed")
+			assert.Equal(awsErr.(awserr.RequestFailure).StatusCode(), http.StatusPreconditionFailed)
+		}
+	}
 
 	oldData, err := GetObject(svc, bucket, "key1")
 	assert.Nil(err)
-	assert.Equal(oldData, "zar")
+	assert.Equal(oldData, "bar")
 }
 
 func (suite *S3Suite) TestPutObjectIfmatchNonexistedFailed() {
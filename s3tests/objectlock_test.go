@@ -0,0 +1,431 @@
+package s3test
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+// lockedVersion creates a bucket with Object Lock enabled holding one
+// object, and returns the bucket and the version ID of the object.
+func (suite *S3Suite) lockedVersion(key string, content string) (string, string) {
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateObjectLockBucket(svc, bucket)
+	assert.Nil(err)
+
+	id, err := PutObjectVersion(svc, bucket, key, content)
+	assert.Nil(err)
+
+	return bucket, id
+}
+
+func (suite *S3Suite) TestObjectLockPutGetConfiguration() {
+
+	/*
+		Resource : bucket, method: object-lock
+		Scenario : create a bucket with Object Lock enabled, read its
+		           configuration, then set a default retention and read it back.
+		Assertion: versioning is enabled on the bucket; Object Lock is enabled
+		           without a rule at first, then with the rule set.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateObjectLockBucket(svc, bucket)
+	assert.Nil(err)
+
+	status, err := GetBucketVersioning(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(s3.BucketVersioningStatusEnabled, status)
+
+	config, err := GetObjectLockConfiguration(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockEnabledEnabled, aws.StringValue(config.ObjectLockEnabled))
+	assert.Nil(config.Rule)
+
+	err = PutObjectLockConfiguration(svc, bucket, ObjectLockConfig(s3.ObjectLockRetentionModeGovernance, 1))
+	assert.Nil(err)
+
+	config, err = GetObjectLockConfiguration(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(ObjectLockConfig(s3.ObjectLockRetentionModeGovernance, 1).String(), config.String())
+}
+
+func (suite *S3Suite) TestObjectLockInvalidConfiguration() {
+
+	/*
+		Resource : bucket, method: object-lock
+		Scenario : set and read Object Lock configuration on a bucket created
+		           without it, then set default retentions with an unknown mode,
+		           with both days and years, and with zero days.
+		Assertion: the bucket without Object Lock fails InvalidBucketState and
+		           ObjectLockConfigurationNotFoundError; the bad rules fail
+		           MalformedXML and InvalidArgument.
+	*/
+
+	assert := suite
+	plain := GetBucketName()
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, plain)
+	assert.Nil(err)
+
+	err = PutObjectLockConfiguration(svc, plain, ObjectLockConfig(s3.ObjectLockRetentionModeGovernance, 1))
+	suite.expectError(err, "InvalidBucketState", http.StatusConflict)
+
+	_, err = GetObjectLockConfiguration(svc, plain)
+	suite.expectError(err, "ObjectLockConfigurationNotFoundError", http.StatusNotFound)
+
+	err = CreateObjectLockBucket(svc, bucket)
+	assert.Nil(err)
+
+	bothPeriods := ObjectLockConfig(s3.ObjectLockRetentionModeGovernance, 1)
+	bothPeriods.Rule.DefaultRetention.Years = aws.Int64(1)
+
+	err = PutObjectLockConfiguration(svc, bucket, ObjectLockConfig("WORM", 1))
+	suite.expectError(err, "MalformedXML", http.StatusBadRequest)
+
+	err = PutObjectLockConfiguration(svc, bucket, bothPeriods)
+	suite.expectError(err, "MalformedXML", http.StatusBadRequest)
+
+	err = PutObjectLockConfiguration(svc, bucket, ObjectLockConfig(s3.ObjectLockRetentionModeGovernance, 0))
+	suite.expectError(err, "InvalidArgument", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestObjectLockSuspendVersioning() {
+
+	/*
+		Resource : bucket, method: versioning
+		Scenario : suspend versioning on a bucket with Object Lock enabled.
+		Assertion: fails InvalidBucketState and versioning stays enabled.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateObjectLockBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, s3.BucketVersioningStatusSuspended)
+	suite.expectError(err, "InvalidBucketState", http.StatusConflict)
+
+	status, err := GetBucketVersioning(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(s3.BucketVersioningStatusEnabled, status)
+}
+
+func (suite *S3Suite) TestObjectRetentionGovernance() {
+
+	/*
+		Resource : object, method: retention
+		Scenario : set governance retention on a version, then delete it,
+		           overwrite its key, shorten its retention, and delete it
+		           again bypassing governance retention.
+		Assertion: the retention is read back; the version can neither be
+		           deleted nor its retention shortened without the bypass, and
+		           overwriting the key leaves it intact; with the bypass it is
+		           deleted.
+	*/
+
+	assert := suite
+	bucket, id := suite.lockedVersion("locked", "original")
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	err := PutObjectRetention(svc, bucket, "locked", id, s3.ObjectLockRetentionModeGovernance, until, false)
+	assert.Nil(err)
+
+	retention, err := GetObjectRetention(svc, bucket, "locked", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockRetentionModeGovernance, aws.StringValue(retention.Mode))
+	assert.True(until.Equal(aws.TimeValue(retention.RetainUntilDate)))
+
+	_, err = DeleteObjectVersion(svc, bucket, "locked", id)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = PutObjectVersion(svc, bucket, "locked", "overwritten")
+	assert.Nil(err)
+
+	content, err := GetObjectVersion(svc, bucket, "locked", id)
+	assert.Nil(err)
+	assert.Equal("original", content)
+
+	err = PutObjectRetention(svc, bucket, "locked", id, s3.ObjectLockRetentionModeGovernance, until.Add(-time.Hour), false)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = DeleteObjectVersionBypass(svc, bucket, "locked", id)
+	assert.Nil(err)
+
+	_, err = GetObjectVersion(svc, bucket, "locked", id)
+	suite.expectError(err, "NoSuchVersion", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestObjectRetentionCompliance() {
+
+	/*
+		Resource : object, method: retention
+		Scenario : set a short compliance retention on a version, then delete
+		           it bypassing governance retention, shorten its retention,
+		           move it to governance mode, and extend it.
+		Assertion: deleting and weakening the retention fail AccessDenied even
+		           with the bypass; extending it succeeds.
+	*/
+
+	assert := suite
+	bucket, id := suite.lockedVersion("locked", "original")
+	until := time.Now().Add(3 * time.Second).UTC().Truncate(time.Second)
+
+	err := PutObjectRetention(svc, bucket, "locked", id, s3.ObjectLockRetentionModeCompliance, until, false)
+	assert.Nil(err)
+
+	err = DeleteObjectVersionBypass(svc, bucket, "locked", id)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = PutObjectRetention(svc, bucket, "locked", id, s3.ObjectLockRetentionModeCompliance, until.Add(-time.Second), true)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = PutObjectRetention(svc, bucket, "locked", id, s3.ObjectLockRetentionModeGovernance, until, true)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = PutObjectRetention(svc, bucket, "locked", id, s3.ObjectLockRetentionModeCompliance, until.Add(time.Second), false)
+	assert.Nil(err)
+
+	retention, err := GetObjectRetention(svc, bucket, "locked", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockRetentionModeCompliance, aws.StringValue(retention.Mode))
+	assert.True(until.Add(time.Second).Equal(aws.TimeValue(retention.RetainUntilDate)))
+
+	content, err := GetObjectVersion(svc, bucket, "locked", id)
+	assert.Nil(err)
+	assert.Equal("original", content)
+}
+
+func (suite *S3Suite) TestObjectRetentionDefault() {
+
+	/*
+		Resource : object, method: put
+		Scenario : write an object to a bucket with a default governance
+		           retention of one day.
+		Assertion: the object gets the retention, reported on reads too, and
+		           cannot be deleted without bypassing it.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateObjectLockBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectLockConfiguration(svc, bucket, ObjectLockConfig(s3.ObjectLockRetentionModeGovernance, 1))
+	assert.Nil(err)
+
+	id, err := PutObjectVersion(svc, bucket, "key", "echo")
+	assert.Nil(err)
+
+	retention, err := GetObjectRetention(svc, bucket, "key", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockRetentionModeGovernance, aws.StringValue(retention.Mode))
+	assert.WithinDuration(time.Now().Add(24*time.Hour), aws.TimeValue(retention.RetainUntilDate), time.Minute)
+
+	head, err := HeadObjectVersion(svc, bucket, "key", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockModeGovernance, aws.StringValue(head.ObjectLockMode))
+	assert.WithinDuration(time.Now().Add(24*time.Hour), aws.TimeValue(head.ObjectLockRetainUntilDate), time.Minute)
+
+	_, err = DeleteObjectVersion(svc, bucket, "key", id)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestObjectLockPutObjectHeaders() {
+
+	/*
+		Resource : object, method: put
+		Scenario : write an object with a retention and a legal hold set
+		           through the x-amz-object-lock-* headers.
+		Assertion: both are read back, and reported on reads.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	err := CreateObjectLockBucket(svc, bucket)
+	assert.Nil(err)
+
+	resp, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:                    aws.String(bucket),
+		Key:                       aws.String("key"),
+		Body:                      strings.NewReader("echo"),
+		ObjectLockMode:            aws.String(s3.ObjectLockModeGovernance),
+		ObjectLockRetainUntilDate: aws.Time(until),
+		ObjectLockLegalHoldStatus: aws.String(s3.ObjectLockLegalHoldStatusOn),
+	})
+	assert.Nil(err)
+	id := aws.StringValue(resp.VersionId)
+
+	retention, err := GetObjectRetention(svc, bucket, "key", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockRetentionModeGovernance, aws.StringValue(retention.Mode))
+	assert.True(until.Equal(aws.TimeValue(retention.RetainUntilDate)))
+
+	status, err := GetObjectLegalHold(svc, bucket, "key", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockLegalHoldStatusOn, status)
+
+	head, err := HeadObjectVersion(svc, bucket, "key", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockModeGovernance, aws.StringValue(head.ObjectLockMode))
+	assert.True(until.Equal(aws.TimeValue(head.ObjectLockRetainUntilDate)))
+	assert.Equal(s3.ObjectLockLegalHoldStatusOn, aws.StringValue(head.ObjectLockLegalHoldStatus))
+}
+
+func (suite *S3Suite) TestObjectRetentionInvalid() {
+
+	/*
+		Resource : object, method: retention
+		Scenario : set retention on an object in a bucket without Object Lock,
+		           set a retention ending in the past, and read the retention
+		           of a version without one.
+		Assertion: fail InvalidRequest, InvalidArgument and
+		           NoSuchObjectLockConfiguration.
+	*/
+
+	assert := suite
+	plain := GetBucketName()
+	bucket, id := suite.lockedVersion("key", "echo")
+
+	err := CreateBucket(svc, plain)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, plain, "key", "echo")
+	assert.Nil(err)
+
+	err = PutObjectRetention(svc, plain, "key", "", s3.ObjectLockRetentionModeGovernance, time.Now().Add(time.Hour), false)
+	suite.expectError(err, "InvalidRequest", http.StatusBadRequest)
+
+	err = PutObjectRetention(svc, bucket, "key", id, s3.ObjectLockRetentionModeGovernance, time.Now().Add(-time.Hour), false)
+	suite.expectError(err, "InvalidArgument", http.StatusBadRequest)
+
+	_, err = GetObjectRetention(svc, bucket, "key", id)
+	suite.expectError(err, "NoSuchObjectLockConfiguration", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestObjectLegalHold() {
+
+	/*
+		Resource : object, method: legal-hold
+		Scenario : turn a legal hold on for a version, delete it bypassing
+		           governance retention, then turn the hold off and delete it.
+		Assertion: the hold is read back; the version cannot be deleted while
+		           it is on, even with the bypass, and is deleted once it is off.
+	*/
+
+	assert := suite
+	bucket, id := suite.lockedVersion("held", "echo")
+
+	err := PutObjectLegalHold(svc, bucket, "held", id, s3.ObjectLockLegalHoldStatusOn)
+	assert.Nil(err)
+
+	status, err := GetObjectLegalHold(svc, bucket, "held", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockLegalHoldStatusOn, status)
+
+	err = DeleteObjectVersionBypass(svc, bucket, "held", id)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = PutObjectLegalHold(svc, bucket, "held", id, s3.ObjectLockLegalHoldStatusOff)
+	assert.Nil(err)
+
+	status, err = GetObjectLegalHold(svc, bucket, "held", id)
+	assert.Nil(err)
+	assert.Equal(s3.ObjectLockLegalHoldStatusOff, status)
+
+	_, err = DeleteObjectVersion(svc, bucket, "held", id)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestObjectLockDeleteObjects() {
+
+	/*
+		Resource : bucket, method: delete objects
+		Scenario : delete a locked version and an unlocked one in one request,
+		           without and with bypassing governance retention.
+		Assertion: without the bypass only the unlocked version is deleted and
+		           the locked one is reported AccessDenied; with it, both are.
+	*/
+
+	assert := suite
+	bucket, locked := suite.lockedVersion("locked", "echo")
+
+	free, err := PutObjectVersion(svc, bucket, "free", "echo")
+	assert.Nil(err)
+
+	err = PutObjectRetention(svc, bucket, "locked", locked, s3.ObjectLockRetentionModeGovernance, time.Now().Add(time.Hour), false)
+	assert.Nil(err)
+
+	input := &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{
+			{Key: aws.String("locked"), VersionId: aws.String(locked)},
+			{Key: aws.String("free"), VersionId: aws.String(free)},
+		}},
+	}
+
+	resp, err := svc.DeleteObjects(input)
+	assert.Nil(err)
+	assert.Equal(1, len(resp.Deleted))
+	if assert.Equal(1, len(resp.Errors)) {
+		assert.Equal("locked", aws.StringValue(resp.Errors[0].Key))
+		assert.Equal("AccessDenied", aws.StringValue(resp.Errors[0].Code))
+	}
+
+	input.Delete.Objects = input.Delete.Objects[:1]
+	input.BypassGovernanceRetention = aws.Bool(true)
+
+	resp, err = svc.DeleteObjects(input)
+	assert.Nil(err)
+	assert.Equal(1, len(resp.Deleted))
+	assert.Equal(0, len(resp.Errors))
+}
+
+func (suite *S3Suite) TestObjectLockDeletePrefixedBuckets() {
+
+	/*
+		Resource : bucket, method: delete
+		Scenario : clean up a bucket holding versions under governance
+		           retention, under a short compliance retention and under a
+		           legal hold, the way the suite does after each test.
+		Assertion: the bucket is deleted.
+	*/
+
+	assert := suite
+	bucket, governed := suite.lockedVersion("governed", "echo")
+
+	err := PutObjectRetention(svc, bucket, "governed", governed, s3.ObjectLockRetentionModeGovernance, time.Now().Add(time.Hour), false)
+	assert.Nil(err)
+
+	complied, err := PutObjectVersion(svc, bucket, "complied", "echo")
+	assert.Nil(err)
+
+	err = PutObjectRetention(svc, bucket, "complied", complied, s3.ObjectLockRetentionModeCompliance, time.Now().Add(2*time.Second), false)
+	assert.Nil(err)
+
+	held, err := PutObjectVersion(svc, bucket, "held", "echo")
+	assert.Nil(err)
+
+	err = PutObjectLegalHold(svc, bucket, "held", held, s3.ObjectLockLegalHoldStatusOn)
+	assert.Nil(err)
+
+	DeletePrefixedBuckets(svc)
+
+	_, err = svc.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	suite.expectError(err, "NotFound", http.StatusNotFound)
+}
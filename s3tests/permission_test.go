@@ -0,0 +1,510 @@
+package s3test
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+var altSvc *s3.S3
+var anonSvc *s3.S3
+
+func errCode(err error) string {
+
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+func (suite *S3Suite) TestBucketPrivateDeniesAltUser() {
+
+	/*
+		Resource : bucket, method: list/put object
+		Scenario : second user accesses a private bucket of the main user.
+		Assertion: reads and writes are denied for alt and anonymous users.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "private"})
+	assert.Nil(err)
+
+	_, err = ListObjects(altSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = PutObjectToBucket(altSvc, bucket, "foo", "bar")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = ListObjects(anonSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = PutObjectToBucket(anonSvc, bucket, "foo", "bar")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketPublicReadAltUser() {
+
+	/*
+		Resource : bucket, method: list/put object
+		Scenario : second user accesses a public-read bucket of the main user.
+		Assertion: listing is allowed, writing is denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read"})
+	assert.Nil(err)
+
+	_, err = ListObjects(altSvc, bucket)
+	assert.Nil(err)
+
+	_, err = ListObjects(anonSvc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(altSvc, bucket, "foo", "bar")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	err = PutObjectToBucket(anonSvc, bucket, "foo", "bar")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketPublicReadWriteAltUser() {
+
+	/*
+		Resource : bucket, method: put object
+		Scenario : second user writes to a public-read-write bucket of the main user.
+		Assertion: writes are allowed for alt and anonymous users.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read-write"})
+	assert.Nil(err)
+
+	err = PutObjectToBucket(altSvc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	err = PutObjectToBucket(anonSvc, bucket, "baz", "bar")
+	assert.Nil(err)
+
+	keys, err := ListObjects(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(2, len(keys))
+}
+
+func (suite *S3Suite) TestBucketAuthenticatedReadAltUser() {
+
+	/*
+		Resource : bucket, method: list
+		Scenario : authenticated-read bucket listed by alt and anonymous users.
+		Assertion: alt user may list, anonymous user is denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "authenticated-read"})
+	assert.Nil(err)
+
+	_, err = ListObjects(altSvc, bucket)
+	assert.Nil(err)
+
+	_, err = ListObjects(anonSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketDeleteByAltUserDenied() {
+
+	/*
+		Resource : bucket, method: delete
+		Scenario : second user deletes a public-read-write bucket of the main user.
+		Assertion: only the owner may delete a bucket.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read-write"})
+	assert.Nil(err)
+
+	err = DeleteBucket(altSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	bkts, err := ListBuckets(svc)
+	assert.Nil(err)
+	assert.Equal(true, Contains(bkts, bucket))
+}
+
+func (suite *S3Suite) TestBucketCreateExistingByAltUser() {
+
+	/*
+		Resource : bucket, method: create
+		Scenario : second user creates a bucket the main user already owns.
+		Assertion: fails BucketAlreadyExists and the bucket is not listed for alt.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = CreateBucket(altSvc, bucket)
+	suite.expectError(err, "BucketAlreadyExists", http.StatusConflict)
+
+	bkts, err := ListBuckets(altSvc)
+	assert.Nil(err)
+	assert.Equal(false, Contains(bkts, bucket))
+}
+
+func (suite *S3Suite) TestBucketACLReadByAltUserDenied() {
+
+	/*
+		Resource : bucket, method: get/put acl
+		Scenario : second user reads and changes the ACL of a public-read bucket.
+		Assertion: both are denied, READ does not imply READ_ACP.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read"})
+	assert.Nil(err)
+
+	_, err = GetBucketACL(altSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = SetACL(altSvc, bucket, "public-read-write")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketGrantReadToAltUser() {
+
+	/*
+		Resource : bucket, method: list/put object
+		Scenario : READ granted to the alt user by canonical id.
+		Assertion: alt user may list but not write, anonymous user may do neither.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	// Grant headers replace the owner's FULL_CONTROL, which teardown needs.
+	err = CreateBucketWithHeader(svc, bucket, map[string]string{
+		"x-amz-grant-read":         "id=" + altID,
+		"x-amz-grant-full-control": "id=" + mainID,
+	})
+	assert.Nil(err)
+
+	_, err = ListObjects(altSvc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(altSvc, bucket, "foo", "bar")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = ListObjects(anonSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketGrantWriteToAltUser() {
+
+	/*
+		Resource : bucket, method: list/put object
+		Scenario : WRITE granted to the alt user by canonical id.
+		Assertion: alt user may write but not list.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	mainID, err := GetUserID(svc)
+	assert.Nil(err)
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	// Grant headers replace the owner's FULL_CONTROL, which teardown needs.
+	err = CreateBucketWithHeader(svc, bucket, map[string]string{
+		"x-amz-grant-write":        "id=" + altID,
+		"x-amz-grant-full-control": "id=" + mainID,
+	})
+	assert.Nil(err)
+
+	err = PutObjectToBucket(altSvc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = ListObjects(altSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketGrantACPToAltUser() {
+
+	/*
+		Resource : bucket, method: get/put acl
+		Scenario : READ_ACP and WRITE_ACP granted to the alt user.
+		Assertion: alt user may read and replace the ACL but not list the bucket.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	headers := map[string]string{
+		"x-amz-grant-read-acp":  "id=" + altID,
+		"x-amz-grant-write-acp": "id=" + altID,
+	}
+
+	err = CreateBucketWithHeader(svc, bucket, headers)
+	assert.Nil(err)
+
+	acl, err := GetBucketACL(altSvc, bucket)
+	assert.Nil(err)
+	assert.Equal(2, len(acl.Grants))
+
+	_, err = ListObjects(altSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = SetACL(altSvc, bucket, "public-read")
+	assert.Nil(err)
+
+	_, err = ListObjects(anonSvc, bucket)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestObjectPrivateDeniesAltUser() {
+
+	/*
+		Resource : object, method: get
+		Scenario : private object in a public-read bucket.
+		Assertion: the bucket ACL does not make its objects readable.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read"})
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = GetObject(altSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = GetObject(anonSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestObjectPublicReadAltUser() {
+
+	/*
+		Resource : object, method: get/put acl
+		Scenario : public-read object read by alt and anonymous users.
+		Assertion: both may read it, neither may change its ACL.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = SetObjectACL(svc, bucket, "foo", "public-read")
+	assert.Nil(err)
+
+	got, err := GetObject(altSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", got)
+
+	got, err = GetObject(anonSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", got)
+
+	_, err = SetObjectACL(altSvc, bucket, "foo", "public-read-write")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = GetObjectACL(altSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestObjectAuthenticatedReadAltUser() {
+
+	/*
+		Resource : object, method: get
+		Scenario : authenticated-read object read by alt and anonymous users.
+		Assertion: alt user may read, anonymous user is denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = SetObjectACL(svc, bucket, "foo", "authenticated-read")
+	assert.Nil(err)
+
+	_, err = GetObject(altSvc, bucket, "foo")
+	assert.Nil(err)
+
+	_, err = GetObject(anonSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestObjectWrittenByAltUserPrivate() {
+
+	/*
+		Resource : object, method: put/get
+		Scenario : alt user writes a private object into the main user's public-read-write bucket.
+		Assertion: the bucket owner cannot read an object it does not own.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read-write"})
+	assert.Nil(err)
+
+	err = PutObjectToBucket(altSvc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = GetObject(svc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	got, err := GetObject(altSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", got)
+}
+
+func (suite *S3Suite) TestObjectBucketOwnerReadByAltUser() {
+
+	/*
+		Resource : object, method: put/get
+		Scenario : alt user writes an object with bucket-owner-read into the main user's bucket.
+		Assertion: the bucket owner may read the object but not its ACL.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read-write"})
+	assert.Nil(err)
+
+	err = SetupObjectWithHeader(altSvc, bucket, "foo", "bar", map[string]string{"x-amz-acl": "bucket-owner-read"})
+	assert.Nil(err)
+
+	got, err := GetObject(svc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", got)
+
+	_, err = GetObjectACL(svc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestObjectBucketOwnerFullControlByAltUser() {
+
+	/*
+		Resource : object, method: put/get acl
+		Scenario : alt user writes an object with bucket-owner-full-control into the main user's bucket.
+		Assertion: the bucket owner may read the object and manage its ACL.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read-write"})
+	assert.Nil(err)
+
+	err = SetupObjectWithHeader(altSvc, bucket, "foo", "bar", map[string]string{"x-amz-acl": "bucket-owner-full-control"})
+	assert.Nil(err)
+
+	_, err = GetObject(svc, bucket, "foo")
+	assert.Nil(err)
+
+	acl, err := GetObjectACL(svc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal(2, len(acl.Grants))
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+	assert.Equal(altID, *acl.Owner.ID)
+
+	_, err = SetObjectACL(svc, bucket, "foo", "public-read")
+	assert.Nil(err)
+
+	_, err = GetObject(anonSvc, bucket, "foo")
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestObjectGrantReadToAltUser() {
+
+	/*
+		Resource : object, method: get
+		Scenario : READ granted on an object to the alt user by canonical id.
+		Assertion: alt user may read it, anonymous user may not.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	altID, err := GetUserID(altSvc)
+	assert.Nil(err)
+
+	err = CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = SetupObjectWithHeader(svc, bucket, "foo", "bar", map[string]string{"x-amz-grant-read": "id=" + altID})
+	assert.Nil(err)
+
+	_, err = GetObject(altSvc, bucket, "foo")
+	assert.Nil(err)
+
+	_, err = GetObject(anonSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = GetObjectACL(altSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestListBucketsAltUser() {
+
+	/*
+		Resource : service, method: list buckets
+		Scenario : alt user lists buckets while the main user owns one.
+		Assertion: buckets of other users are not listed, anonymous listing is denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read-write"})
+	assert.Nil(err)
+
+	bkts, err := ListBuckets(altSvc)
+	assert.Nil(err)
+	assert.Equal(false, Contains(bkts, bucket))
+
+	_, err = anonSvc.ListBuckets(&s3.ListBucketsInput{})
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
@@ -0,0 +1,491 @@
+package s3test
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+// testNet is an address block reserved for documentation (RFC 5737); no
+// request of the suite comes from it.
+const testNet = "192.0.2.0/24"
+
+func (suite *S3Suite) TestBucketPolicyPutGetDelete() {
+
+	/*
+		Resource : bucket, method: put/get/delete policy
+		Scenario : set a policy, read it back, delete it.
+		Assertion: the policy reads back as set, and is gone once deleted.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	policy := BucketPolicy(PolicyStatement{
+		Effect:    "Allow",
+		Principal: "*",
+		Action:    "s3:GetObject",
+		Resource:  ObjectARN(bucket, "*"),
+	})
+
+	_, err = PutBucketPolicy(svc, bucket, policy)
+	assert.Nil(err)
+
+	got, err := GetBucketPolicy(svc, bucket)
+	assert.Nil(err)
+	assert.JSONEq(policy, got)
+
+	_, err = DeleteBucketPolicy(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketPolicy(svc, bucket)
+	suite.expectError(err, "NoSuchBucketPolicy", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestBucketPolicyGetNotExist() {
+
+	/*
+		Resource : bucket, method: get policy
+		Scenario : read the policy of a bucket that never had one.
+		Assertion: fails NoSuchBucketPolicy.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketPolicy(svc, bucket)
+	suite.expectError(err, "NoSuchBucketPolicy", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestBucketPolicyMalformed() {
+
+	/*
+		Resource : bucket, method: put policy
+		Scenario : set policies that are not JSON, lack required elements, or name
+		           unknown actions, other buckets or unknown principals.
+		Assertion: each fails MalformedPolicy and leaves no policy behind.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	statement := func(change func(*PolicyStatement)) string {
+		st := PolicyStatement{Effect: "Allow", Principal: "*", Action: "s3:GetObject", Resource: ObjectARN(bucket, "*")}
+		change(&st)
+		return BucketPolicy(st)
+	}
+
+	for name, policy := range map[string]string{
+		"not json":          "this is not a policy",
+		"no statement":      `{"Version": "2012-10-17"}`,
+		"bad effect":        statement(func(st *PolicyStatement) { st.Effect = "Maybe" }),
+		"no principal":      statement(func(st *PolicyStatement) { st.Principal = nil }),
+		"unknown principal": statement(func(st *PolicyStatement) { st.Principal = map[string]string{"CanonicalUser": "no-such-user"} }),
+		"unknown action":    statement(func(st *PolicyStatement) { st.Action = "s3:FrobnicateObject" }),
+		"other service":     statement(func(st *PolicyStatement) { st.Action = "sqs:SendMessage" }),
+		"other bucket":      statement(func(st *PolicyStatement) { st.Resource = ObjectARN(bucket+"-other", "*") }),
+	} {
+		_, err = PutBucketPolicy(svc, bucket, policy)
+		suite.expectError(err, "MalformedPolicy", http.StatusBadRequest)
+		assert.NotNil(err, name)
+	}
+
+	_, err = GetBucketPolicy(svc, bucket)
+	suite.expectError(err, "NoSuchBucketPolicy", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestBucketPolicyAltUserDenied() {
+
+	/*
+		Resource : bucket, method: put/delete policy
+		Scenario : alt user sets and deletes the policy of the main user's bucket.
+		Assertion: both are denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	policy := BucketPolicy(PolicyStatement{
+		Effect:    "Allow",
+		Principal: "*",
+		Action:    "s3:GetObject",
+		Resource:  ObjectARN(bucket, "*"),
+	})
+
+	_, err = PutBucketPolicy(altSvc, bucket, policy)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = PutBucketPolicy(svc, bucket, policy)
+	assert.Nil(err)
+
+	_, err = DeleteBucketPolicy(altSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketPolicyAllowAltUser() {
+
+	/*
+		Resource : bucket, method: list/get/put object
+		Scenario : a private bucket whose policy lets the alt user list it and
+		           read its objects.
+		Assertion: alt user may list and read but not write; anonymous is denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	alt, err := UserPrincipal(altSvc)
+	assert.Nil(err)
+
+	_, err = PutBucketPolicy(svc, bucket, BucketPolicy(
+		PolicyStatement{
+			Effect:    "Allow",
+			Principal: alt,
+			Action:    "s3:ListBucket",
+			Resource:  BucketARN(bucket),
+		},
+		PolicyStatement{
+			Effect:    "Allow",
+			Principal: alt,
+			Action:    "s3:GetObject",
+			Resource:  ObjectARN(bucket, "*"),
+		},
+	))
+	assert.Nil(err)
+
+	keys, err := ListObjects(altSvc, bucket)
+	assert.Nil(err)
+	assert.Equal(1, len(keys))
+
+	content, err := GetObject(altSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+
+	err = PutObjectToBucket(altSvc, bucket, "baz", "bar")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = GetObject(anonSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketPolicyAnonymousRead() {
+
+	/*
+		Resource : object, method: get
+		Scenario : a private bucket whose policy lets anyone read its objects.
+		Assertion: anonymous reads succeed, anonymous listing is still denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = GetObject(anonSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = PutBucketPolicy(svc, bucket, BucketPolicy(PolicyStatement{
+		Effect:    "Allow",
+		Principal: "*",
+		Action:    "s3:GetObject",
+		Resource:  ObjectARN(bucket, "*"),
+	}))
+	assert.Nil(err)
+
+	content, err := GetObject(anonSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+
+	_, err = ListObjects(anonSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketPolicyDenyOverridesAllow() {
+
+	/*
+		Resource : object, method: get
+		Scenario : a public-read bucket whose policy allows everyone to read and
+		           denies the alt user.
+		Assertion: the explicit deny wins over both the allow and the ACL for the
+		           alt user; anonymous reads still succeed.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucketWithHeader(svc, bucket, map[string]string{"x-amz-acl": "public-read"})
+	assert.Nil(err)
+
+	err = SetupObjectWithHeader(svc, bucket, "foo", "bar", map[string]string{"x-amz-acl": "public-read"})
+	assert.Nil(err)
+
+	alt, err := UserPrincipal(altSvc)
+	assert.Nil(err)
+
+	_, err = PutBucketPolicy(svc, bucket, BucketPolicy(
+		PolicyStatement{
+			Effect:    "Allow",
+			Principal: "*",
+			Action:    "s3:GetObject",
+			Resource:  ObjectARN(bucket, "*"),
+		},
+		PolicyStatement{
+			Effect:    "Deny",
+			Principal: alt,
+			Action:    "s3:GetObject",
+			Resource:  ObjectARN(bucket, "*"),
+		},
+	))
+	assert.Nil(err)
+
+	_, err = GetObject(altSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	content, err := GetObject(anonSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+
+	content, err = GetObject(svc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+}
+
+func (suite *S3Suite) TestBucketPolicyDenyOwner() {
+
+	/*
+		Resource : object, method: delete
+		Scenario : a policy denies everyone, the owner included, deleting objects.
+		Assertion: the owner is denied until the policy is deleted, which the
+		           owner may still do.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = PutBucketPolicy(svc, bucket, BucketPolicy(PolicyStatement{
+		Effect:    "Deny",
+		Principal: "*",
+		Action:    []string{"s3:DeleteObject", "s3:DeleteObjectVersion"},
+		Resource:  ObjectARN(bucket, "*"),
+	}))
+	assert.Nil(err)
+
+	err = DeleteObject(svc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = DeleteBucketPolicy(svc, bucket)
+	assert.Nil(err)
+
+	err = DeleteObject(svc, bucket, "foo")
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestBucketPolicyResourceWildcard() {
+
+	/*
+		Resource : object, method: get
+		Scenario : a policy lets anyone read keys matching public/* and report-?.txt.
+		Assertion: anonymous reads succeed for matching keys only.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, key := range []string{"public/a", "public/b/c", "private/a", "report-1.txt", "report-10.txt"} {
+		err = PutObjectToBucket(svc, bucket, key, "bar")
+		assert.Nil(err)
+	}
+
+	_, err = PutBucketPolicy(svc, bucket, BucketPolicy(PolicyStatement{
+		Effect:    "Allow",
+		Principal: "*",
+		Action:    "s3:GetObject",
+		Resource:  []string{ObjectARN(bucket, "public/*"), ObjectARN(bucket, "report-?.txt")},
+	}))
+	assert.Nil(err)
+
+	for _, key := range []string{"public/a", "public/b/c", "report-1.txt"} {
+		_, err = GetObject(anonSvc, bucket, key)
+		assert.Nil(err, key)
+	}
+
+	for _, key := range []string{"private/a", "report-10.txt"} {
+		_, err = GetObject(anonSvc, bucket, key)
+		suite.expectError(err, "AccessDenied", http.StatusForbidden)
+	}
+}
+
+func (suite *S3Suite) TestBucketPolicyConditionPrefix() {
+
+	/*
+		Resource : bucket, method: list
+		Scenario : a policy lets anyone list the bucket with the prefix public/.
+		Assertion: anonymous listing with that prefix succeeds and returns only
+		           its keys; other prefixes and no prefix are denied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, key := range []string{"public/a", "private/a"} {
+		err = PutObjectToBucket(svc, bucket, key, "bar")
+		assert.Nil(err)
+	}
+
+	_, err = PutBucketPolicy(svc, bucket, BucketPolicy(PolicyStatement{
+		Effect:    "Allow",
+		Principal: "*",
+		Action:    "s3:ListBucket",
+		Resource:  BucketARN(bucket),
+		Condition: map[string]map[string]interface{}{
+			"StringEquals": {"s3:prefix": "public/"},
+		},
+	}))
+	assert.Nil(err)
+
+	_, keys, _, err := ListObjectsWithPrefix(anonSvc, bucket, "public/")
+	assert.Nil(err)
+	assert.Equal([]string{"public/a"}, keys)
+
+	_, _, _, err = ListObjectsWithPrefix(anonSvc, bucket, "private/")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = ListObjects(anonSvc, bucket)
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestBucketPolicyConditionSourceIP() {
+
+	/*
+		Resource : object, method: get
+		Scenario : a policy lets anyone read objects from an address block no
+		           request comes from, then from anywhere but that block.
+		Assertion: anonymous reads are denied by IpAddress and allowed by
+		           NotIpAddress.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	allowFrom := func(operator string) string {
+		return BucketPolicy(PolicyStatement{
+			Effect:    "Allow",
+			Principal: "*",
+			Action:    "s3:GetObject",
+			Resource:  ObjectARN(bucket, "*"),
+			Condition: map[string]map[string]interface{}{
+				operator: {"aws:SourceIp": testNet},
+			},
+		})
+	}
+
+	_, err = PutBucketPolicy(svc, bucket, allowFrom("IpAddress"))
+	assert.Nil(err)
+
+	_, err = GetObject(anonSvc, bucket, "foo")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = PutBucketPolicy(svc, bucket, allowFrom("NotIpAddress"))
+	assert.Nil(err)
+
+	content, err := GetObject(anonSvc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+}
+
+func (suite *S3Suite) TestBucketPolicyConditionEncryption() {
+
+	/*
+		Resource : object, method: put
+		Scenario : a policy denies writes that do not ask for AES256 server side
+		           encryption.
+		Assertion: a plain write is denied, an AES256 write succeeds.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = PutBucketPolicy(svc, bucket, BucketPolicy(
+		PolicyStatement{
+			Effect:    "Deny",
+			Principal: "*",
+			Action:    "s3:PutObject",
+			Resource:  ObjectARN(bucket, "*"),
+			Condition: map[string]map[string]interface{}{
+				"StringNotEquals": {"s3:x-amz-server-side-encryption": "AES256"},
+			},
+		},
+		PolicyStatement{
+			Effect:    "Deny",
+			Principal: "*",
+			Action:    "s3:PutObject",
+			Resource:  ObjectARN(bucket, "*"),
+			Condition: map[string]map[string]interface{}{
+				"Null": {"s3:x-amz-server-side-encryption": true},
+			},
+		},
+	))
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "plain", "bar")
+	suite.expectError(err, "AccessDenied", http.StatusForbidden)
+
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String("encrypted"),
+		Body:                 strings.NewReader("bar"),
+		ServerSideEncryption: aws.String("AES256"),
+	})
+	assert.Nil(err)
+
+	content, err := GetObject(svc, bucket, "encrypted")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+}
@@ -0,0 +1,297 @@
+package s3test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+// signedPostFields returns the fields of a POST upload of key to bucket,
+// signed with a policy valid for an hour holding conditions.
+func (suite *S3Suite) signedPostFields(bucket string, key string, conditions ...interface{}) map[string]string {
+
+	fields := map[string]string{"key": key}
+
+	err := SignPostForm(fields, bucket, time.Now().Add(time.Hour), conditions...)
+	suite.Nil(err)
+
+	return fields
+}
+
+func (suite *S3Suite) TestPostObjectAnonymous() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload a public-read file to a publicly writable bucket
+		           with an HTML form without a policy.
+		Assertion: the upload succeeds with 204 and the object holds the file.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetACL(svc, bucket, "public-read-write")
+	assert.Nil(err)
+
+	resp, _, err := PostObject(bucket, map[string]string{"key": "anonymous.txt", "acl": "public-read"}, "bar")
+	assert.Nil(err)
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+
+	content, err := GetObject(svc, bucket, "anonymous.txt")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+}
+
+func (suite *S3Suite) TestPostObjectAuthenticated() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload a file with an HTML form signed with a policy whose
+		           exact, starts-with and content-length-range conditions the
+		           ACL, Content-Type, metadata and file meet.
+		Assertion: the upload succeeds with 204 and the object holds the file,
+		           its Content-Type and metadata.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	fields := map[string]string{
+		"key":            "signed.txt",
+		"acl":            "private",
+		"Content-Type":   "text/plain",
+		"x-amz-meta-foo": "bar",
+	}
+
+	err = SignPostForm(fields, bucket, time.Now().Add(time.Hour),
+		map[string]string{"key": "signed.txt"},
+		map[string]string{"acl": "private"},
+		StartsWith("Content-Type", "text/"),
+		StartsWith("x-amz-meta-foo", ""),
+		ContentLengthRange(0, 1024))
+	assert.Nil(err)
+
+	resp, _, err := PostObject(bucket, fields, "bar")
+	assert.Nil(err)
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+
+	obj, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("signed.txt")})
+	if assert.Nil(err) {
+		defer obj.Body.Close()
+		assert.Equal("text/plain", aws.StringValue(obj.ContentType))
+		assert.Equal("bar", aws.StringValue(obj.Metadata["Foo"]))
+	}
+}
+
+func (suite *S3Suite) TestPostObjectFilenameKey() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload a file with an HTML form whose key holds ${filename}.
+		Assertion: the object is stored under the key with the file name of
+		           the upload in place of ${filename}.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	fields := suite.signedPostFields(bucket, "uploads/${filename}", StartsWith("key", "uploads/"))
+
+	resp, _, err := PostObject(bucket, fields, "bar")
+	assert.Nil(err)
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+
+	content, err := GetObject(svc, bucket, "uploads/"+PostFilename)
+	assert.Nil(err)
+	assert.Equal("bar", content)
+}
+
+func (suite *S3Suite) TestPostObjectSuccessActionStatus() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload files with an HTML form asking for success statuses
+		           201, 200 and 404.
+		Assertion: 201 is answered with a PostResponse document naming the
+		           object, 200 with an empty body; 404 is not a success status,
+		           so 204 is returned.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, c := range []struct {
+		status string
+		want   int
+	}{
+		{"201", http.StatusCreated},
+		{"200", http.StatusOK},
+		{"404", http.StatusNoContent},
+	} {
+		fields := suite.signedPostFields(bucket, "status"+c.status, StartsWith("key", "status"), StartsWith("success_action_status", ""))
+		fields["success_action_status"] = c.status
+
+		resp, body, err := PostObject(bucket, fields, "bar")
+		assert.Nil(err)
+		assert.Equal(c.want, resp.StatusCode)
+
+		switch c.want {
+		case http.StatusCreated:
+			assert.Contains(body, "<Key>status201</Key>")
+			assert.Contains(body, "<Bucket>"+bucket+"</Bucket>")
+		case http.StatusOK:
+			assert.Equal("", body)
+		}
+
+		content, err := GetObject(svc, bucket, "status"+c.status)
+		assert.Nil(err)
+		assert.Equal("bar", content)
+	}
+}
+
+func (suite *S3Suite) TestPostObjectSuccessActionRedirect() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload a file with an HTML form asking to be redirected on
+		           success.
+		Assertion: the upload is answered with 303 and a Location naming the
+		           bucket, key and ETag of the object on the redirect URL.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	redirect := "http://www.example.com/uploaded"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	fields := suite.signedPostFields(bucket, "redirected.txt",
+		map[string]string{"key": "redirected.txt"},
+		map[string]string{"success_action_redirect": redirect})
+	fields["success_action_redirect"] = redirect
+
+	resp, _, err := PostObject(bucket, fields, "bar")
+	assert.Nil(err)
+	assert.Equal(http.StatusSeeOther, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if assert.Nil(err) {
+		assert.True(strings.HasPrefix(location.String(), redirect+"?"))
+		assert.Equal(bucket, location.Query().Get("bucket"))
+		assert.Equal("redirected.txt", location.Query().Get("key"))
+		assert.Equal(resp.Header.Get("ETag"), location.Query().Get("etag"))
+	}
+}
+
+func (suite *S3Suite) TestPostObjectExpiredPolicy() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload a file with an HTML form signed with a policy that
+		           has expired.
+		Assertion: fails AccessDenied and nothing is stored.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	fields := map[string]string{"key": "expired.txt"}
+	err = SignPostForm(fields, bucket, time.Now().Add(-time.Minute), StartsWith("key", ""))
+	assert.Nil(err)
+
+	resp, body, err := PostObject(bucket, fields, "bar")
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "AccessDenied", http.StatusForbidden)
+
+	_, err = GetObject(svc, bucket, "expired.txt")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestPostObjectConditionViolations() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload files with HTML forms breaking an exact match, a
+		           starts-with condition, each bound of a content-length-range,
+		           and adding a field no condition covers.
+		Assertion: the broken conditions and the extra field fail
+		           AccessDenied; files too large or too small fail
+		           EntityTooLarge and EntityTooSmall.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	extra := suite.signedPostFields(bucket, "foo.txt", StartsWith("key", ""))
+	extra["x-amz-meta-foo"] = "bar"
+
+	for _, c := range []struct {
+		fields  map[string]string
+		content string
+		code    string
+		status  int
+	}{
+		{suite.signedPostFields(bucket, "foo.txt", map[string]string{"key": "bar.txt"}), "bar", "AccessDenied", http.StatusForbidden},
+		{suite.signedPostFields(bucket, "foo.txt", StartsWith("key", "uploads/")), "bar", "AccessDenied", http.StatusForbidden},
+		{suite.signedPostFields(bucket, "foo.txt", StartsWith("key", ""), ContentLengthRange(0, 2)), "bar", "EntityTooLarge", http.StatusBadRequest},
+		{suite.signedPostFields(bucket, "foo.txt", StartsWith("key", ""), ContentLengthRange(10, 20)), "bar", "EntityTooSmall", http.StatusBadRequest},
+		{extra, "bar", "AccessDenied", http.StatusForbidden},
+	} {
+		resp, body, err := PostObject(bucket, c.fields, c.content)
+		assert.Nil(err)
+		suite.expectError(ResponseError(resp, body), c.code, c.status)
+	}
+
+	_, err = GetObject(svc, bucket, "foo.txt")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestPostObjectMissingFields() {
+
+	/*
+		Resource : object, method: post
+		Scenario : upload files with signed HTML forms missing the key, the
+		           signature, the credential and the policy.
+		Assertion: each fails with 400 InvalidArgument.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, missing := range []string{"key", "x-amz-signature", "x-amz-credential", "policy"} {
+		fields := suite.signedPostFields(bucket, "foo.txt", StartsWith("key", ""))
+		delete(fields, missing)
+
+		resp, body, err := PostObject(bucket, fields, "bar")
+		assert.Nil(err)
+		suite.expectError(ResponseError(resp, body), "InvalidArgument", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,336 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+func (suite *S3Suite) TestPresignedGetObject() {
+
+	/*
+		Resource : object, method: get
+		Scenario : presign a GET and fetch it with a plain HTTP client.
+		Assertion: the URL serves the object.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	p, err := PresignGetObject(svc, bucket, "foo", 15*time.Minute)
+	assert.Nil(err)
+
+	resp, body, err := SendPresigned(p, "")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("bar", body)
+}
+
+func (suite *S3Suite) TestPresignedPutObject() {
+
+	/*
+		Resource : object, method: put
+		Scenario : presign a PUT and upload through it with a plain HTTP
+		           client.
+		Assertion: the upload succeeds and the object holds the body sent.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	p, err := PresignPutObject(svc, bucket, "foo", "", "", 15*time.Minute)
+	assert.Nil(err)
+
+	resp, _, err := SendPresigned(p, "bar")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	content, err := GetObject(svc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal("bar", content)
+}
+
+func (suite *S3Suite) TestPresignedHeadObject() {
+
+	/*
+		Resource : object, method: head
+		Scenario : presign a HEAD and send it with a plain HTTP client.
+		Assertion: the response has the object's size and ETag and no body.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	p, err := PresignHeadObject(svc, bucket, "foo", 15*time.Minute)
+	assert.Nil(err)
+
+	resp, body, err := SendPresigned(p, "")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("3", resp.Header.Get("Content-Length"))
+	assert.Equal(`"37b51d194a7513e45b56f6524f2d51f2"`, resp.Header.Get("ETag"))
+	assert.Equal("", body)
+}
+
+func (suite *S3Suite) TestPresignedDeleteObject() {
+
+	/*
+		Resource : object, method: delete
+		Scenario : presign a DELETE and send it with a plain HTTP client.
+		Assertion: the object is deleted.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	p, err := PresignDeleteObject(svc, bucket, "foo", 15*time.Minute)
+	assert.Nil(err)
+
+	resp, _, err := SendPresigned(p, "")
+	assert.Nil(err)
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+
+	_, err = GetObject(svc, bucket, "foo")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestPresignedUploadPart() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload the last part of a multipart upload through a
+		           presigned URL and complete the upload.
+		Assertion: the part's ETag is returned and the object holds every
+		           part.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, err := UploadParts(svc, bucket, "foo", []int{MinPartSize}, 1)
+	assert.Nil(err)
+
+	p, err := PresignUploadPart(svc, bucket, "foo", u.UploadID, 2, 15*time.Minute)
+	assert.Nil(err)
+
+	resp, _, err := SendPresigned(p, "bar")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(`"37b51d194a7513e45b56f6524f2d51f2"`, resp.Header.Get("ETag"))
+
+	last := &s3.CompletedPart{ETag: aws.String(resp.Header.Get("ETag")), PartNumber: aws.Int64(2)}
+	_, err = u.Complete(svc, append(u.Parts, last)...)
+	assert.Nil(err)
+
+	content, err := GetObject(svc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal(u.Content()+"bar", content)
+}
+
+func (suite *S3Suite) TestPresignedURLExpired() {
+
+	/*
+		Resource : object, method: get
+		Scenario : fetch a presigned GET an hour after it expired.
+		Assertion: fails AccessDenied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	p, err := PresignGetObjectAt(svc, bucket, "foo", time.Now().Add(-2*time.Hour), time.Hour)
+	assert.Nil(err)
+
+	resp, body, err := SendPresigned(p, "")
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestPresignedURLMaxExpiry() {
+
+	/*
+		Resource : object, method: get
+		Scenario : fetch presigned GETs valid for exactly seven days and for
+		           a second more.
+		Assertion: seven days is the longest allowed and is served; longer
+		           fails AuthorizationQueryParametersError.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	p, err := PresignGetObject(svc, bucket, "foo", MaxPresignExpiry)
+	assert.Nil(err)
+
+	resp, body, err := SendPresigned(p, "")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("bar", body)
+
+	p, err = PresignGetObject(svc, bucket, "foo", MaxPresignExpiry+time.Second)
+	assert.Nil(err)
+
+	resp, body, err = SendPresigned(p, "")
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "AuthorizationQueryParametersError", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestPresignedURLTampered() {
+
+	/*
+		Resource : object, method: get
+		Scenario : fetch a presigned GET after pointing it at another key,
+		           and after extending its expiry.
+		Assertion: both fail SignatureDoesNotMatch.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = CreateObjects(svc, bucket, map[string]string{"foo": "bar", "secret": "baz"})
+	assert.Nil(err)
+
+	p, err := PresignGetObject(svc, bucket, "foo", time.Minute)
+	assert.Nil(err)
+
+	u, err := url.Parse(p.URL)
+	assert.Nil(err)
+
+	otherKey := *u
+	otherKey.Path = strings.TrimSuffix(u.Path, "foo") + "secret"
+
+	longer := *u
+	q := longer.Query()
+	q.Set("X-Amz-Expires", "3600")
+	longer.RawQuery = q.Encode()
+
+	for _, tampered := range []url.URL{otherKey, longer} {
+		resp, body, err := SendPresigned(&PresignedRequest{Method: p.Method, URL: tampered.String(), Header: p.Header}, "")
+		assert.Nil(err)
+		suite.expectError(ResponseError(resp, body), "SignatureDoesNotMatch", http.StatusForbidden)
+	}
+}
+
+func (suite *S3Suite) TestPresignedPutObjectBoundHeaders() {
+
+	/*
+		Resource : object, method: put
+		Scenario : presign a PUT binding Content-Type and Content-MD5, and
+		           upload through it with the signed headers, once with the
+		           body they describe and once with another.
+		Assertion: the matching upload succeeds and keeps the Content-Type;
+		           the other fails BadDigest.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	sum := md5.Sum([]byte("bar"))
+	contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	p, err := PresignPutObject(svc, bucket, "foo", "text/plain", contentMD5, 15*time.Minute)
+	assert.Nil(err)
+	assert.Equal("text/plain", p.Header.Get("Content-Type"))
+	assert.Equal(contentMD5, p.Header.Get("Content-MD5"))
+
+	resp, body, err := SendPresigned(p, "baz")
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "BadDigest", http.StatusBadRequest)
+
+	resp, _, err = SendPresigned(p, "bar")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	obj, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String("foo")})
+	assert.Nil(err)
+	assert.Equal("text/plain", aws.StringValue(obj.ContentType))
+}
+
+func (suite *S3Suite) TestPresignedPutObjectHeaderMismatch() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload through a PUT presigned with Content-Type and
+		           Content-MD5 bound, sending another Content-Type, and
+		           leaving Content-MD5 out.
+		Assertion: both fail SignatureDoesNotMatch and nothing is stored.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	sum := md5.Sum([]byte("bar"))
+	p, err := PresignPutObject(svc, bucket, "foo", "text/plain", base64.StdEncoding.EncodeToString(sum[:]), 15*time.Minute)
+	assert.Nil(err)
+
+	otherType := http.Header{}
+	noMD5 := http.Header{}
+	for name, values := range p.Header {
+		otherType[name] = values
+		noMD5[name] = values
+	}
+	otherType.Set("Content-Type", "text/html")
+	noMD5.Del("Content-MD5")
+
+	for _, header := range []http.Header{otherType, noMD5} {
+		resp, body, err := SendPresigned(&PresignedRequest{Method: p.Method, URL: p.URL, Header: header}, "bar")
+		assert.Nil(err)
+		suite.expectError(ResponseError(resp, body), "SignatureDoesNotMatch", http.StatusForbidden)
+	}
+
+	_, err = GetObject(svc, bucket, "foo")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
@@ -0,0 +1,62 @@
+package s3test
+
+import (
+	"testing"
+
+	. "../Utilities"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// startTest adds the running test to the report. Its outcome is recorded
+// once the test and its teardown are over.
+func startTest(t *testing.T, suiteName, testName string) {
+
+	tags := testTags[testName]
+
+	StartTest(suiteName, testName, tags)
+
+	t.Cleanup(func() {
+		switch {
+		case t.Skipped():
+			// Tests skipped through skipTest have recorded why already.
+			EndTest(StatusSkip, SkipReason(tags))
+		case t.Failed():
+			EndTest(StatusFail, "")
+		default:
+			EndTest(StatusPass, "")
+		}
+	})
+}
+
+// skipTest skips the running test for reason, recording it in the report
+// first.
+func skipTest(t *testing.T, reason string) {
+
+	RecordSkip(reason)
+	t.Skip(reason)
+}
+
+func (suite *S3Suite) AfterTest(suiteName, testName string) {
+
+	StopClock()
+}
+
+func (suite *HeadSuite) AfterTest(suiteName, testName string) {
+
+	StopClock()
+}
+
+// expectError asserts that err is the S3 error code, sent with the HTTP
+// status, and records both in the report.
+func (suite *S3Suite) expectError(err error, code string, status int) {
+
+	ExpectError(err, code, status)
+
+	suite.Equal(code, errCode(err))
+
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		suite.Equal(status, reqErr.StatusCode())
+	} else {
+		suite.Fail("no error response", "expected %s (%d), got %v", code, status, err)
+	}
+}
@@ -1,13 +1,48 @@
 package s3test
 
 import (
-	"github.com/stretchr/testify/suite"
+	"fmt"
+	"os"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/suite"
+
 	. "../Utilities"
 )
 
-var svc = GetConn()
+var svc *s3.S3
+
+// TestMain loads the configuration before any client is created, so that
+// a broken config stops the run with every problem listed up front.
+func TestMain(m *testing.M) {
+
+	if err := LoadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := Connect(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	svc = GetConn()
+	altSvc = GetAltConn()
+	anonSvc = GetAnonConn()
+	hostSvc = GetConnWithHostStyle(true)
+
+	code := m.Run()
+
+	if err := WriteReport(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write report, %v\n", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+
+	os.Exit(code)
+}
 
 type S3Suite struct {
 	suite.Suite
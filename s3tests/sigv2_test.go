@@ -0,0 +1,320 @@
+package s3test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+
+	. "../Utilities"
+)
+
+// sendV2 sends a request for key in bucket, or for the bucket when key is
+// empty, signed with SigV2 in its headers as the main user. headers are
+// added before signing.
+func (suite *S3Suite) sendV2(method string, bucket string, key string, query string, body string, headers map[string]string) (*http.Response, string) {
+
+	req, err := NewObjectRequest(method, bucket, key, query, body)
+	suite.Nil(err)
+
+	for name, v := range headers {
+		req.Header.Set(name, v)
+	}
+
+	suite.Nil(SignV2(req, Creds))
+
+	resp, data, err := SendRequest(req)
+	suite.Nil(err)
+
+	return resp, data
+}
+
+func (suite *S3Suite) TestSigV2HeaderAuth() {
+
+	/*
+		Resource : bucket, object, method: put, get
+		Scenario : create a bucket, put an object and read it back with
+		           requests signed with SigV2 in the Authorization header.
+		Assertion: every request succeeds and the object holds its content.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	resp, _ := suite.sendV2("PUT", bucket, "", "", "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, _ = suite.sendV2("PUT", bucket, "foo", "", "bar", map[string]string{"Content-Type": "text/plain", "x-amz-meta-color": "blue"})
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, body := suite.sendV2("GET", bucket, "foo", "", "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("bar", body)
+	assert.Equal("text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal("blue", resp.Header.Get("x-amz-meta-color"))
+}
+
+func (suite *S3Suite) TestSigV2QueryAuth() {
+
+	/*
+		Resource : object, method: get
+		Scenario : fetch an object through a URL presigned with SigV2, and
+		           through one whose Expires has passed.
+		Assertion: the valid URL serves the object; the expired one fails
+		           AccessDenied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("GET", bucket, "foo", "", "")
+	assert.Nil(err)
+	assert.Nil(PresignV2(req, Creds, time.Now().Add(15*time.Minute)))
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("bar", body)
+
+	req, err = NewObjectRequest("GET", bucket, "foo", "", "")
+	assert.Nil(err)
+	assert.Nil(PresignV2(req, Creds, time.Now().Add(-time.Minute)))
+
+	resp, body, err = SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestSigV2BadAuthorizationEmpty() {
+
+	/*
+		Resource : object, method: put
+		Scenario : put an object in a private bucket with an empty
+		           Authorization header.
+		Assertion: the request is anonymous and fails AccessDenied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("PUT", bucket, "foo", "", "bar")
+	assert.Nil(err)
+	req.Header.Set("Authorization", " ")
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestSigV2BadAuthorizationNone() {
+
+	/*
+		Resource : object, method: put
+		Scenario : put an object in a private bucket without an
+		           Authorization header.
+		Assertion: the request is anonymous and fails AccessDenied.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("PUT", bucket, "foo", "", "bar")
+	assert.Nil(err)
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "AccessDenied", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestSigV2BadAuthorizationMalformed() {
+
+	/*
+		Resource : object, method: put
+		Scenario : put an object with a SigV2 Authorization header that has
+		           no signature after the access key.
+		Assertion: fails InvalidArgument.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	creds, err := Creds.Get()
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("PUT", bucket, "foo", "", "bar")
+	assert.Nil(err)
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Authorization", "AWS "+creds.AccessKeyID)
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "InvalidArgument", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestSigV2BadAuthorizationUnknownKey() {
+
+	/*
+		Resource : object, method: put
+		Scenario : put an object signed with SigV2 by an access key the
+		           gateway does not know.
+		Assertion: fails InvalidAccessKeyId.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("PUT", bucket, "foo", "", "bar")
+	assert.Nil(err)
+	assert.Nil(SignV2(req, credentials.NewStaticCredentials("NOSUCHACCESSKEY", "secret", "")))
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "InvalidAccessKeyId", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestSigV2BadSignature() {
+
+	/*
+		Resource : object, method: put
+		Scenario : put an object signed with SigV2 with the main access key
+		           but the wrong secret.
+		Assertion: fails SignatureDoesNotMatch.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	creds, err := Creds.Get()
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("PUT", bucket, "foo", "", "bar")
+	assert.Nil(err)
+	assert.Nil(SignV2(req, credentials.NewStaticCredentials(creds.AccessKeyID, "wrong"+creds.SecretAccessKey, "")))
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "SignatureDoesNotMatch", http.StatusForbidden)
+}
+
+func (suite *S3Suite) TestSigV2ClockSkew() {
+
+	/*
+		Resource : object, method: get
+		Scenario : get an object with SigV2 requests dated twenty minutes in
+		           the past and in the future.
+		Assertion: both fail RequestTimeTooSkewed.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	for _, skew := range []time.Duration{-20 * time.Minute, 20 * time.Minute} {
+		date := time.Now().Add(skew).UTC().Format(http.TimeFormat)
+
+		resp, body := suite.sendV2("GET", bucket, "foo", "", "", map[string]string{"Date": date})
+		suite.expectError(ResponseError(resp, body), "RequestTimeTooSkewed", http.StatusForbidden)
+	}
+}
+
+func (suite *S3Suite) TestSigV2Subresources() {
+
+	/*
+		Resource : object, method: get, put, post, delete
+		Scenario : send SigV2 requests to the ?acl, ?uploads, ?uploadId and
+		           ?versionId subresources, which are part of the signed
+		           resource.
+		Assertion: every request succeeds.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	id, err := PutObjectVersion(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	_, err = PutObjectVersion(svc, bucket, "foo", "baz")
+	assert.Nil(err)
+
+	resp, body := suite.sendV2("GET", bucket, "foo", "versionId="+id, "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("bar", body)
+
+	resp, body = suite.sendV2("GET", bucket, "foo", "acl", "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Contains(body, "<AccessControlPolicy")
+
+	resp, _ = suite.sendV2("PUT", bucket, "foo", "acl", "", map[string]string{"x-amz-acl": "public-read"})
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, body = suite.sendV2("POST", bucket, "multi", "uploads", "", nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Contains(body, "<UploadId>")
+
+	uploads, _, _, err := ListAllMultipartUploads(svc, bucket, ListUploadsOptions{})
+	if assert.Nil(err) && assert.Equal(1, len(uploads)) {
+		resp, _ = suite.sendV2("DELETE", bucket, "multi", "uploadId="+*uploads[0].UploadId, "", nil)
+		assert.Equal(http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func (suite *S3Suite) TestSigV2SubresourceNotSigned() {
+
+	/*
+		Resource : object, method: get
+		Scenario : send a SigV2 request to ?acl signed as if it were for the
+		           object itself.
+		Assertion: fails SignatureDoesNotMatch, as the subresource is part of
+		           the signed resource.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("GET", bucket, "foo", "", "")
+	assert.Nil(err)
+	assert.Nil(SignV2(req, Creds))
+	req.URL.RawQuery = "acl"
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "SignatureDoesNotMatch", http.StatusForbidden)
+}
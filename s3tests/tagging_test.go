@@ -0,0 +1,519 @@
+package s3test
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+func (suite *S3Suite) TestObjectTaggingPutGetDelete() {
+
+	/*
+		Resource : object, method: tagging
+		Scenario : tag an object, read the tags back, then delete them.
+		Assertion: the tags read back are the ones put, and none are left after
+		           the delete.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "tagged"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, key, "bar")
+	assert.Nil(err)
+
+	tags, err := GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Empty(tags)
+
+	want := map[string]string{"project": "s3tests", "cost-center": "42", "empty": ""}
+
+	err = PutObjectTagging(svc, bucket, key, want)
+	assert.Nil(err)
+
+	tags, err = GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+
+	err = DeleteObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+
+	tags, err = GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Empty(tags)
+}
+
+func (suite *S3Suite) TestObjectTaggingReplace() {
+
+	/*
+		Resource : object, method: tagging
+		Scenario : tag an object after it was uploaded with tags.
+		Assertion: the new tag set replaces the old one rather than merging
+		           with it.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "tagged"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectWithTagging(svc, bucket, key, "bar", map[string]string{"a": "1", "b": "2"})
+	assert.Nil(err)
+
+	err = PutObjectTagging(svc, bucket, key, map[string]string{"b": "3", "c": "4"})
+	assert.Nil(err)
+
+	tags, err := GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal(map[string]string{"b": "3", "c": "4"}, tags)
+}
+
+func (suite *S3Suite) TestObjectTaggingMaxTags() {
+
+	/*
+		Resource : object, method: tagging
+		Scenario : tag an object with the maximum number of tags, then with one
+		           more.
+		Assertion: 10 tags are accepted; 11 fail BadRequest and leave the tags
+		           as they were.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "tagged"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, key, "bar")
+	assert.Nil(err)
+
+	want := MakeTags(MaxObjectTags, 8, 8)
+
+	err = PutObjectTagging(svc, bucket, key, want)
+	assert.Nil(err)
+
+	err = PutObjectTagging(svc, bucket, key, MakeTags(MaxObjectTags+1, 8, 8))
+	suite.expectError(err, "BadRequest", http.StatusBadRequest)
+
+	tags, err := GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+}
+
+func (suite *S3Suite) TestObjectTaggingKeyValueLimits() {
+
+	/*
+		Resource : object, method: tagging
+		Scenario : tag an object with keys and values at and over their maximum
+		           lengths.
+		Assertion: keys of 128 and values of 256 characters are accepted; one
+		           character more fails InvalidTag.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "tagged"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, key, "bar")
+	assert.Nil(err)
+
+	want := MakeTags(MaxObjectTags, MaxTagKeyLen, MaxTagValueLen)
+
+	err = PutObjectTagging(svc, bucket, key, want)
+	assert.Nil(err)
+
+	tags, err := GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+
+	err = PutObjectTagging(svc, bucket, key, MakeTags(1, MaxTagKeyLen+1, 1))
+	suite.expectError(err, "InvalidTag", http.StatusBadRequest)
+
+	err = PutObjectTagging(svc, bucket, key, MakeTags(1, 1, MaxTagValueLen+1))
+	suite.expectError(err, "InvalidTag", http.StatusBadRequest)
+
+	tags, err = GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+}
+
+func (suite *S3Suite) TestObjectTaggingOnUpload() {
+
+	/*
+		Resource : object, method: put
+		Scenario : upload objects with tags in the x-amz-tagging header, with
+		           characters that need encoding and with too many tags.
+		Assertion: the tags are stored as sent; an upload with 11 tags fails
+		           BadRequest and writes nothing.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	want := map[string]string{"key with space": "a+b=c", "café": "path/to@host:1"}
+
+	err = PutObjectWithTagging(svc, bucket, "tagged", "bar", want)
+	assert.Nil(err)
+
+	tags, err := GetObjectTagging(svc, bucket, "tagged")
+	assert.Nil(err)
+	assert.Equal(want, tags)
+
+	err = PutObjectWithTagging(svc, bucket, "toomany", "bar", MakeTags(MaxObjectTags+1, 8, 8))
+	suite.expectError(err, "BadRequest", http.StatusBadRequest)
+
+	_, err = GetObject(svc, bucket, "toomany")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestObjectTaggingCopy() {
+
+	/*
+		Resource : object, method: copy
+		Scenario : copy a tagged object with the default tagging directive, with
+		           COPY, and with REPLACE both with and without new tags.
+		Assertion: the tags of the source are copied unless the directive is
+		           REPLACE, which takes the tags of the request.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	source := map[string]string{"origin": "source"}
+	replaced := map[string]string{"origin": "copy", "extra": "1"}
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectWithTagging(svc, bucket, "source", "bar", source)
+	assert.Nil(err)
+
+	for _, c := range []struct {
+		key       string
+		directive string
+		tags      map[string]string
+		want      map[string]string
+	}{
+		{"default", "", replaced, source},
+		{"copy", s3.TaggingDirectiveCopy, replaced, source},
+		{"replace", s3.TaggingDirectiveReplace, replaced, replaced},
+		{"replace-empty", s3.TaggingDirectiveReplace, nil, map[string]string{}},
+	} {
+		err = CopyObjectWithTagging(svc, bucket, bucket+"/source", c.key, c.directive, c.tags)
+		assert.Nil(err, c.key)
+
+		tags, err := GetObjectTagging(svc, bucket, c.key)
+		assert.Nil(err, c.key)
+		assert.Equal(c.want, tags, c.key)
+	}
+}
+
+func (suite *S3Suite) TestObjectTaggingMultipart() {
+
+	/*
+		Resource : object, method: multipart upload
+		Scenario : start a multipart upload with tags and complete it.
+		Assertion: the completed object carries the tags.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "mymultipart"
+	want := map[string]string{"upload": "multipart", "parts": "2"}
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	u, err := InitiateMultipartUploadWithTagging(svc, bucket, key, want)
+	assert.Nil(err)
+
+	for i, size := range []int{MinPartSize, 10} {
+		data := strings.Repeat("x", size)
+		part, err := u.UploadPart(svc, int64(i+1), data)
+		assert.Nil(err)
+		u.Parts = append(u.Parts, part)
+		u.Data = append(u.Data, data)
+	}
+
+	_, err = u.Complete(svc, u.Parts...)
+	assert.Nil(err)
+
+	tags, err := GetObjectTagging(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+}
+
+func (suite *S3Suite) TestObjectTaggingCount() {
+
+	/*
+		Resource : object, method: get
+		Scenario : read an object before tagging it, after tagging it on upload
+		           and afterwards, and once its tags are deleted.
+		Assertion: x-amz-tagging-count gives the number of tags, and is left
+		           out when there are none.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "untagged", "bar")
+	assert.Nil(err)
+
+	count, err := GetObjectTaggingCount(svc, bucket, "untagged")
+	assert.Nil(err)
+	assert.Equal(int64(0), count)
+
+	err = PutObjectWithTagging(svc, bucket, "tagged", "bar", MakeTags(3, 8, 8))
+	assert.Nil(err)
+
+	count, err = GetObjectTaggingCount(svc, bucket, "tagged")
+	assert.Nil(err)
+	assert.Equal(int64(3), count)
+
+	err = PutObjectTagging(svc, bucket, "tagged", MakeTags(MaxObjectTags, 8, 8))
+	assert.Nil(err)
+
+	count, err = GetObjectTaggingCount(svc, bucket, "tagged")
+	assert.Nil(err)
+	assert.Equal(int64(MaxObjectTags), count)
+
+	err = DeleteObjectTagging(svc, bucket, "tagged")
+	assert.Nil(err)
+
+	resp, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("tagged")})
+	assert.Nil(err)
+	if err == nil {
+		resp.Body.Close()
+		assert.Nil(resp.TagCount)
+	}
+}
+
+func (suite *S3Suite) TestObjectTaggingNoSuchKey() {
+
+	/*
+		Resource : object, method: tagging
+		Scenario : get, put and delete the tags of a key that does not exist.
+		Assertion: all three fail NoSuchKey.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetObjectTagging(svc, bucket, "missing")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+
+	err = PutObjectTagging(svc, bucket, "missing", map[string]string{"a": "b"})
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+
+	err = DeleteObjectTagging(svc, bucket, "missing")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestBucketTaggingPutGetDelete() {
+
+	/*
+		Resource : bucket, method: tagging
+		Scenario : tag a bucket, tag it again with another set, then delete the
+		           tags.
+		Assertion: the tags read back are the last set put, and none are left
+		           after the delete.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutBucketTagging(svc, bucket, map[string]string{"team": "storage", "cost-center": "42"})
+	assert.Nil(err)
+
+	want := map[string]string{"team": "gateway", "env": "staging", "empty": ""}
+
+	err = PutBucketTagging(svc, bucket, want)
+	assert.Nil(err)
+
+	tags, err := GetBucketTagging(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+
+	err = DeleteBucketTagging(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketTagging(svc, bucket)
+	suite.expectError(err, "NoSuchTagSet", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestBucketTaggingNoSuchTagSet() {
+
+	/*
+		Resource : bucket, method: tagging
+		Scenario : read the tags of a bucket never tagged, and delete them.
+		Assertion: the read fails NoSuchTagSet; the delete succeeds.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketTagging(svc, bucket)
+	suite.expectError(err, "NoSuchTagSet", http.StatusNotFound)
+
+	err = DeleteBucketTagging(svc, bucket)
+	assert.Nil(err)
+}
+
+func (suite *S3Suite) TestBucketTaggingEmptyTagSet() {
+
+	/*
+		Resource : bucket, method: tagging
+		Scenario : tag a bucket, then put an empty tag set.
+		Assertion: the empty set is accepted and leaves the bucket untagged.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutBucketTagging(svc, bucket, map[string]string{"team": "storage"})
+	assert.Nil(err)
+
+	err = PutBucketTagging(svc, bucket, map[string]string{})
+	assert.Nil(err)
+
+	_, err = GetBucketTagging(svc, bucket)
+	suite.expectError(err, "NoSuchTagSet", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestBucketTaggingDuplicateKeys() {
+
+	/*
+		Resource : bucket, method: tagging
+		Scenario : tag a bucket with a tag set holding the same key twice.
+		Assertion: fails InvalidTag and leaves the tags as they were.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	want := map[string]string{"team": "storage"}
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutBucketTagging(svc, bucket, want)
+	assert.Nil(err)
+
+	_, err = svc.PutBucketTagging(&s3.PutBucketTaggingInput{
+		Bucket: aws.String(bucket),
+		Tagging: &s3.Tagging{TagSet: []*s3.Tag{
+			{Key: aws.String("env"), Value: aws.String("prod")},
+			{Key: aws.String("env"), Value: aws.String("staging")},
+		}},
+	})
+	suite.expectError(err, "InvalidTag", http.StatusBadRequest)
+
+	tags, err := GetBucketTagging(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+}
+
+func (suite *S3Suite) TestBucketTaggingInvalidCharacters() {
+
+	/*
+		Resource : bucket, method: tagging
+		Scenario : tag a bucket with keys and values holding characters tags do
+		           not allow, and with a key in the reserved aws: namespace.
+		Assertion: each fails InvalidTag, while letters, digits, spaces and
+		           + - = . _ : / @ are accepted.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	for _, tags := range []map[string]string{
+		{"hash#key": "value"},
+		{"key": "percent%value"},
+		{"angle<key>": "value"},
+		{"key": "semi;colon"},
+		{"aws:reserved": "value"},
+	} {
+		err = PutBucketTagging(svc, bucket, tags)
+		suite.expectError(err, "InvalidTag", http.StatusBadRequest)
+	}
+
+	want := map[string]string{"Key With Space_1": "a+b-c=d.e_f:g/h@i", "café": "naïve"}
+
+	err = PutBucketTagging(svc, bucket, want)
+	assert.Nil(err)
+
+	tags, err := GetBucketTagging(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+}
+
+func (suite *S3Suite) TestBucketTaggingLimits() {
+
+	/*
+		Resource : bucket, method: tagging
+		Scenario : tag a bucket with 50 tags of the longest keys and values
+		           allowed, then with one tag, key or value too many.
+		Assertion: the limits are accepted; going past any of them fails
+		           InvalidTag and leaves the tags as they were.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	want := MakeTags(MaxBucketTags, MaxTagKeyLen, MaxTagValueLen)
+
+	err = PutBucketTagging(svc, bucket, want)
+	assert.Nil(err)
+
+	tags, err := GetBucketTagging(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+
+	for _, over := range []map[string]string{
+		MakeTags(MaxBucketTags+1, 8, 8),
+		MakeTags(1, MaxTagKeyLen+1, 1),
+		MakeTags(1, 1, MaxTagValueLen+1),
+	} {
+		err = PutBucketTagging(svc, bucket, over)
+		suite.expectError(err, "InvalidTag", http.StatusBadRequest)
+	}
+
+	tags, err = GetBucketTagging(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(want, tags)
+}
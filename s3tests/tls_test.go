@@ -0,0 +1,146 @@
+package s3test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	. "../Utilities"
+)
+
+// sseCustomerKey is a raw 256-bit key and its MD5; the SDK base64 encodes
+// the key itself.
+var sseCustomerKey = []string{"AES256", "s3tests-customer-provided-key-32", "SMLIR1tdI7ysuwAiK6QFhw=="}
+
+func (suite *S3Suite) skipUnlessSSL() {
+
+	if !UseSSL() {
+		skipTest(suite.T(), "requires is_secure")
+	}
+}
+
+func (suite *S3Suite) TestTLSEncryptedRoundTrip() {
+
+	/*
+		Resource : object, method: put/get
+		Scenario : write and read an SSE-C object over HTTPS.
+		Assertion: both requests succeed and the data round-trips.
+	*/
+
+	suite.skipUnlessSSL()
+
+	assert := suite
+	bucket := GetBucketName()
+	data := strings.Repeat("A", 1024)
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = WriteSSECEcrypted(svc, bucket, "testobj", data, sseCustomerKey)
+	assert.Nil(err)
+
+	got, err := ReadSSECEcrypted(svc, bucket, "testobj", sseCustomerKey)
+	assert.Nil(err)
+	assert.Equal(data, got)
+
+	// Without the key the object cannot be read back.
+	_, err = GetObject(svc, bucket, "testobj")
+	assert.NotNil(err)
+}
+
+func (suite *S3Suite) TestEncryptionSSECOverHTTPRejected() {
+
+	/*
+		Resource : object, method: put
+		Scenario : send an SSE-C PUT over plain HTTP without the SDK, which
+		           would refuse to send it.
+		Assertion: the gateway fails it InvalidRequest and stores nothing.
+	*/
+
+	if UseSSL() {
+		skipTest(suite.T(), "requires a plain HTTP endpoint")
+	}
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	req, err := NewObjectRequest("PUT", bucket, "testobj", "", "echo")
+	assert.Nil(err)
+	req.Header.Set("x-amz-server-side-encryption-customer-algorithm", sseCustomerKey[0])
+	req.Header.Set("x-amz-server-side-encryption-customer-key", base64.StdEncoding.EncodeToString([]byte(sseCustomerKey[1])))
+	req.Header.Set("x-amz-server-side-encryption-customer-key-MD5", sseCustomerKey[2])
+	assert.Nil(SignRequest(req, "echo"))
+
+	resp, body, err := SendRequest(req)
+	assert.Nil(err)
+	suite.expectError(ResponseError(resp, body), "InvalidRequest", http.StatusBadRequest)
+
+	_, err = GetObject(svc, bucket, "testobj")
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestTLSPresignedURL() {
+
+	/*
+		Resource : object, method: get
+		Scenario : presign a GET over HTTPS and fetch it without the SDK.
+		Assertion: the URL uses https and serves the object.
+	*/
+
+	suite.skipUnlessSSL()
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	err = PutObjectToBucket(svc, bucket, "foo", "bar")
+	assert.Nil(err)
+
+	url, err := GeneratePresignedUrlGetObject(svc, bucket, "foo")
+	assert.Nil(err)
+	assert.Equal(true, strings.HasPrefix(url, "https://"))
+
+	resp, err := svc.Config.HTTPClient.Get(url)
+	assert.Nil(err)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("bar", string(body))
+}
+
+func (suite *S3Suite) TestTLSUntrustedCertificate() {
+
+	/*
+		Resource : service, method: get
+		Scenario : connect with a client that trusts no certificate authority.
+		Assertion: the handshake fails before any request is sent.
+	*/
+
+	suite.skipUnlessSSL()
+
+	if GetConfig().Default.InsecureSkipVerify {
+		skipTest(suite.T(), "certificate verification is disabled")
+	}
+
+	assert := suite
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: x509.NewCertPool()},
+	}}
+
+	_, err := client.Get(svc.Endpoint)
+	assert.NotNil(err)
+}
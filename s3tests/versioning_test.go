@@ -0,0 +1,528 @@
+package s3test
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"fmt"
+	"net/http"
+
+	. "../Utilities"
+)
+
+func (suite *S3Suite) TestVersioningBucketCreateSuspend() {
+
+	/*
+		Resource : bucket, method: put/get versioning
+		Scenario : enable, suspend and re-enable versioning on a new bucket.
+		Assertion: the status reads back as set and is empty before the first change.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	status, err := GetBucketVersioning(svc, bucket)
+	assert.Nil(err)
+	assert.Equal("", status)
+
+	for _, want := range []string{"Enabled", "Suspended", "Enabled"} {
+		_, err = SetBucketVersioning(svc, bucket, want)
+		assert.Nil(err)
+
+		status, err = GetBucketVersioning(svc, bucket)
+		assert.Nil(err)
+		assert.Equal(want, status)
+	}
+}
+
+func (suite *S3Suite) TestVersioningBucketBadStatus() {
+
+	/*
+		Resource : bucket, method: put versioning
+		Scenario : set versioning to a status other than Enabled or Suspended.
+		Assertion: fails MalformedXML.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Bogus")
+	suite.expectError(err, "MalformedXML", http.StatusBadRequest)
+}
+
+func (suite *S3Suite) TestVersioningObjectCreateReadRemove() {
+
+	/*
+		Resource : object, method: put/get version
+		Scenario : write the same key three times in a versioned bucket.
+		Assertion: each write gets its own version, every version stays readable.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	contents := []string{"v1", "v2", "v3"}
+	var ids []string
+
+	for _, content := range contents {
+		id, err := PutObjectVersion(svc, bucket, key, content)
+		assert.Nil(err)
+		assert.NotEqual("", id)
+		assert.Equal(false, Contains(ids, id))
+		ids = append(ids, id)
+	}
+
+	for i, id := range ids {
+		got, err := GetObjectVersion(svc, bucket, key, id)
+		assert.Nil(err)
+		assert.Equal(contents[i], got)
+	}
+
+	got, err := GetObject(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal("v3", got)
+
+	resp, err := ListObjectVersions(svc, bucket, "")
+	assert.Nil(err)
+	assert.Equal(3, len(resp.Versions))
+	assert.Equal(0, len(resp.DeleteMarkers))
+
+	// Versions of a key are listed newest first.
+	assert.Equal(ids[2], *resp.Versions[0].VersionId)
+	assert.Equal(true, *resp.Versions[0].IsLatest)
+	assert.Equal(false, *resp.Versions[1].IsLatest)
+}
+
+func (suite *S3Suite) TestVersioningObjectHeadVersion() {
+
+	/*
+		Resource : object, method: head version
+		Scenario : head an older version of an object.
+		Assertion: the version id and size of that version are returned.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	first, err := PutObjectVersion(svc, bucket, key, "short")
+	assert.Nil(err)
+
+	_, err = PutObjectVersion(svc, bucket, key, "much longer")
+	assert.Nil(err)
+
+	resp, err := HeadObjectVersion(svc, bucket, key, first)
+	assert.Nil(err)
+	assert.Equal(first, *resp.VersionId)
+	assert.Equal(int64(5), *resp.ContentLength)
+}
+
+func (suite *S3Suite) TestVersioningObjectDeleteMarker() {
+
+	/*
+		Resource : object, method: delete
+		Scenario : delete an object in a versioned bucket without a version id.
+		Assertion: a delete marker hides the object, removing the marker restores it.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	id, err := PutObjectVersion(svc, bucket, key, "data")
+	assert.Nil(err)
+
+	resp, err := DeleteObjectVersion(svc, bucket, key, "")
+	assert.Nil(err)
+	assert.Equal(true, aws.BoolValue(resp.DeleteMarker))
+	marker := aws.StringValue(resp.VersionId)
+	assert.NotEqual("", marker)
+	assert.NotEqual(id, marker)
+
+	_, err = GetObject(svc, bucket, key)
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+
+	objs, err := ListObjects(svc, bucket)
+	assert.Nil(err)
+	assert.Equal(0, len(objs))
+
+	versions, err := ListObjectVersions(svc, bucket, "")
+	assert.Nil(err)
+	assert.Equal(1, len(versions.Versions))
+	assert.Equal(1, len(versions.DeleteMarkers))
+	assert.Equal(marker, *versions.DeleteMarkers[0].VersionId)
+	assert.Equal(true, *versions.DeleteMarkers[0].IsLatest)
+
+	got, err := GetObjectVersion(svc, bucket, key, id)
+	assert.Nil(err)
+	assert.Equal("data", got)
+
+	resp, err = DeleteObjectVersion(svc, bucket, key, marker)
+	assert.Nil(err)
+	assert.Equal(true, aws.BoolValue(resp.DeleteMarker))
+
+	got, err = GetObject(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal("data", got)
+}
+
+func (suite *S3Suite) TestVersioningObjectGetDeleteMarkerVersion() {
+
+	/*
+		Resource : object, method: get version
+		Scenario : read the version id of a delete marker.
+		Assertion: fails MethodNotAllowed.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	_, err = PutObjectVersion(svc, bucket, key, "data")
+	assert.Nil(err)
+
+	resp, err := DeleteObjectVersion(svc, bucket, key, "")
+	assert.Nil(err)
+
+	_, err = GetObjectVersion(svc, bucket, key, *resp.VersionId)
+	suite.expectError(err, "MethodNotAllowed", http.StatusMethodNotAllowed)
+}
+
+func (suite *S3Suite) TestVersioningObjectRemoveVersions() {
+
+	/*
+		Resource : object, method: delete version
+		Scenario : remove the versions of an object one by one, newest first.
+		Assertion: the previous version becomes current and the key goes away with the last one.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	first, err := PutObjectVersion(svc, bucket, key, "v1")
+	assert.Nil(err)
+
+	second, err := PutObjectVersion(svc, bucket, key, "v2")
+	assert.Nil(err)
+
+	resp, err := DeleteObjectVersion(svc, bucket, key, second)
+	assert.Nil(err)
+	assert.Equal(second, aws.StringValue(resp.VersionId))
+	assert.Equal(false, aws.BoolValue(resp.DeleteMarker))
+
+	got, err := GetObject(svc, bucket, key)
+	assert.Nil(err)
+	assert.Equal("v1", got)
+
+	_, err = GetObjectVersion(svc, bucket, key, second)
+	suite.expectError(err, "NoSuchVersion", http.StatusNotFound)
+
+	_, err = DeleteObjectVersion(svc, bucket, key, first)
+	assert.Nil(err)
+
+	_, err = GetObject(svc, bucket, key)
+	suite.expectError(err, "NoSuchKey", http.StatusNotFound)
+
+	versions, err := ListObjectVersions(svc, bucket, "")
+	assert.Nil(err)
+	assert.Equal(0, len(versions.Versions))
+	assert.Equal(0, len(versions.DeleteMarkers))
+}
+
+func (suite *S3Suite) TestVersioningNullVersionAfterSuspend() {
+
+	/*
+		Resource : object, method: put
+		Scenario : write before enabling, while enabled and after suspending versioning.
+		Assertion: writes outside Enabled share the null version, which the last one overwrites.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = PutObjectVersion(svc, bucket, key, "unversioned")
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	enabled, err := PutObjectVersion(svc, bucket, key, "enabled")
+	assert.Nil(err)
+	assert.NotEqual("null", enabled)
+
+	got, err := GetObjectVersion(svc, bucket, key, "null")
+	assert.Nil(err)
+	assert.Equal("unversioned", got)
+
+	_, err = SetBucketVersioning(svc, bucket, "Suspended")
+	assert.Nil(err)
+
+	suspended, err := PutObjectVersion(svc, bucket, key, "suspended")
+	assert.Nil(err)
+	assert.Equal("null", suspended)
+
+	got, err = GetObjectVersion(svc, bucket, key, "null")
+	assert.Nil(err)
+	assert.Equal("suspended", got)
+
+	got, err = GetObjectVersion(svc, bucket, key, enabled)
+	assert.Nil(err)
+	assert.Equal("enabled", got)
+
+	versions, err := ListObjectVersions(svc, bucket, "")
+	assert.Nil(err)
+	assert.Equal(2, len(versions.Versions))
+}
+
+func (suite *S3Suite) TestVersioningSuspendedDeleteMarker() {
+
+	/*
+		Resource : object, method: delete
+		Scenario : delete an object while versioning is suspended.
+		Assertion: the null version is replaced by a null delete marker, older versions survive.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	enabled, err := PutObjectVersion(svc, bucket, key, "enabled")
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Suspended")
+	assert.Nil(err)
+
+	_, err = PutObjectVersion(svc, bucket, key, "suspended")
+	assert.Nil(err)
+
+	resp, err := DeleteObjectVersion(svc, bucket, key, "")
+	assert.Nil(err)
+	assert.Equal(true, aws.BoolValue(resp.DeleteMarker))
+	assert.Equal("null", aws.StringValue(resp.VersionId))
+
+	versions, err := ListObjectVersions(svc, bucket, "")
+	assert.Nil(err)
+	assert.Equal(1, len(versions.Versions))
+	assert.Equal(enabled, *versions.Versions[0].VersionId)
+	assert.Equal(1, len(versions.DeleteMarkers))
+	assert.Equal("null", *versions.DeleteMarkers[0].VersionId)
+}
+
+func (suite *S3Suite) TestVersioningCopyObjectVersion() {
+
+	/*
+		Resource : object, method: copy
+		Scenario : copy an older version of an object to a new key.
+		Assertion: the copy has the content of the requested version.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+	key := "testobj"
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	first, err := PutObjectVersion(svc, bucket, key, "v1")
+	assert.Nil(err)
+
+	_, err = PutObjectVersion(svc, bucket, key, "v2")
+	assert.Nil(err)
+
+	resp, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String("copy"),
+		CopySource: aws.String(fmt.Sprintf("%s/%s?versionId=%s", bucket, key, first)),
+	})
+	assert.Nil(err)
+	assert.Equal(first, aws.StringValue(resp.CopySourceVersionId))
+	assert.NotEqual("", aws.StringValue(resp.VersionId))
+
+	got, err := GetObject(svc, bucket, "copy")
+	assert.Nil(err)
+	assert.Equal("v1", got)
+}
+
+func (suite *S3Suite) TestVersioningMultiObjectDeleteVersions() {
+
+	/*
+		Resource : object, method: multi-delete
+		Scenario : delete specific versions with a single multi-object delete.
+		Assertion: only the named versions are removed.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	var objs []*s3.ObjectIdentifier
+	for _, key := range []string{"a", "b"} {
+		id, err := PutObjectVersion(svc, bucket, key, "old")
+		assert.Nil(err)
+		objs = append(objs, &s3.ObjectIdentifier{Key: aws.String(key), VersionId: aws.String(id)})
+
+		_, err = PutObjectVersion(svc, bucket, key, "new")
+		assert.Nil(err)
+	}
+
+	resp, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3.Delete{Objects: objs},
+	})
+	assert.Nil(err)
+	assert.Equal(2, len(resp.Deleted))
+	assert.Equal(*objs[0].VersionId, aws.StringValue(resp.Deleted[0].VersionId))
+
+	versions, err := ListObjectVersions(svc, bucket, "")
+	assert.Nil(err)
+	assert.Equal(2, len(versions.Versions))
+
+	got, err := GetObject(svc, bucket, "a")
+	assert.Nil(err)
+	assert.Equal("new", got)
+}
+
+func (suite *S3Suite) TestVersioningListVersionsPaginated() {
+
+	/*
+		Resource : bucket, method: list versions
+		Scenario : page through versions and delete markers two at a time.
+		Assertion: every version and marker is returned exactly once.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	want := map[string]bool{}
+	for _, key := range []string{"a", "a", "a", "b", "c", "c"} {
+		id, err := PutObjectVersion(svc, bucket, key, key)
+		assert.Nil(err)
+		want[id] = true
+	}
+
+	resp, err := DeleteObjectVersion(svc, bucket, "b", "")
+	assert.Nil(err)
+	want[*resp.VersionId] = true
+
+	got := map[string]bool{}
+	input := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket), MaxKeys: aws.Int64(2)}
+
+	for pages := 0; pages < 10; pages++ {
+		page, err := svc.ListObjectVersions(input)
+		assert.Nil(err)
+
+		assert.True(len(page.Versions)+len(page.DeleteMarkers) <= 2)
+		for _, v := range page.Versions {
+			assert.Equal(false, got[*v.VersionId])
+			got[*v.VersionId] = true
+		}
+		for _, m := range page.DeleteMarkers {
+			assert.Equal(false, got[*m.VersionId])
+			got[*m.VersionId] = true
+		}
+
+		if !aws.BoolValue(page.IsTruncated) {
+			break
+		}
+		input.KeyMarker, input.VersionIdMarker = page.NextKeyMarker, page.NextVersionIdMarker
+	}
+
+	assert.Equal(want, got)
+}
+
+func (suite *S3Suite) TestVersioningBucketCleanup() {
+
+	/*
+		Resource : bucket, method: delete
+		Scenario : remove a versioned bucket holding old versions and delete markers.
+		Assertion: DeletePrefixedBuckets empties and deletes it.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetBucketVersioning(svc, bucket, "Enabled")
+	assert.Nil(err)
+
+	for i := 0; i < 3; i++ {
+		_, err = PutObjectVersion(svc, bucket, "key", fmt.Sprintf("v%d", i))
+		assert.Nil(err)
+	}
+
+	_, err = DeleteObjectVersion(svc, bucket, "key", "")
+	assert.Nil(err)
+
+	err = DeleteBucket(svc, bucket)
+	suite.expectError(err, "BucketNotEmpty", http.StatusConflict)
+
+	DeletePrefixedBuckets(svc)
+
+	bkts, err := ListBuckets(svc)
+	assert.Nil(err)
+	assert.Equal(false, Contains(bkts, bucket))
+}
@@ -0,0 +1,369 @@
+package s3test
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	. "../Utilities"
+)
+
+func (suite *S3Suite) skipUnlessWebsite() {
+
+	if WebsiteEndpoint() == "" {
+		skipTest(suite.T(), "requires website_endpoint")
+	}
+}
+
+// publicWebsite creates a publicly readable bucket holding objects, each
+// publicly readable too, and returns its name. The bucket name is a valid
+// DNS label, since website requests address buckets as subdomains.
+func (suite *S3Suite) publicWebsite(objects map[string]string) string {
+
+	assert := suite
+	bucket := GetDNSBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = SetACL(svc, bucket, "public-read")
+	assert.Nil(err)
+
+	for key, content := range objects {
+		err = PutObjectToBucket(svc, bucket, key, content)
+		assert.Nil(err)
+
+		_, err = SetObjectACL(svc, bucket, key, "public-read")
+		assert.Nil(err)
+	}
+
+	return bucket
+}
+
+func (suite *S3Suite) TestWebsitePutGetDelete() {
+
+	/*
+		Resource : bucket, method: website
+		Scenario : set a website configuration with index and error documents
+		           and a routing rule, read it back, then delete it.
+		Assertion: the configuration read back is the one set; once deleted,
+		           reading it fails NoSuchWebsiteConfiguration.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketWebsite(svc, bucket)
+	suite.expectError(err, "NoSuchWebsiteConfiguration", http.StatusNotFound)
+
+	config := WebsiteConfig("index.html", "error.html", &s3.RoutingRule{
+		Condition: &s3.Condition{KeyPrefixEquals: aws.String("docs/")},
+		Redirect:  &s3.Redirect{ReplaceKeyPrefixWith: aws.String("documents/"), HttpRedirectCode: aws.String("302")},
+	})
+
+	err = PutBucketWebsite(svc, bucket, config)
+	assert.Nil(err)
+
+	resp, err := GetBucketWebsite(svc, bucket)
+	assert.Nil(err)
+	assert.Equal("index.html", aws.StringValue(resp.IndexDocument.Suffix))
+	assert.Equal("error.html", aws.StringValue(resp.ErrorDocument.Key))
+	if assert.Equal(1, len(resp.RoutingRules)) {
+		assert.Equal(config.RoutingRules[0].String(), resp.RoutingRules[0].String())
+	}
+
+	err = DeleteBucketWebsite(svc, bucket)
+	assert.Nil(err)
+
+	_, err = GetBucketWebsite(svc, bucket)
+	suite.expectError(err, "NoSuchWebsiteConfiguration", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestWebsiteInvalidConfig() {
+
+	/*
+		Resource : bucket, method: website
+		Scenario : set website configurations without an index document, with
+		           an index document suffix holding a slash, redirecting every
+		           request alongside an index document, and with a routing
+		           rule replacing both the key and its prefix.
+		Assertion: each fails with the error S3 gives and sets nothing.
+	*/
+
+	assert := suite
+	bucket := GetBucketName()
+
+	err := CreateBucket(svc, bucket)
+	assert.Nil(err)
+
+	noIndex := &s3.WebsiteConfiguration{ErrorDocument: &s3.ErrorDocument{Key: aws.String("error.html")}}
+
+	redirectAndIndex := WebsiteConfig("index.html", "")
+	redirectAndIndex.RedirectAllRequestsTo = &s3.RedirectAllRequestsTo{HostName: aws.String("www.example.com")}
+
+	bothReplacements := WebsiteConfig("index.html", "", &s3.RoutingRule{
+		Redirect: &s3.Redirect{ReplaceKeyWith: aws.String("a.html"), ReplaceKeyPrefixWith: aws.String("b/")},
+	})
+
+	for _, c := range []struct {
+		config *s3.WebsiteConfiguration
+		code   string
+	}{
+		{noIndex, "InvalidArgument"},
+		{WebsiteConfig("docs/index.html", ""), "InvalidArgument"},
+		{redirectAndIndex, "InvalidRequest"},
+		{bothReplacements, "InvalidRequest"},
+	} {
+		err = PutBucketWebsite(svc, bucket, c.config)
+		suite.expectError(err, c.code, http.StatusBadRequest)
+	}
+
+	_, err = GetBucketWebsite(svc, bucket)
+	suite.expectError(err, "NoSuchWebsiteConfiguration", http.StatusNotFound)
+}
+
+func (suite *S3Suite) TestWebsiteNoConfiguration() {
+
+	/*
+		Resource : website endpoint, method: get
+		Scenario : read a public bucket without a website configuration from
+		           its website endpoint.
+		Assertion: fails with 404.
+	*/
+
+	suite.skipUnlessWebsite()
+
+	assert := suite
+	bucket := suite.publicWebsite(map[string]string{"index.html": "<h1>index</h1>"})
+
+	resp, _, err := WebsiteRequest("GET", bucket, "")
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *S3Suite) TestWebsiteIndexDocument() {
+
+	/*
+		Resource : website endpoint, method: get
+		Scenario : read the root of the site, a directory, and a directory
+		           without its trailing slash.
+		Assertion: the root and the directory are answered with their index
+		           documents; the directory without a slash is redirected to
+		           the directory.
+	*/
+
+	suite.skipUnlessWebsite()
+
+	assert := suite
+	bucket := suite.publicWebsite(map[string]string{
+		"index.html":      "<h1>index</h1>",
+		"docs/index.html": "<h1>docs</h1>",
+	})
+
+	err := PutBucketWebsite(svc, bucket, WebsiteConfig("index.html", ""))
+	assert.Nil(err)
+
+	resp, body, err := WebsiteRequest("GET", bucket, "")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("<h1>index</h1>", body)
+
+	resp, body, err = WebsiteRequest("GET", bucket, "docs/")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("<h1>docs</h1>", body)
+
+	resp, _, err = WebsiteRequest("GET", bucket, "docs")
+	assert.Nil(err)
+	assert.Equal(http.StatusFound, resp.StatusCode)
+	assert.Contains(resp.Header.Get("Location"), "/docs/")
+}
+
+func (suite *S3Suite) TestWebsiteErrorDocument() {
+
+	/*
+		Resource : website endpoint, method: get
+		Scenario : read a missing key and a private object from a site with
+		           an error document, then a missing key from a site without.
+		Assertion: the error document is served with 404 and 403; without
+		           one the missing key fails with 404.
+	*/
+
+	suite.skipUnlessWebsite()
+
+	assert := suite
+	bucket := suite.publicWebsite(map[string]string{
+		"index.html": "<h1>index</h1>",
+		"error.html": "<h1>error</h1>",
+	})
+
+	err := PutObjectToBucket(svc, bucket, "private.html", "<h1>private</h1>")
+	assert.Nil(err)
+
+	err = PutBucketWebsite(svc, bucket, WebsiteConfig("index.html", "error.html"))
+	assert.Nil(err)
+
+	resp, body, err := WebsiteRequest("GET", bucket, "missing.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	assert.Equal("<h1>error</h1>", body)
+
+	resp, body, err = WebsiteRequest("GET", bucket, "private.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+	assert.Equal("<h1>error</h1>", body)
+
+	err = PutBucketWebsite(svc, bucket, WebsiteConfig("index.html", ""))
+	assert.Nil(err)
+
+	resp, body, err = WebsiteRequest("GET", bucket, "missing.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	assert.NotEqual("<h1>error</h1>", body)
+}
+
+func (suite *S3Suite) TestWebsiteRoutingRuleKeyPrefix() {
+
+	/*
+		Resource : website endpoint, method: get
+		Scenario : read keys matching routing rules on their key prefix, one
+		           replacing the prefix on the same host, one replacing the
+		           whole key on another host over https.
+		Assertion: each is redirected with the rule's code to the key and host
+		           it names; other keys are served.
+	*/
+
+	suite.skipUnlessWebsite()
+
+	assert := suite
+	bucket := suite.publicWebsite(map[string]string{
+		"index.html": "<h1>index</h1>",
+		"page.html":  "<h1>page</h1>",
+	})
+
+	err := PutBucketWebsite(svc, bucket, WebsiteConfig("index.html", "",
+		&s3.RoutingRule{
+			Condition: &s3.Condition{KeyPrefixEquals: aws.String("docs/")},
+			Redirect:  &s3.Redirect{ReplaceKeyPrefixWith: aws.String("documents/"), HttpRedirectCode: aws.String("302")},
+		},
+		&s3.RoutingRule{
+			Condition: &s3.Condition{KeyPrefixEquals: aws.String("old/")},
+			Redirect:  &s3.Redirect{HostName: aws.String("www.example.com"), Protocol: aws.String("https"), ReplaceKeyWith: aws.String("new.html")},
+		}))
+	assert.Nil(err)
+
+	resp, _, err := WebsiteRequest("GET", bucket, "docs/guide.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusFound, resp.StatusCode)
+	assert.Equal(WebsiteURL(bucket, "documents/guide.html"), resp.Header.Get("Location"))
+
+	resp, _, err = WebsiteRequest("GET", bucket, "old/page.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal("https://www.example.com/new.html", resp.Header.Get("Location"))
+
+	resp, body, err := WebsiteRequest("GET", bucket, "page.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("<h1>page</h1>", body)
+}
+
+func (suite *S3Suite) TestWebsiteRoutingRuleErrorCode() {
+
+	/*
+		Resource : website endpoint, method: get
+		Scenario : read a missing key and an existing one from a site with a
+		           routing rule on the 404 error code.
+		Assertion: the missing key is redirected to the host of the rule with
+		           its prefix replaced; the existing key is served.
+	*/
+
+	suite.skipUnlessWebsite()
+
+	assert := suite
+	bucket := suite.publicWebsite(map[string]string{
+		"index.html": "<h1>index</h1>",
+		"page.html":  "<h1>page</h1>",
+	})
+
+	err := PutBucketWebsite(svc, bucket, WebsiteConfig("index.html", "", &s3.RoutingRule{
+		Condition: &s3.Condition{HttpErrorCodeReturnedEquals: aws.String("404")},
+		Redirect:  &s3.Redirect{HostName: aws.String("www.example.com"), Protocol: aws.String("http"), ReplaceKeyPrefixWith: aws.String("report-404/")},
+	}))
+	assert.Nil(err)
+
+	resp, _, err := WebsiteRequest("GET", bucket, "missing.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal("http://www.example.com/report-404/missing.html", resp.Header.Get("Location"))
+
+	resp, body, err := WebsiteRequest("GET", bucket, "page.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("<h1>page</h1>", body)
+}
+
+func (suite *S3Suite) TestWebsiteRedirectAllRequests() {
+
+	/*
+		Resource : website endpoint, method: get
+		Scenario : read the root and a key from a site redirecting every
+		           request to another host.
+		Assertion: both are redirected permanently to the same path on that
+		           host.
+	*/
+
+	suite.skipUnlessWebsite()
+
+	assert := suite
+	bucket := suite.publicWebsite(map[string]string{"index.html": "<h1>index</h1>"})
+
+	err := PutBucketWebsite(svc, bucket, &s3.WebsiteConfiguration{
+		RedirectAllRequestsTo: &s3.RedirectAllRequestsTo{HostName: aws.String("www.example.com"), Protocol: aws.String("https")},
+	})
+	assert.Nil(err)
+
+	resp, _, err := WebsiteRequest("GET", bucket, "")
+	assert.Nil(err)
+	assert.Equal(http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal("https://www.example.com/", resp.Header.Get("Location"))
+
+	resp, _, err = WebsiteRequest("GET", bucket, "docs/page.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal("https://www.example.com/docs/page.html", resp.Header.Get("Location"))
+}
+
+func (suite *S3Suite) TestWebsiteObjectRedirect() {
+
+	/*
+		Resource : website endpoint, method: get
+		Scenario : read an object written with x-amz-website-redirect-location.
+		Assertion: the request is redirected permanently to that location.
+	*/
+
+	suite.skipUnlessWebsite()
+
+	assert := suite
+	bucket := suite.publicWebsite(map[string]string{"index.html": "<h1>index</h1>"})
+
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:                  aws.String(bucket),
+		Key:                     aws.String("moved.html"),
+		ACL:                     aws.String("public-read"),
+		WebsiteRedirectLocation: aws.String("/index.html"),
+	})
+	assert.Nil(err)
+
+	err = PutBucketWebsite(svc, bucket, WebsiteConfig("index.html", ""))
+	assert.Nil(err)
+
+	resp, _, err := WebsiteRequest("GET", bucket, "moved.html")
+	assert.Nil(err)
+	assert.Equal(http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal("/index.html", resp.Header.Get("Location"))
+}